@@ -0,0 +1,203 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+// cache-replay replays a trace of flow cache reads against simulated
+// eviction policies and reports what the cache orchestrator would have
+// decided, and the miss ratio which would have followed. It does not need
+// a cache server.
+//
+//	cache-replay -trace reads.jsonl -capacity 10000 -switch-threshold 0.03
+//
+// The trace is the JSON Lines format of the cachetrace package.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/danenherdi/faas-provider/pkg/adaptive"
+	"github.com/openfaas/faas-netes/pkg/cachesim"
+	"github.com/openfaas/faas-netes/pkg/cachetrace"
+	"github.com/openfaas/faas-netes/pkg/orchestrator"
+)
+
+func main() {
+	var (
+		tracePath         string
+		policies          string
+		capacity          int64
+		bySize            bool
+		initialPolicy     string
+		evalInterval      time.Duration
+		stabilityPeriod   time.Duration
+		switchThreshold   float64
+		maxMemory         uint64
+		weights           string
+		refreshCandidates bool
+		decisionsPath     string
+		jsonOutput        bool
+		verbose           bool
+	)
+
+	defaults := adaptive.DefaultOrchestratorConfig()
+
+	flag.StringVar(&tracePath, "trace", "-", "trace of cache reads as JSON Lines, - for stdin")
+	flag.StringVar(&policies, "policies", strings.Join(cachesim.Policies(), ","), "candidate policies, comma separated")
+	flag.Int64Var(&capacity, "capacity", 1000, "capacity of the cache in entries, or in bytes with -by-size")
+	flag.BoolVar(&bySize, "by-size", false, "measure the capacity in bytes, using the size of each value")
+	flag.StringVar(&initialPolicy, "initial", "", "policy to start with, by default the best over the first interval")
+	flag.DurationVar(&evalInterval, "eval-interval", defaults.EvaluationInterval, "interval between evaluations, as ORCHESTRATOR_EVAL_INTERVAL")
+	flag.DurationVar(&stabilityPeriod, "stability-period", defaults.StabilityPeriod, "period without evaluations after a switch, as ORCHESTRATOR_STABILITY_PERIOD")
+	flag.Float64Var(&switchThreshold, "switch-threshold", defaults.SwitchThreshold, "minimum net benefit of a switch, as ORCHESTRATOR_SWITCH_THRESHOLD")
+	flag.Uint64Var(&maxMemory, "max-memory", 8, "memory available to the cache in GB, as ORCHESTRATOR_MAX_MEMORY")
+	flag.StringVar(&weights, "weights", "", "weights of the time, memory and degradation costs of a switch, comma separated, such as 0.4,0.2,0.4")
+	flag.BoolVar(&refreshCandidates, "refresh-candidates", false, "measure every candidate on each evaluation, not only the policy in use")
+	flag.StringVar(&decisionsPath, "decisions", "", "write the decisions to this file as JSON Lines")
+	flag.BoolVar(&jsonOutput, "json", false, "print the result as JSON")
+	flag.BoolVar(&verbose, "v", false, "print the log of each evaluation")
+	flag.Parse()
+
+	if !verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	config := orchestrator.SimulationConfig{
+		Orchestrator: &adaptive.OrchestratorConfig{
+			EvaluationInterval: evalInterval,
+			StabilityPeriod:    stabilityPeriod,
+			SwitchThreshold:    switchThreshold,
+			MaxMemory:          maxMemory * 1024 * 1024 * 1024,
+		},
+		Policies:          splitList(policies),
+		Capacity:          capacity,
+		BySize:            bySize,
+		InitialPolicy:     initialPolicy,
+		RefreshCandidates: refreshCandidates,
+	}
+
+	if len(weights) > 0 {
+		for _, weight := range splitList(weights) {
+			value, err := strconv.ParseFloat(weight, 64)
+			if err != nil {
+				fail(fmt.Errorf("invalid weight %q: %w", weight, err))
+			}
+			config.Weights = append(config.Weights, value)
+		}
+	}
+
+	simulation, err := orchestrator.NewSimulation(config)
+	if err != nil {
+		fail(err)
+	}
+
+	input := os.Stdin
+	if tracePath != "-" {
+		if input, err = os.Open(tracePath); err != nil {
+			fail(err)
+		}
+		defer input.Close()
+	}
+
+	reader := cachetrace.NewReader(input)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fail(fmt.Errorf("unable to read trace: %w", err))
+		}
+
+		simulation.Access(record)
+	}
+
+	result := simulation.Result()
+
+	if len(decisionsPath) > 0 {
+		if err := writeDecisions(decisionsPath, result.Decisions); err != nil {
+			fail(err)
+		}
+	}
+
+	if jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(result)
+		return
+	}
+
+	printResult(os.Stdout, result)
+}
+
+func printResult(out io.Writer, result orchestrator.SimulationResult) {
+	fmt.Fprintf(out, "Reads: %d\n", result.Accesses)
+	fmt.Fprintf(out, "Recorded miss ratio: %.4f\n", result.RecordedMissRatio)
+	fmt.Fprintf(out, "Orchestrated miss ratio: %.4f (%s → %s, %d switches)\n\n",
+		result.MissRatio, result.InitialPolicy, result.FinalPolicy, result.Switches)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "POLICY\tMISS RATIO")
+	policies := make([]string, 0, len(result.PolicyMissRatios))
+	for policy := range result.PolicyMissRatios {
+		policies = append(policies, policy)
+	}
+	sort.Strings(policies)
+	for _, policy := range policies {
+		fmt.Fprintf(w, "%s\t%.4f\n", policy, result.PolicyMissRatios[policy])
+	}
+	w.Flush()
+
+	if len(result.Decisions) == 0 {
+		return
+	}
+
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tTRIGGER\tDECISION\tFROM\tTO\tMISS RATIO\tCANDIDATE\tCOST\tNET BENEFIT")
+	for _, d := range result.Decisions {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%.4f\t%.4f\t%.4f\t%.4f\n",
+			d.Timestamp.Format(time.RFC3339), d.Trigger, d.Decision, d.FromPolicy, d.ToPolicy,
+			d.CurrentMissRatio, d.CandidateMissRatio, d.SwitchingCost, d.NetBenefit)
+	}
+	w.Flush()
+}
+
+func writeDecisions(path string, decisions []orchestrator.Decision) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(file)
+	for _, decision := range decisions {
+		if err := encoder.Encode(decision); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	return file.Close()
+}
+
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "cache-replay: %s\n", err)
+	os.Exit(1)
+}
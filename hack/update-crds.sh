@@ -19,7 +19,7 @@ fi
 # Prevent CRDs from being removed via helm uninstall
 
 # Only annotate CRDs which are held in templates, but not the ones in the crds/ folder
-ANNOTATE_CRDS=("openfaas.com_profiles.yaml" "openfaas.com_functioningresses.yaml" "openfaas.com_functions.yaml")
+ANNOTATE_CRDS=("openfaas.com_flows.yaml" "openfaas.com_profiles.yaml" "openfaas.com_functioningresses.yaml" "openfaas.com_functions.yaml")
 
 for f in ./artifacts/crds/*.yaml; do \
 
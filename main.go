@@ -8,30 +8,35 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"strconv"
+	"strings"
 	"time"
 
 	paperClient "github.com/danenherdi/paper-client-go"
-	"github.com/redis/go-redis/v9"
 
 	faasProvider "github.com/danenherdi/faas-provider"
+	"github.com/danenherdi/faas-provider/auth"
 	"github.com/danenherdi/faas-provider/logs"
 	"github.com/danenherdi/faas-provider/proxy"
 	providertypes "github.com/danenherdi/faas-provider/types"
+	"github.com/openfaas/faas-netes/pkg/cachetrace"
+	"github.com/openfaas/faas-netes/pkg/caching"
 	clientset "github.com/openfaas/faas-netes/pkg/client/clientset/versioned"
 	informers "github.com/openfaas/faas-netes/pkg/client/informers/externalversions"
 	v1 "github.com/openfaas/faas-netes/pkg/client/informers/externalversions/openfaas/v1"
 	"github.com/openfaas/faas-netes/pkg/config"
+	"github.com/openfaas/faas-netes/pkg/flows"
 	"github.com/openfaas/faas-netes/pkg/handlers"
 	"github.com/openfaas/faas-netes/pkg/k8s"
+	"github.com/openfaas/faas-netes/pkg/orchestrator"
 	"github.com/openfaas/faas-netes/pkg/signals"
+	"github.com/openfaas/faas-netes/pkg/tracing"
 	version "github.com/openfaas/faas-netes/version"
+	"github.com/prometheus/client_golang/prometheus"
 	kubeinformers "k8s.io/client-go/informers"
 	v1apps "k8s.io/client-go/informers/apps/v1"
 	v1core "k8s.io/client-go/informers/core/v1"
@@ -48,10 +53,13 @@ import (
 
 const defaultResync = time.Hour * 10
 
+// shutdownTimeout limits the time taken to send queued spans and cache
+// trace records once the server has shut down
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	var kubeconfig string
 	var masterURL string
-	var flowConfigFile string
 	var (
 		verbose bool
 	)
@@ -62,8 +70,6 @@ func main() {
 	flag.StringVar(&masterURL, "master", "",
 		"The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster.")
 	flag.Bool("operator", false, "Run as an operator (not available in CE)")
-	flag.StringVar(&flowConfigFile, "flowconfig", "/etc/open-faas/flows/config.json",
-		"Path to a flow config file")
 	flag.Parse()
 
 	sha, release := version.GetReleaseInfo()
@@ -109,6 +115,15 @@ Version: %s Commit: %s
 
 	config.Fprint(verbose)
 
+	exporter, err := tracing.NewExporter(config.Tracing)
+	if err != nil {
+		log.Fatalf("Error configuring tracing: %s", err.Error())
+	}
+	if exporter != nil {
+		tracing.SetExporter(exporter)
+		log.Printf("Exporting spans to: %s", config.Tracing.Endpoint)
+	}
+
 	deployConfig := k8s.DeploymentConfig{
 		RuntimeHTTPPort: 8080,
 		HTTPProbe:       config.HTTPProbe,
@@ -146,8 +161,19 @@ Version: %s Commit: %s
 	}
 	config.FaaSConfig.EnableCaching = isCachingEnabled == "true"
 
+	// The orchestrator settings are read along with the config, whichever
+	// the caching method
+	if config.FaaSConfig.EnableIntelligentOrchestrator {
+		log.Println("Intelligent Orchestrator Configuration:")
+		log.Printf("Enabled: %v", config.FaaSConfig.EnableIntelligentOrchestrator)
+		log.Printf("Evaluation Interval: %d seconds", config.FaaSConfig.OrchestratorEvalInterval)
+		log.Printf("Stability Period: %d seconds", config.FaaSConfig.OrchestratorStabilityPeriod)
+		log.Printf("Switch Threshold: %.2f%%", config.FaaSConfig.OrchestratorSwitchThreshold*100)
+		log.Printf("Max Memory: %d GB", config.FaaSConfig.OrchestratorMaxMemory)
+	}
+
 	// Get caching method
-	var cacheClient providertypes.CacheClient
+	var cacheClient caching.Client
 	cachingMethod := os.Getenv("CACHING_METHOD")
 
 	// Case for papercache caching method
@@ -158,104 +184,28 @@ Version: %s Commit: %s
 		}
 		log.Printf("Attempting to connect to PaperCache at: %s", paperHost)
 
-		// Orchestrator settings
-		enableIntelligentOrchestrator := os.Getenv("ENABLE_INTELLIGENT_ORCHESTRATOR")
-		if enableIntelligentOrchestrator == "true" {
-			config.FaaSConfig.EnableIntelligentOrchestrator = true
-		} else {
-			config.FaaSConfig.EnableIntelligentOrchestrator = false // Default: false
-		}
-
-		orchestratorEvalInterval := os.Getenv("ORCHESTRATOR_EVAL_INTERVAL")
-		if orchestratorEvalInterval != "" {
-			if val, err := strconv.ParseUint(orchestratorEvalInterval, 10, 64); err == nil {
-				config.FaaSConfig.OrchestratorEvalInterval = val
-			} else {
-				log.Printf("Invalid ORCHESTRATOR_EVAL_INTERVAL: %s, using default (10)", orchestratorEvalInterval)
-				config.FaaSConfig.OrchestratorEvalInterval = 10
-			}
-		} else {
-			config.FaaSConfig.OrchestratorEvalInterval = 10 // Default: 10 seconds
-		}
-
-		orchestratorStabilityPeriod := os.Getenv("ORCHESTRATOR_STABILITY_PERIOD")
-		if orchestratorStabilityPeriod != "" {
-			if val, err := strconv.ParseUint(orchestratorStabilityPeriod, 10, 64); err == nil {
-				config.FaaSConfig.OrchestratorStabilityPeriod = val
-			} else {
-				log.Printf("Invalid ORCHESTRATOR_STABILITY_PERIOD: %s, using default (30)", orchestratorStabilityPeriod)
-				config.FaaSConfig.OrchestratorStabilityPeriod = 30
-			}
-		} else {
-			config.FaaSConfig.OrchestratorStabilityPeriod = 30 // Default: 30 seconds
-		}
-
-		orchestratorSwitchThreshold := os.Getenv("ORCHESTRATOR_SWITCH_THRESHOLD")
-		if orchestratorSwitchThreshold != "" {
-			if val, err := strconv.ParseFloat(orchestratorSwitchThreshold, 64); err == nil {
-				if val > 0 && val <= 1.0 {
-					config.FaaSConfig.OrchestratorSwitchThreshold = val
-				} else {
-					log.Printf("Invalid ORCHESTRATOR_SWITCH_THRESHOLD: %s (must be 0-1), using default (0.05)", orchestratorSwitchThreshold)
-					config.FaaSConfig.OrchestratorSwitchThreshold = 0.05
-				}
-			} else {
-				log.Printf("Invalid ORCHESTRATOR_SWITCH_THRESHOLD: %s, using default (0.05)", orchestratorSwitchThreshold)
-				config.FaaSConfig.OrchestratorSwitchThreshold = 0.05
-			}
-		} else {
-			config.FaaSConfig.OrchestratorSwitchThreshold = 0.05 // Default: 5%
-		}
-
-		orchestratorMaxMemory := os.Getenv("ORCHESTRATOR_MAX_MEMORY")
-		if orchestratorMaxMemory != "" {
-			if val, err := strconv.ParseUint(orchestratorMaxMemory, 10, 64); err == nil {
-				config.FaaSConfig.OrchestratorMaxMemory = val
-			} else {
-				log.Printf("Invalid ORCHESTRATOR_MAX_MEMORY: %s, using default (8)", orchestratorMaxMemory)
-				config.FaaSConfig.OrchestratorMaxMemory = 8
-			}
-		} else {
-			config.FaaSConfig.OrchestratorMaxMemory = 8 // Default: 8 GB
-		}
-
-		// Log configuration
-		if config.FaaSConfig.EnableIntelligentOrchestrator {
-			log.Println("Intelligent Orchestrator Configuration:")
-			log.Printf("Enabled: %v", config.FaaSConfig.EnableIntelligentOrchestrator)
-			log.Printf("Evaluation Interval: %d seconds", config.FaaSConfig.OrchestratorEvalInterval)
-			log.Printf("Stability Period: %d seconds", config.FaaSConfig.OrchestratorStabilityPeriod)
-			log.Printf("Switch Threshold: %.2f%%", config.FaaSConfig.OrchestratorSwitchThreshold*100)
-			log.Printf("Max Memory: %d GB", config.FaaSConfig.OrchestratorMaxMemory)
-		}
-
 		client, err := paperClient.ClientConnect(paperHost)
 		if err != nil {
 			log.Fatalf("Error connecting to PaperCache: %s", err.Error())
 		}
-		cacheClient = &providertypes.PaperCacheClientWrapper{Client: client}
+		cacheClient = caching.NewPaperCache(client)
 		log.Printf("Using PaperCache for caching. Host: %s", paperHost)
-	} else {
-		client := redis.NewClient(&redis.Options{
-			Addr: "localhost:6379",
-			DB:   0,
-		})
-		cacheClient = &providertypes.RedisClientWrapper{Client: client}
-		log.Println("Using Redis for caching.")
-	}
-
-	// Flows config
-	var flows providertypes.Flows
-	// Read the flow configuration file
-	flowsData, err := os.ReadFile(flowConfigFile)
-	if err != nil {
-		log.Fatalf("Error creating flow configuration: %s", err.Error())
+	} else if cachingMethod == "memory" {
+		memory := caching.NewMemory(config.MemoryCache.MaxBytes, config.MemoryCache.MaxEntries)
+		prometheus.MustRegister(memory)
+		cacheClient = memory
+		log.Printf("Using in-memory caching. Max bytes: %d, Max entries: %d", config.MemoryCache.MaxBytes, config.MemoryCache.MaxEntries)
+	} else if config.FaaSConfig.EnableCaching {
+		client, err := caching.ConnectRedis(context.Background(), config.Redis)
+		if err != nil {
+			log.Fatalf("Error connecting to Redis: %s", err.Error())
+		}
+		cacheClient = client
+		log.Printf("Using Redis for caching. Mode: %s, Address: %s", config.Redis.Mode(), strings.Join(config.Redis.Addresses, ","))
 	}
-	_ = json.Unmarshal([]byte(flowsData), &flows)
 
 	setup := serverSetup{
 		config:              config,
-		flows:               flows,
 		functionFactory:     factory,
 		kubeInformerFactory: kubeInformerFactory,
 		faasInformerFactory: faasInformerFactory,
@@ -271,6 +221,7 @@ type customInformers struct {
 	EndpointsInformer  v1core.EndpointsInformer
 	DeploymentInformer v1apps.DeploymentInformer
 	FunctionsInformer  v1.FunctionInformer
+	FlowsInformer      v1.FlowInformer
 }
 
 func startInformers(setup serverSetup, stopCh <-chan struct{}, operator bool) customInformers {
@@ -298,10 +249,20 @@ func startInformers(setup serverSetup, stopCh <-chan struct{}, operator bool) cu
 		log.Fatalf("failed to wait for cache to sync")
 	}
 
+	if err := flows.CheckInstalled(setup.faasClient.Discovery()); err != nil {
+		log.Fatalf("Error starting the flows informer: %s", err.Error())
+	}
+	flows := faasInformerFactory.Openfaas().V1().Flows()
+	go flows.Informer().Run(stopCh)
+	if ok := cache.WaitForNamedCacheSync("faas-netes:flows", stopCh, flows.Informer().HasSynced); !ok {
+		log.Fatalf("failed to wait for cache to sync")
+	}
+
 	return customInformers{
 		EndpointsInformer:  endpoints,
 		DeploymentInformer: deployments,
 		FunctionsInformer:  functions,
+		FlowsInformer:      flows,
 	}
 }
 
@@ -319,10 +280,14 @@ func runController(setup serverSetup) {
 	deployLister := listers.DeploymentInformer.Lister()
 	functionLookup := k8s.NewFunctionLookup(config.DefaultFunctionNamespace, listers.EndpointsInformer.Lister())
 	functionList := k8s.NewFunctionList(config.DefaultFunctionNamespace, deployLister)
+	flowLister := listers.FlowsInformer.Lister()
+	flowLookup := flows.NewLookup(config.DefaultFunctionNamespace, flowLister)
+	flows.RegisterEventHandlers(listers.FlowsInformer.Informer(), flowLookup)
 
 	printFunctionExecutionTime := true
 
-	proxyHandler := proxy.NewHandlerFunc(config.FaaSConfig, functionLookup, printFunctionExecutionTime)
+	// the function's traceparent is set by the span of its invocation
+	proxyHandler := tracing.Handler("function", proxy.NewHandlerFunc(config.FaaSConfig, functionLookup, printFunctionExecutionTime))
 
 	if err := handlers.Check(functionList); err != nil {
 		msg := fmt.Sprintf("Function invocations disabled due to error: %s.", err.Error())
@@ -333,14 +298,57 @@ func runController(setup serverSetup) {
 		}
 	}
 
+	var executions *flows.ExecutionStore
+	if config.ExecutionHistory > 0 {
+		executions = flows.NewExecutionStore(config.ExecutionHistory)
+	}
+
+	// ctx is cancelled on the first shutdown signal, which shuts down the
+	// server and the background workers
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+
+	var decisions *orchestrator.DecisionLog
+	if config.DecisionHistory > 0 {
+		decisions = orchestrator.NewDecisionLog(config.DecisionHistory)
+	}
+
+	var cacheOrchestrator *orchestrator.Orchestrator
+	if config.FaaSConfig.EnableCaching && setup.cacheClient != nil {
+		backend := orchestrator.NewBackend(setup.cacheClient, config.Redis.Policies)
+		cacheOrchestrator = orchestrator.Start(ctx, config.FaaSConfig, backend, decisions)
+	}
+	if cacheOrchestrator != nil {
+		prometheus.MustRegister(cacheOrchestrator)
+	}
+
+	cacheTraceSink, err := cachetrace.NewSink(config.CacheTrace)
+	if err != nil {
+		log.Fatalf("Error configuring the cache trace: %s", err.Error())
+	}
+	var cacheTrace *cachetrace.Recorder
+	if cacheTraceSink != nil {
+		cacheTrace = cachetrace.NewRecorder(cacheTraceSink, config.CacheTrace.SampleRate)
+		log.Printf("Capturing cache reads to: %s", config.CacheTrace.Sink)
+	}
+
+	flowHandler := flows.NewHandler(config.FaaSConfig, config.DefaultFunctionNamespace, setup.cacheClient, functionLookup, flowLookup, k8s.NewSecretsClient(kubeClient), executions, cacheOrchestrator, cacheTrace, printFunctionExecutionTime)
+
+	asyncFlows := flows.NewAsyncQueue(flowHandler, config.AsyncFlows.Workers, config.AsyncFlows.QueueSize, printFunctionExecutionTime)
+	asyncFlows.Start(ctx)
+
 	bootstrapHandlers := providertypes.FaaSHandlers{
 		FunctionProxy:  proxyHandler,
-		Flows:          handlers.MakeFlowsHandler(setup.flows),
-		FlowProxy:      proxy.NewFlowHandler(config.FaaSConfig, setup.cacheClient, functionLookup, setup.flows, printFunctionExecutionTime),
+		Flows:          handlers.MakeFlowsHandler(config.DefaultFunctionNamespace, flowLister),
+		FlowProxy:      flowHandler,
 		DeleteFunction: handlers.MakeDeleteHandler(config.DefaultFunctionNamespace, kubeClient),
 		DeployFunction: handlers.MakeDeployHandler(config.DefaultFunctionNamespace, factory, functionList),
 		FunctionLister: handlers.MakeFunctionReader(config.DefaultFunctionNamespace, deployLister),
-		FlowReader:     handlers.MakeFlowReader(config.DefaultFunctionNamespace, nil),
+		FlowReader:     handlers.MakeFlowReader(config.DefaultFunctionNamespace, flowLister, deployLister),
 		FunctionStatus: handlers.MakeReplicaReader(config.DefaultFunctionNamespace, deployLister),
 		ScaleFunction:  handlers.MakeReplicaUpdater(config.DefaultFunctionNamespace, kubeClient),
 		UpdateFunction: handlers.MakeUpdateHandler(config.DefaultFunctionNamespace, factory),
@@ -351,19 +359,88 @@ func runController(setup serverSetup) {
 		ListNamespaces: handlers.MakeNamespacesLister(config.DefaultFunctionNamespace, kubeClient),
 	}
 
-	ctx := context.Background()
+	var flowCache caching.Client
+	if config.FaaSConfig.EnableCaching {
+		flowCache = setup.cacheClient
+	}
+
+	registerSystemRoutes(config.FaaSConfig, []systemRoute{
+		{path: "/system/flows/status", method: http.MethodGet, handler: bootstrapHandlers.FlowReader},
+		{path: "/system/flows/validate", method: http.MethodPost, handler: flows.MakeValidateHandler(flowLookup)},
+		{path: "/system/flows/cache", method: http.MethodDelete, handler: flows.MakeCachePurgeHandler(config.DefaultFunctionNamespace, flowCache)},
+		{path: "/system/flows/{name:[" + faasProvider.NameExpression + "]+}/cache", method: http.MethodDelete, handler: flows.MakeCacheInvalidationHandler(config.DefaultFunctionNamespace, flowCache, flowLookup)},
+		{path: "/system/flows/{name:[" + faasProvider.NameExpression + "]+}/cache/{params:.*}", method: http.MethodDelete, handler: flows.MakeCacheInvalidationHandler(config.DefaultFunctionNamespace, flowCache, flowLookup)},
+		{path: "/system/flows/{name:[" + faasProvider.NameExpression + "]+}/executions", method: http.MethodGet, handler: flows.MakeExecutionListHandler(executions)},
+		{path: "/system/flows/{name:[" + faasProvider.NameExpression + "]+}/executions/{id}", method: http.MethodGet, handler: flows.MakeExecutionHandler(executions)},
+		{path: "/system/cache/orchestrator", method: http.MethodGet, handler: orchestrator.MakeStatusHandler(cacheOrchestrator)},
+		{path: "/system/cache/orchestrator/pin", method: http.MethodPost, handler: orchestrator.MakePinHandler(cacheOrchestrator)},
+		{path: "/system/cache/orchestrator/pin", method: http.MethodDelete, handler: orchestrator.MakeUnpinHandler(cacheOrchestrator)},
+		{path: "/system/cache/orchestrator/pause", method: http.MethodPost, handler: orchestrator.MakePauseHandler(cacheOrchestrator)},
+		{path: "/system/cache/orchestrator/resume", method: http.MethodPost, handler: orchestrator.MakeResumeHandler(cacheOrchestrator)},
+		{path: "/system/cache/orchestrator/evaluate", method: http.MethodPost, handler: orchestrator.MakeEvaluateHandler(cacheOrchestrator)},
+		{path: "/system/cache/orchestrator/decisions", method: http.MethodGet, handler: orchestrator.MakeDecisionsHandler(cacheOrchestrator)},
+	})
+
+	// Open endpoint, in the same way as /flow
+	asyncFlowHandler := flows.MakeAsyncHandler(asyncFlows, flowLookup)
+	faasProvider.Router().HandleFunc("/async-flow/{name:["+faasProvider.NameExpression+"]+}", asyncFlowHandler).Methods(http.MethodPost)
+	faasProvider.Router().HandleFunc("/async-flow/{name:["+faasProvider.NameExpression+"]+}/", asyncFlowHandler).Methods(http.MethodPost)
+	faasProvider.Router().HandleFunc("/async-flow/{name:["+faasProvider.NameExpression+"]+}/{params:.*}", asyncFlowHandler).Methods(http.MethodPost)
 
 	faasProvider.Serve(ctx, &bootstrapHandlers, &config.FaaSConfig)
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+
+	if err := tracing.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error sending queued spans: %s", err.Error())
+	}
+	if err := cacheTrace.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error writing queued cache trace records: %s", err.Error())
+	}
+}
+
+// systemRoute is an endpoint which is not part of the provider's
+// FaaSHandlers
+type systemRoute struct {
+	path    string
+	method  string
+	handler http.HandlerFunc
+}
+
+// registerSystemRoutes adds routes to the provider's router, decorated with
+// basic auth in the same way as the provider's own /system endpoints
+func registerSystemRoutes(config providertypes.FaaSConfig, routes []systemRoute) {
+	var credentials *auth.BasicAuthCredentials
+	if config.EnableBasicAuth {
+		reader := auth.ReadBasicAuthFromDisk{
+			SecretMountPath: config.SecretMountPath,
+		}
+
+		var err error
+		credentials, err = reader.Read()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	for _, route := range routes {
+		handler := route.handler
+		if credentials != nil {
+			handler = auth.DecorateWithBasicAuth(handler, credentials)
+		}
+
+		faasProvider.Router().HandleFunc(route.path, handler).Methods(route.method)
+	}
 }
 
 // serverSetup is a container for the config and clients needed to start the
 // faas-netes controller or operator
 type serverSetup struct {
 	config              config.BootstrapConfig
-	flows               providertypes.Flows
 	kubeClient          *kubernetes.Clientset
 	faasClient          *clientset.Clientset
-	cacheClient         providertypes.CacheClient
+	cacheClient         caching.Client
 	functionFactory     k8s.FunctionFactory
 	kubeInformerFactory kubeinformers.SharedInformerFactory
 	faasInformerFactory informers.SharedInformerFactory
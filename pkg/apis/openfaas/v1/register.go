@@ -37,6 +37,8 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		&FunctionList{},
 		&Profile{},
 		&ProfileList{},
+		&Flow{},
+		&FlowList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
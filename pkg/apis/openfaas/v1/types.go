@@ -217,3 +217,255 @@ type ProfileList struct {
 
 	Items []Profile `json:"items"`
 }
+
+// +genclient
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:printcolumn:name="Caching",type=boolean,JSONPath=`.spec.caching`
+// +kubebuilder:printcolumn:name="TTL",type=integer,JSONPath=`.spec.cacheTTL`
+
+// Flow describes a composition of OpenFaaS functions which is executed by
+// the flow proxy. The name of the Flow is the name of the function which is
+// invoked once all of its children have returned.
+type Flow struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec FlowSpec `json:"spec"`
+}
+
+// FlowSpec is the spec for a Flow resource
+type FlowSpec struct {
+	// Args lists the arguments accepted by the flow
+	// +optional
+	Args []string `json:"args,omitempty"`
+
+	// Children are invoked before the flow's own function, their
+	// responses are passed to it keyed by alias
+	// +optional
+	Children map[string]FlowChild `json:"children,omitempty"`
+
+	// MaxConcurrency limits how many children are invoked at the same
+	// time, all children are invoked at once when unset
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MaxConcurrency int32 `json:"maxConcurrency,omitempty"`
+
+	// Caching enables caching of the flow's response
+	// +optional
+	Caching bool `json:"caching,omitempty"`
+
+	// CacheTTL is the number of seconds a cached response is kept for, it is
+	// required when Caching is enabled
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	CacheTTL int32 `json:"cacheTTL,omitempty"`
+
+	// StaleTTL is the number of seconds after CacheTTL during which an
+	// expired response is still served, while a fresh response is fetched
+	// in the background
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	StaleTTL int32 `json:"staleTTL,omitempty"`
+
+	// CacheStatusCodes lists the status codes of responses which are
+	// cached, only 2xx responses are cached when unset
+	// +optional
+	CacheStatusCodes []int32 `json:"cacheStatusCodes,omitempty"`
+
+	// CacheKey selects the parts of a request which identify a cached
+	// response, only the args are used when unset
+	// +optional
+	CacheKey *FlowCacheKey `json:"cacheKey,omitempty"`
+
+	// IsThirdParty marks a flow which is served by an external API
+	// rather than by an OpenFaaS function
+	// +optional
+	IsThirdParty bool `json:"isThirdParty,omitempty"`
+
+	// ThirdPartyURL is the address of the external API, required when
+	// IsThirdParty is set
+	// +optional
+	ThirdPartyURL *string `json:"thirdPartyURL,omitempty"`
+
+	// ThirdParty customises the request made to ThirdPartyURL, which is
+	// otherwise a POST of the args as JSON
+	// +optional
+	ThirdParty *ThirdPartyRequest `json:"thirdParty,omitempty"`
+}
+
+// ThirdPartyRequest describes the request made to a third-party API.
+// Templates use Go's text/template syntax, with the flow's args available
+// as .args and a json function to encode a value, for example:
+// {"city": {{ json .args.city }}}
+type ThirdPartyRequest struct {
+	// Method is the HTTP method, defaults to POST
+	// +optional
+	// +kubebuilder:validation:Enum=GET;POST;PUT;PATCH;DELETE
+	Method string `json:"method,omitempty"`
+
+	// Query maps query string keys to templates, which are added to the
+	// query string of ThirdPartyURL. A GET has no body, so the args of a
+	// flow which uses GET are only sent through Query.
+	// +optional
+	Query map[string]string `json:"query,omitempty"`
+
+	// Headers maps request headers to templates. Credentials must not be
+	// set here, use Auth instead
+	// +optional
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Auth adds credentials read from an OpenFaaS secret
+	// +optional
+	Auth *ThirdPartyAuth `json:"auth,omitempty"`
+
+	// RequestTemplate renders the request body, the args are sent as JSON
+	// when unset. No body is sent for a GET
+	// +optional
+	RequestTemplate string `json:"requestTemplate,omitempty"`
+
+	// ResponseTemplate renders the child's output from the API's response,
+	// which is available as .body, decoded when it is JSON, along with
+	// .status and .header
+	// +optional
+	ResponseTemplate string `json:"responseTemplate,omitempty"`
+}
+
+// ThirdPartyAuth authenticates requests to a third-party API with a
+// credential read from an OpenFaaS secret in the namespace of the flows
+type ThirdPartyAuth struct {
+	// Type is bearer, to send the secret as a bearer token, or basic, for
+	// a secret in the form username:password
+	Type ThirdPartyAuthType `json:"type"`
+
+	// Secret is the name of the OpenFaaS secret
+	Secret string `json:"secret"`
+}
+
+// ThirdPartyAuthType is the scheme used to authenticate to a third-party API
+// +kubebuilder:validation:Enum=bearer;basic
+type ThirdPartyAuthType string
+
+const (
+	// ThirdPartyAuthBearer sends an Authorization: Bearer header
+	ThirdPartyAuthBearer ThirdPartyAuthType = "bearer"
+	// ThirdPartyAuthBasic sends an Authorization: Basic header
+	ThirdPartyAuthBasic ThirdPartyAuthType = "basic"
+)
+
+// FlowCacheKey selects the parts of a request which identify a cached
+// response of a flow
+type FlowCacheKey struct {
+	// Args lists the args included in the key, all args are included when
+	// unset
+	// +optional
+	Args []string `json:"args,omitempty"`
+
+	// Headers lists the request headers included in the key, such as a
+	// tenant header or Accept-Language
+	// +optional
+	Headers []string `json:"headers,omitempty"`
+
+	// Query lists the query string keys included in the key
+	// +optional
+	Query []string `json:"query,omitempty"`
+
+	// Path includes the sub-path which follows the flow's name in the key
+	// +optional
+	Path bool `json:"path,omitempty"`
+
+	// PathParts lists the segments of the sub-path included in the key, by
+	// their 0-based index once split on "/", for when only part of the
+	// sub-path selects the response. It is not used when Path is set.
+	// +optional
+	PathParts []int32 `json:"pathParts,omitempty"`
+
+	// Children lists the aliases of children whose outputs are included in
+	// the key. Those children are invoked before the cache is read, so that
+	// a cached response is only used while they return the same output, and
+	// only the flow's own function is skipped on a hit.
+	// +optional
+	Children []string `json:"children,omitempty"`
+}
+
+// FlowChild is an edge from a flow to another flow
+type FlowChild struct {
+	// Function is the name of the Flow which is invoked
+	Function string `json:"function"`
+
+	// ArgsMap maps the child's arguments to the parent's arguments,
+	// in the form of child argument: parent argument. A path to the
+	// output of a sibling, such as children.user.id, may be used in place
+	// of a parent argument, the sibling is then invoked first
+	// +optional
+	ArgsMap map[string]string `json:"argsMap,omitempty"`
+
+	// DependsOn lists the siblings which must complete before the child is
+	// invoked, the child is skipped when any of them is skipped
+	// +optional
+	DependsOn []string `json:"dependsOn,omitempty"`
+
+	// OutputMap sets args from the child's output, in the form of
+	// argument: selector. Selectors are JSONPath-style, such as $.user.id
+	// or $.items[0].name. The args are passed to the parent's function
+	// along with its own, and a sibling which uses one in its ArgsMap or
+	// When condition is invoked after this child
+	// +optional
+	OutputMap map[string]string `json:"outputMap,omitempty"`
+
+	// When is a condition which must be true for the child to be invoked,
+	// for example "args.amount > 1000". Paths start with args, for the
+	// parent's args, or children, for the output of a sibling, which is
+	// then invoked first. Skipped children are listed in the flow's input
+	// +optional
+	When string `json:"when,omitempty"`
+
+	// Timeout limits each attempt to call the child, the flow proxy's
+	// default timeout applies when unset, and 30s for a third-party API
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// Retries is the number of times a failed call to the child is retried
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=10
+	Retries int32 `json:"retries,omitempty"`
+
+	// RetryBackoff is the delay before the first retry, which doubles on
+	// each further retry. Defaults to 100ms
+	// +optional
+	RetryBackoff *metav1.Duration `json:"retryBackoff,omitempty"`
+
+	// OnError decides what happens when the child still fails after its
+	// retries. Defaults to fail
+	// +optional
+	OnError ChildErrorPolicy `json:"onError,omitempty"`
+
+	// Fallback is passed to the flow as the child's output when OnError
+	// is fallback
+	// +optional
+	Fallback string `json:"fallback,omitempty"`
+}
+
+// ChildErrorPolicy is the action taken when a child of a flow fails
+// +kubebuilder:validation:Enum=fail;skip;fallback
+type ChildErrorPolicy string
+
+const (
+	// ChildErrorFail fails the whole flow
+	ChildErrorFail ChildErrorPolicy = "fail"
+	// ChildErrorSkip leaves the child out of the flow's input
+	ChildErrorSkip ChildErrorPolicy = "skip"
+	// ChildErrorFallback passes the child's Fallback to the flow instead
+	ChildErrorFallback ChildErrorPolicy = "fallback"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// FlowList is a list of Flow resources
+type FlowList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []Flow `json:"items"`
+}
@@ -0,0 +1,146 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+// Package cachesim simulates eviction policies in-process, so that a trace
+// of cache reads can be replayed against each of them without a cache
+// server.
+package cachesim
+
+import (
+	"container/list"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Cache is a simulated cache with a fixed capacity
+type Cache interface {
+	// Access reads key at now, returning true for a hit. On a miss the
+	// value is stored, as the flow stores the response it fetches.
+	Access(key string, size int64, ttl time.Duration, now time.Time) bool
+	// Len returns the number of entries in the cache
+	Len() int
+}
+
+// policy orders the entries of a cache for eviction
+type policy interface {
+	// inserted is called when e is stored
+	inserted(e *entry)
+	// hit is called when e is read
+	hit(e *entry)
+	// victim returns the next entry to evict
+	victim() *entry
+	// removed is called when e is evicted or has expired
+	removed(e *entry)
+}
+
+// constructors of the policies by name, the names are those of PaperCache
+var constructors = map[string]func() policy{
+	"lru":   func() policy { return &lru{order: list.New()} },
+	"fifo":  func() policy { return &fifo{order: list.New()} },
+	"lfu":   func() policy { return &lfu{} },
+	"clock": func() policy { return &clock{ring: list.New()} },
+	"sieve": func() policy { return &sieve{order: list.New()} },
+}
+
+// Policies returns the names of the policies which can be simulated
+func Policies() []string {
+	names := make([]string, 0, len(constructors))
+	for name := range constructors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New creates a cache which evicts with the named policy once the cost of
+// its entries exceeds capacity. When bySize is true the cost of an entry
+// is its size in bytes, otherwise each entry costs 1.
+func New(name string, capacity int64, bySize bool) (Cache, error) {
+	constructor, ok := constructors[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown policy: %s", name)
+	}
+	if capacity <= 0 {
+		return nil, fmt.Errorf("capacity must be greater than zero")
+	}
+
+	return &cache{
+		policy:   constructor(),
+		capacity: capacity,
+		bySize:   bySize,
+		entries:  map[string]*entry{},
+	}, nil
+}
+
+// entry is a value stored in a cache, the fields after cost are used by
+// the policies
+type entry struct {
+	key     string
+	cost    int64
+	expires time.Time
+
+	element   *list.Element
+	frequency int
+	sequence  uint64
+	index     int
+	visited   bool
+}
+
+type cache struct {
+	policy   policy
+	capacity int64
+	bySize   bool
+
+	entries  map[string]*entry
+	used     int64
+	sequence uint64
+}
+
+func (c *cache) Access(key string, size int64, ttl time.Duration, now time.Time) bool {
+	c.sequence++
+
+	if e, ok := c.entries[key]; ok {
+		if e.expires.IsZero() || now.Before(e.expires) {
+			e.sequence = c.sequence
+			c.policy.hit(e)
+			return true
+		}
+		c.remove(e)
+	}
+
+	cost := int64(1)
+	if c.bySize {
+		cost = size
+		if cost < 1 {
+			cost = 1
+		}
+	}
+	if cost > c.capacity {
+		return false
+	}
+
+	for c.used+cost > c.capacity {
+		c.remove(c.policy.victim())
+	}
+
+	e := &entry{key: key, cost: cost, sequence: c.sequence}
+	if ttl > 0 {
+		e.expires = now.Add(ttl)
+	}
+	c.entries[key] = e
+	c.used += cost
+	c.policy.inserted(e)
+
+	return false
+}
+
+func (c *cache) Len() int {
+	return len(c.entries)
+}
+
+func (c *cache) remove(e *entry) {
+	c.policy.removed(e)
+	delete(c.entries, e.key)
+	c.used -= e.cost
+}
@@ -0,0 +1,94 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package cachesim
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Policies_Evict(t *testing.T) {
+	cases := []struct {
+		policy string
+		reads  string
+		want   string
+	}{
+		// b is the least recently read
+		{"lru", "a b a c b a", "MMHMMM"},
+		// a was stored first, reading it does not keep it
+		{"fifo", "a b a c b a", "MMHMHM"},
+		// b is read least often, then c as the least recent of the keys
+		// read once
+		{"lfu", "a b a c d a c", "MMHMMHM"},
+		// a has its second chance, b does not
+		{"clock", "a b a c a b", "MMHMHM"},
+		{"sieve", "a b a c a b", "MMHMHM"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.policy, func(t *testing.T) {
+			cache, err := New(tc.policy, 2, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			now := time.Unix(1700000000, 0)
+			got := ""
+			for _, key := range strings.Fields(tc.reads) {
+				if cache.Access(key, 1, 0, now) {
+					got += "H"
+				} else {
+					got += "M"
+				}
+			}
+
+			if got != tc.want {
+				t.Errorf("want %s, got %s", tc.want, got)
+			}
+			if cache.Len() != 2 {
+				t.Errorf("want 2 entries, got %d", cache.Len())
+			}
+		})
+	}
+}
+
+func Test_Cache_Expires(t *testing.T) {
+	cache, _ := New("lru", 10, false)
+	now := time.Unix(1700000000, 0)
+
+	cache.Access("a", 1, time.Minute, now)
+	if !cache.Access("a", 1, time.Minute, now.Add(59*time.Second)) {
+		t.Errorf("want a hit before the TTL")
+	}
+	if cache.Access("a", 1, time.Minute, now.Add(time.Minute)) {
+		t.Errorf("want a miss after the TTL")
+	}
+	if cache.Len() != 1 {
+		t.Errorf("want the expired value to be replaced, got %d entries", cache.Len())
+	}
+}
+
+func Test_Cache_BySize(t *testing.T) {
+	cache, _ := New("lru", 100, true)
+	now := time.Unix(1700000000, 0)
+
+	cache.Access("a", 60, 0, now)
+	cache.Access("b", 30, 0, now)
+	cache.Access("c", 20, 0, now)
+	if cache.Len() != 2 || cache.Access("a", 60, 0, now) {
+		t.Errorf("want a to be evicted to make room for c")
+	}
+
+	cache.Access("huge", 200, 0, now)
+	if cache.Access("huge", 200, 0, now) {
+		t.Errorf("want a value larger than the cache not to be stored")
+	}
+}
+
+func Test_New_UnknownPolicy(t *testing.T) {
+	if _, err := New("arc", 10, false); err == nil {
+		t.Errorf("want an error for a policy which cannot be simulated")
+	}
+}
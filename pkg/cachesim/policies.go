@@ -0,0 +1,157 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package cachesim
+
+import (
+	"container/heap"
+	"container/list"
+)
+
+// lru evicts the entry which was read least recently
+type lru struct {
+	order *list.List
+}
+
+func (p *lru) inserted(e *entry) { e.element = p.order.PushFront(e) }
+func (p *lru) hit(e *entry)      { p.order.MoveToFront(e.element) }
+func (p *lru) victim() *entry    { return p.order.Back().Value.(*entry) }
+func (p *lru) removed(e *entry)  { p.order.Remove(e.element) }
+
+// fifo evicts the entry which was stored first, reads do not change the
+// order
+type fifo struct {
+	order *list.List
+}
+
+func (p *fifo) inserted(e *entry) { e.element = p.order.PushFront(e) }
+func (p *fifo) hit(e *entry)      {}
+func (p *fifo) victim() *entry    { return p.order.Back().Value.(*entry) }
+func (p *fifo) removed(e *entry)  { p.order.Remove(e.element) }
+
+// lfu evicts the entry which was read least often, the least recent of
+// them on a tie
+type lfu struct {
+	entries []*entry
+}
+
+func (p *lfu) inserted(e *entry) {
+	e.frequency = 1
+	heap.Push(p, e)
+}
+
+func (p *lfu) hit(e *entry) {
+	e.frequency++
+	heap.Fix(p, e.index)
+}
+
+func (p *lfu) victim() *entry   { return p.entries[0] }
+func (p *lfu) removed(e *entry) { heap.Remove(p, e.index) }
+
+// heap.Interface
+func (p *lfu) Len() int { return len(p.entries) }
+func (p *lfu) Less(i, j int) bool {
+	a, b := p.entries[i], p.entries[j]
+	if a.frequency != b.frequency {
+		return a.frequency < b.frequency
+	}
+	return a.sequence < b.sequence
+}
+func (p *lfu) Swap(i, j int) {
+	p.entries[i], p.entries[j] = p.entries[j], p.entries[i]
+	p.entries[i].index = i
+	p.entries[j].index = j
+}
+func (p *lfu) Push(x interface{}) {
+	e := x.(*entry)
+	e.index = len(p.entries)
+	p.entries = append(p.entries, e)
+}
+func (p *lfu) Pop() interface{} {
+	e := p.entries[len(p.entries)-1]
+	p.entries = p.entries[:len(p.entries)-1]
+	return e
+}
+
+// clock gives each entry which was read since the hand last passed it a
+// second chance, the hand moves from the oldest entry to the newest
+type clock struct {
+	ring *list.List
+	hand *list.Element
+}
+
+func (p *clock) inserted(e *entry) {
+	// new entries are placed just behind the hand, so that they are the
+	// last it reaches
+	if p.hand == nil {
+		e.element = p.ring.PushBack(e)
+		return
+	}
+	e.element = p.ring.InsertBefore(e, p.hand)
+}
+
+func (p *clock) hit(e *entry) { e.visited = true }
+
+func (p *clock) victim() *entry {
+	if p.hand == nil {
+		p.hand = p.ring.Front()
+	}
+	for {
+		e := p.hand.Value.(*entry)
+		if !e.visited {
+			return e
+		}
+		e.visited = false
+		p.advance()
+	}
+}
+
+func (p *clock) removed(e *entry) {
+	if p.hand == e.element {
+		p.advance()
+		if p.hand == e.element {
+			p.hand = nil
+		}
+	}
+	p.ring.Remove(e.element)
+}
+
+func (p *clock) advance() {
+	if p.hand = p.hand.Next(); p.hand == nil {
+		p.hand = p.ring.Front()
+	}
+}
+
+// sieve keeps entries in the order they were stored and moves a hand from
+// the oldest to the newest, evicting the first which was not read since
+// the hand last passed it
+type sieve struct {
+	order *list.List
+	hand  *list.Element
+}
+
+func (p *sieve) inserted(e *entry) { e.element = p.order.PushFront(e) }
+func (p *sieve) hit(e *entry)      { e.visited = true }
+
+func (p *sieve) victim() *entry {
+	if p.hand == nil {
+		p.hand = p.order.Back()
+	}
+	for {
+		e := p.hand.Value.(*entry)
+		if !e.visited {
+			return e
+		}
+		e.visited = false
+		if p.hand = p.hand.Prev(); p.hand == nil {
+			p.hand = p.order.Back()
+		}
+	}
+}
+
+func (p *sieve) removed(e *entry) {
+	if p.hand == e.element {
+		p.hand = e.element.Prev()
+	}
+	p.order.Remove(e.element)
+}
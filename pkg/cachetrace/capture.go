@@ -0,0 +1,193 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package cachetrace
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/openfaas/faas-netes/pkg/config"
+)
+
+const (
+	// captureQueueSize is the number of records which wait to be written,
+	// further records are dropped until the sink catches up
+	captureQueueSize = 4096
+	// captureBatchSize is the largest number of records written at once
+	captureBatchSize = 512
+	// captureInterval is the longest time a record waits to be written
+	captureInterval = 5 * time.Second
+
+	// sampleBuckets is the resolution of the sample rate
+	sampleBuckets = 1000000
+)
+
+// Sink receives the records of a trace in batches, in the order they were
+// captured
+type Sink interface {
+	WriteRecords(records []Record) error
+	Close() error
+}
+
+// NewSink creates the sink configured by cfg, nil is returned when the
+// sink is none
+func NewSink(cfg config.CacheTraceConfig) (Sink, error) {
+	switch cfg.Sink {
+	case "", "none":
+		return nil, nil
+	case "file":
+		return NewFileSink(cfg.Path, cfg.MaxFileBytes, cfg.MaxFiles)
+	case "http":
+		u, err := url.Parse(cfg.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			return nil, fmt.Errorf("the cache trace URL must be an http or https URL, got %q", cfg.URL)
+		}
+		return NewHTTPSink(cfg.URL, cfg.Headers), nil
+	}
+	return nil, fmt.Errorf("unknown cache trace sink %q", cfg.Sink)
+}
+
+// HashKey returns the key of a Record for a cache key, so that the trace
+// identifies an entry without carrying the inputs it was derived from
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Recorder captures reads of the cache without blocking them, sampling
+// them by key and writing them to a Sink in batches. A nil recorder
+// captures nothing.
+type Recorder struct {
+	sink Sink
+	// threshold selects the keys whose hash falls below it, out of
+	// sampleBuckets
+	threshold uint64
+
+	records chan Record
+	flushes chan chan struct{}
+
+	lock    sync.Mutex
+	dropped int
+}
+
+// NewRecorder creates a recorder which captures every read of the given
+// fraction of keys. Sampling by key keeps the whole sequence of reads of
+// each sampled entry, which is what an eviction policy acts on.
+func NewRecorder(sink Sink, sampleRate float64) *Recorder {
+	r := &Recorder{
+		sink:      sink,
+		threshold: uint64(sampleRate * sampleBuckets),
+		records:   make(chan Record, captureQueueSize),
+		flushes:   make(chan chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// Sampled returns true when reads of key, as given to Record, are
+// captured
+func (r *Recorder) Sampled(key string) bool {
+	if r == nil {
+		return false
+	}
+	if r.threshold >= sampleBuckets {
+		return true
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()%sampleBuckets < r.threshold
+}
+
+// Record queues a read to be written, record.Key is the hashed key. Reads
+// of keys which are not sampled are ignored, and reads which do not fit in
+// the queue are dropped and counted.
+func (r *Recorder) Record(record Record) {
+	if !r.Sampled(record.Key) {
+		return
+	}
+
+	select {
+	case r.records <- record:
+	default:
+		r.lock.Lock()
+		r.dropped++
+		r.lock.Unlock()
+	}
+}
+
+// Shutdown writes the queued records and closes the sink, waiting until
+// they are written or ctx is done
+func (r *Recorder) Shutdown(ctx context.Context) error {
+	if r == nil {
+		return nil
+	}
+
+	done := make(chan struct{})
+	select {
+	case r.flushes <- done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-done:
+		return r.sink.Close()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run writes a batch when it is full, on each interval and when flushed
+func (r *Recorder) run() {
+	ticker := time.NewTicker(captureInterval)
+	defer ticker.Stop()
+
+	batch := make([]Record, 0, captureBatchSize)
+	write := func() {
+		if len(batch) > 0 {
+			if err := r.sink.WriteRecords(batch); err != nil {
+				log.Printf("error writing %d cache trace records: %s", len(batch), err.Error())
+			}
+			batch = batch[:0]
+		}
+
+		r.lock.Lock()
+		dropped := r.dropped
+		r.dropped = 0
+		r.lock.Unlock()
+		if dropped > 0 {
+			log.Printf("dropped %d cache trace records, the queue was full", dropped)
+		}
+	}
+
+	for {
+		select {
+		case record := <-r.records:
+			batch = append(batch, record)
+			if len(batch) == captureBatchSize {
+				write()
+			}
+		case <-ticker.C:
+			write()
+		case done := <-r.flushes:
+			for queued := len(r.records); queued > 0; queued-- {
+				batch = append(batch, <-r.records)
+				if len(batch) == captureBatchSize {
+					write()
+				}
+			}
+			write()
+			close(done)
+			return
+		}
+	}
+}
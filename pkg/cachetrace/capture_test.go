@@ -0,0 +1,208 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package cachetrace
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/openfaas/faas-netes/pkg/config"
+)
+
+// readTrace reads every record of the trace at path
+func readTrace(t *testing.T, path string) []Record {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	records := []Record{}
+	reader := NewReader(file)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return records
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+}
+
+func testRecord(i int) Record {
+	return Record{
+		Time: time.Date(2024, 5, 1, 10, 0, i, 0, time.UTC),
+		Key:  HashKey(fmt.Sprintf("key-%d", i)),
+		Flow: "checkout",
+		Size: 512,
+		TTL:  60,
+	}
+}
+
+func Test_HashKey(t *testing.T) {
+	key := HashKey("flow:checkout:{\"id\":1}")
+	if len(key) != 16 || key != HashKey("flow:checkout:{\"id\":1}") || key == HashKey("flow:checkout:{\"id\":2}") {
+		t.Fatalf("want a stable hash of 16 characters, got: %s", key)
+	}
+}
+
+func Test_Recorder_Sampled(t *testing.T) {
+	var nilRecorder *Recorder
+	if nilRecorder.Sampled("a") {
+		t.Fatalf("want a nil recorder to capture nothing")
+	}
+
+	recorder := &Recorder{threshold: uint64(0.25 * sampleBuckets)}
+	sampled := 0
+	for i := 0; i < 10000; i++ {
+		key := HashKey(fmt.Sprintf("key-%d", i))
+		if recorder.Sampled(key) {
+			sampled++
+		}
+		if recorder.Sampled(key) != recorder.Sampled(key) {
+			t.Fatalf("want the same keys to be sampled every time")
+		}
+	}
+	if sampled < 2250 || sampled > 2750 {
+		t.Fatalf("want about a quarter of the keys to be sampled, got: %d", sampled)
+	}
+}
+
+func Test_Recorder_ShutdownWritesQueued(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.jsonl")
+	sink, err := NewSink(config.CacheTraceConfig{Sink: "file", Path: path, MaxFileBytes: 1024 * 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := NewRecorder(sink, 1)
+	for i := 0; i < 1000; i++ {
+		recorder.Record(testRecord(i))
+	}
+	if err := recorder.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	records := readTrace(t, path)
+	if len(records) != 1000 {
+		t.Fatalf("want 1000 records, got: %d", len(records))
+	}
+	if records[0] != testRecord(0) || records[999] != testRecord(999) {
+		t.Fatalf("want the records in order, got: %+v and %+v", records[0], records[999])
+	}
+}
+
+func Test_FileSink_Rotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.jsonl")
+
+	sink, err := NewFileSink(path, 1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if err := sink.WriteRecords([]Record{testRecord(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatalf("want %s to be kept: %s", name, err)
+		}
+		if info.Size() > 1024 {
+			t.Errorf("want %s to be at most 1024 bytes, got: %d", name, info.Size())
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("want only 2 rotated files to be kept")
+	}
+
+	current := readTrace(t, path)
+	if last := current[len(current)-1]; last != testRecord(99) {
+		t.Fatalf("want the latest record in the current file, got: %+v", last)
+	}
+	previous := readTrace(t, path+".1")
+	if next := previous[len(previous)-1].Time.Add(time.Second); !next.Equal(current[0].Time) {
+		t.Fatalf("want the rotated file to end where the current one starts")
+	}
+}
+
+func Test_FileSink_Appends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.jsonl")
+
+	for i := 0; i < 2; i++ {
+		sink, err := NewFileSink(path, 1024*1024, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sink.WriteRecords([]Record{testRecord(i)})
+		sink.Close()
+	}
+
+	if records := readTrace(t, path); len(records) != 2 {
+		t.Fatalf("want the file to be appended to on restart, got %d records", len(records))
+	}
+}
+
+func Test_HTTPSink(t *testing.T) {
+	var body []byte
+	var contentType, authorization string
+	status := http.StatusAccepted
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		contentType = r.Header.Get("Content-Type")
+		authorization = r.Header.Get("Authorization")
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink, err := NewSink(config.CacheTraceConfig{Sink: "http", URL: server.URL, Headers: map[string]string{"Authorization": "Bearer abc"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.WriteRecords([]Record{testRecord(0), testRecord(1)}); err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "application/x-ndjson" || authorization != "Bearer abc" {
+		t.Fatalf("unexpected headers: %s %s", contentType, authorization)
+	}
+
+	path := filepath.Join(t.TempDir(), "batch.jsonl")
+	os.WriteFile(path, body, 0600)
+	if records := readTrace(t, path); len(records) != 2 || records[1] != testRecord(1) {
+		t.Fatalf("want the batch as JSON Lines, got: %s", body)
+	}
+
+	status = http.StatusInternalServerError
+	if err := sink.WriteRecords([]Record{testRecord(2)}); err == nil {
+		t.Fatalf("want an error for a status of %d", status)
+	}
+}
+
+func Test_NewSink(t *testing.T) {
+	if sink, err := NewSink(config.CacheTraceConfig{Sink: "none"}); sink != nil || err != nil {
+		t.Fatalf("want no sink for none, got: %v %v", sink, err)
+	}
+	for _, cfg := range []config.CacheTraceConfig{
+		{Sink: "kafka"},
+		{Sink: "http", URL: "traces.example.com"},
+	} {
+		if _, err := NewSink(cfg); err == nil {
+			t.Errorf("want an error for %+v", cfg)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+// Package cachetrace defines the trace of flow cache reads, the same reads
+// which are fed to the orchestrator's RecordAccess, so that they can be
+// replayed offline against other eviction policies.
+//
+// A trace is JSON Lines, one Record per line, in the order of the reads:
+//
+//	{"ts":"2024-05-01T10:00:00.123Z","key":"9f86d081884c7d65","flow":"checkout","hit":false,"size":512,"ttl":60}
+//	{"ts":"2024-05-01T10:00:00.150Z","key":"9f86d081884c7d65","flow":"checkout","hit":true,"size":512,"ttl":60}
+//
+// Blank lines are ignored.
+//
+// The provider captures a trace when cache_trace is set to file or http. A
+// Recorder samples the reads by key, so that every read of a sampled entry
+// is kept, and writes them to a Sink in batches. The file sink rotates the
+// file once it reaches cache_trace_max_file_bytes, and the http sink POSTs
+// each batch as JSON Lines. Either can be read back with NewReader.
+package cachetrace
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// maxLine is the longest line accepted by a Reader
+const maxLine = 64 * 1024
+
+// Record is one read of the cache
+type Record struct {
+	// Time of the read, required
+	Time time.Time `json:"ts"`
+	// Key identifies the cache entry, it is hashed so that the trace does
+	// not carry the inputs of a flow. Required.
+	Key string `json:"key"`
+	// Flow is the flow, or the node of a flow, whose response is cached
+	Flow string `json:"flow,omitempty"`
+	// Hit is true when the entry was found and was fresh or stale
+	Hit bool `json:"hit"`
+	// Size is the number of bytes of the cached value, when known
+	Size int `json:"size,omitempty"`
+	// TTL is the number of seconds for which the value is cached, including
+	// any stale period, zero when unknown
+	TTL int `json:"ttl,omitempty"`
+}
+
+// Reader reads the records of a trace
+type Reader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewReader reads a trace from r
+func NewReader(r io.Reader) *Reader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 4096), maxLine)
+
+	return &Reader{scanner: scanner}
+}
+
+// Read returns the next record, or io.EOF at the end of the trace
+func (r *Reader) Read() (Record, error) {
+	for r.scanner.Scan() {
+		r.line++
+
+		line := strings.TrimSpace(r.scanner.Text())
+		if len(line) == 0 {
+			continue
+		}
+
+		record := Record{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			return record, fmt.Errorf("line %d: %w", r.line, err)
+		}
+		if len(record.Key) == 0 {
+			return record, fmt.Errorf("line %d: key is required", r.line)
+		}
+		if record.Time.IsZero() {
+			return record, fmt.Errorf("line %d: ts is required", r.line)
+		}
+		return record, nil
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return Record{}, fmt.Errorf("line %d: %w", r.line+1, err)
+	}
+	return Record{}, io.EOF
+}
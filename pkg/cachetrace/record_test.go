@@ -0,0 +1,53 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package cachetrace
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func Test_Reader(t *testing.T) {
+	trace := `{"ts":"2024-05-01T10:00:00Z","key":"9f86d081884c7d65","flow":"checkout","hit":false,"size":512,"ttl":60}
+
+{"ts":"2024-05-01T10:00:01Z","key":"9f86d081884c7d65","hit":true}
+`
+	reader := NewReader(strings.NewReader(trace))
+
+	first, err := reader.Read()
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err)
+	}
+	if first.Key != "9f86d081884c7d65" || first.Flow != "checkout" || first.Hit || first.Size != 512 || first.TTL != 60 {
+		t.Errorf("unexpected record: %+v", first)
+	}
+
+	second, err := reader.Read()
+	if err != nil || !second.Hit || second.Time.Sub(first.Time).Seconds() != 1 {
+		t.Errorf("unexpected record: %+v %v", second, err)
+	}
+
+	if _, err := reader.Read(); err != io.EOF {
+		t.Errorf("want io.EOF, got: %v", err)
+	}
+}
+
+func Test_Reader_Invalid(t *testing.T) {
+	cases := map[string]string{
+		"not JSON":   `{"ts":`,
+		"no key":     `{"ts":"2024-05-01T10:00:00Z","hit":true}`,
+		"no time":    `{"key":"a","hit":true}`,
+		"wrong type": `{"ts":"2024-05-01T10:00:00Z","key":"a","hit":"yes"}`,
+	}
+
+	for name, line := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewReader(strings.NewReader("\n" + line)).Read()
+			if err == nil || !strings.HasPrefix(err.Error(), "line 2:") {
+				t.Errorf("want an error for line 2, got: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package caching
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	paperClient "github.com/danenherdi/paper-client-go"
+	"github.com/redis/go-redis/v9"
+)
+
+func Test_escapePattern(t *testing.T) {
+	cases := map[string]string{
+		"flow:openfaas-fn:checkout:": "flow:openfaas-fn:checkout:",
+		"flow:*:[a]?":                `flow:\*:\[a\]\?`,
+		`back\slash^`:                `back\\slash\^`,
+	}
+
+	for value, want := range cases {
+		if got := escapePattern(value); got != want {
+			t.Errorf("want %q for %q, got: %q", want, value, got)
+		}
+	}
+}
+
+func Test_PaperCache_pruneLocked(t *testing.T) {
+	now := time.Now()
+	p := &PaperCache{keys: map[string]time.Time{}}
+
+	p.keys["expired"] = now.Add(-time.Second)
+	for i := 0; i < maxTrackedKeys+10; i++ {
+		p.keys[fmt.Sprintf("key-%d", i)] = now.Add(time.Duration(i+1) * time.Second)
+	}
+
+	p.pruneLocked(now)
+
+	if len(p.keys) != maxTrackedKeys {
+		t.Fatalf("want %d keys, got: %d", maxTrackedKeys, len(p.keys))
+	}
+	if _, ok := p.keys["expired"]; ok {
+		t.Fatalf("want expired key to be forgotten")
+	}
+	if _, ok := p.keys["key-0"]; ok {
+		t.Fatalf("want the keys which expire soonest to be forgotten")
+	}
+	if _, ok := p.keys[fmt.Sprintf("key-%d", maxTrackedKeys+9)]; !ok {
+		t.Fatalf("want the keys which expire last to be kept")
+	}
+}
+
+func Test_IsNotFound(t *testing.T) {
+	if !IsNotFound(ErrNotFound) || !IsNotFound(redis.Nil) || !IsNotFound(paperClient.PaperErrorKeyNotFound) {
+		t.Fatalf("want the not found error of each backend to be recognised")
+	}
+	if IsNotFound(errors.New("connection refused")) {
+		t.Fatalf("want other errors not to be recognised")
+	}
+}
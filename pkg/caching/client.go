@@ -0,0 +1,42 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+// Package caching provides the cache backends used by the flow proxy. Each
+// backend extends the provider's types.CacheClient with the operations
+// needed to invalidate cached responses before their TTL runs out.
+package caching
+
+import (
+	"context"
+	"errors"
+
+	"github.com/danenherdi/faas-provider/types"
+	"github.com/redis/go-redis/v9"
+)
+
+// Client is a types.CacheClient whose entries can also be removed
+type Client interface {
+	types.CacheClient
+
+	// Del removes a single key and returns the number of keys removed,
+	// removing a key which does not exist is not an error
+	Del(ctx context.Context, key string) (int, error)
+
+	// DelPrefix removes every key which starts with prefix and returns
+	// the number of keys removed
+	DelPrefix(ctx context.Context, prefix string) (int, error)
+}
+
+// Wiper is a Client which can remove every entry at once. It is used to
+// purge a cache whose keys can not be listed, so that DelPrefix can not
+// reach them all.
+type Wiper interface {
+	// Wipe removes every entry and returns the number of entries removed
+	Wipe(ctx context.Context) (int, error)
+}
+
+// IsNotFound returns true when err is returned by Get for a key which is
+// not cached, rather than for a failure of the backend
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound) || errors.Is(err, redis.Nil) || isPaperNotFound(err)
+}
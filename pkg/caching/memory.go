@@ -0,0 +1,215 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package caching
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrNotFound is returned by Memory.Get for a key which is not cached or
+// which has expired
+var ErrNotFound = errors.New("key not found")
+
+// memoryEntry is an element of the LRU list of a Memory cache
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// size is the number of bytes an entry counts towards the size of the cache
+func (e *memoryEntry) size() int64 {
+	return int64(len(e.key) + len(e.value))
+}
+
+// Memory is a Client which keeps entries in the memory of the provider.
+// It is bounded by the total size of its keys and values and, optionally,
+// by the number of entries. The least recently used entries are evicted
+// to make room for new ones, and expired entries are removed when they
+// are read or evicted. Entries are not shared between replicas and are
+// lost on restart, so it is intended for development and small
+// installations.
+type Memory struct {
+	maxBytes   int64
+	maxEntries int
+
+	lock    sync.Mutex
+	entries map[string]*list.Element
+	// lru holds *memoryEntry, most recently used first
+	lru   *list.List
+	bytes int64
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
+
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// NewMemory creates an empty Memory cache which holds at most maxBytes of
+// keys and values and, when maxEntries is greater than zero, at most
+// maxEntries entries
+func NewMemory(maxBytes int64, maxEntries int) *Memory {
+	return &Memory{
+		maxBytes:   maxBytes,
+		maxEntries: maxEntries,
+		entries:    map[string]*list.Element{},
+		lru:        list.New(),
+		now:        time.Now,
+	}
+}
+
+// Get returns the value of key, or ErrNotFound
+func (m *Memory) Get(ctx context.Context, key string) ([]byte, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	element, ok := m.entries[key]
+	if ok && !element.Value.(*memoryEntry).expires.After(m.now()) {
+		m.removeLocked(element)
+		ok = false
+	}
+	if !ok {
+		m.misses++
+		return nil, ErrNotFound
+	}
+
+	m.hits++
+	m.lru.MoveToFront(element)
+	return element.Value.(*memoryEntry).value, nil
+}
+
+// SetEx stores value under key for ttl, evicting the least recently used
+// entries until it fits. A value which could never fit is an error.
+func (m *Memory) SetEx(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	entry := &memoryEntry{key: key, value: value, expires: m.now().Add(ttl)}
+	if entry.size() > m.maxBytes {
+		return fmt.Errorf("entry of %d bytes is larger than the cache size of %d bytes", entry.size(), m.maxBytes)
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if element, ok := m.entries[key]; ok {
+		m.removeLocked(element)
+	}
+
+	m.entries[key] = m.lru.PushFront(entry)
+	m.bytes += entry.size()
+
+	for m.bytes > m.maxBytes || (m.maxEntries > 0 && m.lru.Len() > m.maxEntries) {
+		m.removeLocked(m.lru.Back())
+		m.evictions++
+	}
+	return nil
+}
+
+// Del removes a single key, an expired entry is removed but not counted
+func (m *Memory) Del(ctx context.Context, key string) (int, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	element, ok := m.entries[key]
+	if !ok {
+		return 0, nil
+	}
+
+	deleted := 0
+	if element.Value.(*memoryEntry).expires.After(m.now()) {
+		deleted = 1
+	}
+	m.removeLocked(element)
+	return deleted, nil
+}
+
+// DelPrefix removes every key which starts with prefix, expired entries are
+// removed but not counted
+func (m *Memory) DelPrefix(ctx context.Context, prefix string) (int, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	now := m.now()
+	deleted := 0
+	for key, element := range m.entries {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if element.Value.(*memoryEntry).expires.After(now) {
+			deleted++
+		}
+		m.removeLocked(element)
+	}
+	return deleted, nil
+}
+
+// MemoryStats is a snapshot of the counters of a Memory cache
+type MemoryStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+	Bytes     int64
+}
+
+// Stats returns a snapshot of the cache's counters
+func (m *Memory) Stats() MemoryStats {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return MemoryStats{
+		Hits:      m.hits,
+		Misses:    m.misses,
+		Evictions: m.evictions,
+		Entries:   m.lru.Len(),
+		Bytes:     m.bytes,
+	}
+}
+
+func (m *Memory) removeLocked(element *list.Element) {
+	entry := m.lru.Remove(element).(*memoryEntry)
+	delete(m.entries, entry.key)
+	m.bytes -= entry.size()
+}
+
+var (
+	memoryHitsDesc = prometheus.NewDesc("flow_cache_memory_hits_total",
+		"Reads of the in-memory flow cache which found an entry", nil, nil)
+	memoryMissesDesc = prometheus.NewDesc("flow_cache_memory_misses_total",
+		"Reads of the in-memory flow cache which found no entry", nil, nil)
+	memoryEvictionsDesc = prometheus.NewDesc("flow_cache_memory_evictions_total",
+		"Entries evicted from the in-memory flow cache to make room for new ones", nil, nil)
+	memoryEntriesDesc = prometheus.NewDesc("flow_cache_memory_entries",
+		"Entries held by the in-memory flow cache, including expired entries not yet removed", nil, nil)
+	memoryBytesDesc = prometheus.NewDesc("flow_cache_memory_bytes",
+		"Size of the keys and values held by the in-memory flow cache", nil, nil)
+)
+
+// Describe implements prometheus.Collector
+func (m *Memory) Describe(ch chan<- *prometheus.Desc) {
+	ch <- memoryHitsDesc
+	ch <- memoryMissesDesc
+	ch <- memoryEvictionsDesc
+	ch <- memoryEntriesDesc
+	ch <- memoryBytesDesc
+}
+
+// Collect implements prometheus.Collector
+func (m *Memory) Collect(ch chan<- prometheus.Metric) {
+	stats := m.Stats()
+
+	ch <- prometheus.MustNewConstMetric(memoryHitsDesc, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(memoryMissesDesc, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(memoryEvictionsDesc, prometheus.CounterValue, float64(stats.Evictions))
+	ch <- prometheus.MustNewConstMetric(memoryEntriesDesc, prometheus.GaugeValue, float64(stats.Entries))
+	ch <- prometheus.MustNewConstMetric(memoryBytesDesc, prometheus.GaugeValue, float64(stats.Bytes))
+}
@@ -0,0 +1,174 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package caching
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func Test_Memory_GetSetEx(t *testing.T) {
+	m := NewMemory(1024, 0)
+	ctx := context.Background()
+
+	if _, err := m.Get(ctx, "a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("want ErrNotFound, got: %v", err)
+	}
+	if err := m.SetEx(ctx, "a", []byte("value"), time.Minute); err != nil {
+		t.Fatalf("want no error, got: %s", err)
+	}
+
+	value, err := m.Get(ctx, "a")
+	if err != nil || string(value) != "value" {
+		t.Fatalf("want value, got: %q, %v", value, err)
+	}
+
+	stats := m.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Entries != 1 || stats.Bytes != int64(len("a")+len("value")) {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func Test_Memory_Expires(t *testing.T) {
+	now := time.Now()
+	m := NewMemory(1024, 0)
+	m.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	m.SetEx(ctx, "a", []byte("value"), time.Second)
+
+	now = now.Add(time.Second)
+	if _, err := m.Get(ctx, "a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("want an expired entry to be a miss, got: %v", err)
+	}
+	if stats := m.Stats(); stats.Entries != 0 || stats.Bytes != 0 {
+		t.Fatalf("want the expired entry to be removed, got: %+v", stats)
+	}
+}
+
+func Test_Memory_EvictsLeastRecentlyUsedByBytes(t *testing.T) {
+	// each entry is 1 byte of key and 9 bytes of value
+	m := NewMemory(30, 0)
+	ctx := context.Background()
+
+	m.SetEx(ctx, "a", make([]byte, 9), time.Minute)
+	m.SetEx(ctx, "b", make([]byte, 9), time.Minute)
+	m.SetEx(ctx, "c", make([]byte, 9), time.Minute)
+	m.Get(ctx, "a")
+	m.SetEx(ctx, "d", make([]byte, 9), time.Minute)
+
+	if _, err := m.Get(ctx, "b"); err == nil {
+		t.Fatalf("want b to be evicted as the least recently used entry")
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if _, err := m.Get(ctx, key); err != nil {
+			t.Fatalf("want %s to be kept, got: %s", key, err)
+		}
+	}
+	if stats := m.Stats(); stats.Evictions != 1 || stats.Bytes != 30 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	// a larger entry evicts as many entries as it needs
+	m.SetEx(ctx, "e", make([]byte, 19), time.Minute)
+	if stats := m.Stats(); stats.Entries != 2 || stats.Bytes != 30 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	if err := m.SetEx(ctx, "f", make([]byte, 30), time.Minute); err == nil {
+		t.Fatalf("want an error for an entry larger than the cache")
+	}
+}
+
+func Test_Memory_EvictsByEntries(t *testing.T) {
+	m := NewMemory(1024, 2)
+	ctx := context.Background()
+
+	m.SetEx(ctx, "a", []byte("1"), time.Minute)
+	m.SetEx(ctx, "b", []byte("2"), time.Minute)
+	m.SetEx(ctx, "a", []byte("3"), time.Minute)
+	m.SetEx(ctx, "c", []byte("4"), time.Minute)
+
+	if _, err := m.Get(ctx, "b"); err == nil {
+		t.Fatalf("want b to be evicted")
+	}
+	if value, _ := m.Get(ctx, "a"); string(value) != "3" {
+		t.Fatalf("want a to be replaced, got: %q", value)
+	}
+	if stats := m.Stats(); stats.Entries != 2 {
+		t.Fatalf("want 2 entries, got: %+v", stats)
+	}
+}
+
+func Test_Memory_DelPrefix(t *testing.T) {
+	now := time.Now()
+	m := NewMemory(1024, 0)
+	m.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	m.SetEx(ctx, "flow:fn:a:1", []byte("1"), time.Minute)
+	m.SetEx(ctx, "flow:fn:a:2", []byte("2"), time.Second)
+	m.SetEx(ctx, "flow:fn:b:1", []byte("3"), time.Minute)
+
+	now = now.Add(time.Second)
+	deleted, err := m.DelPrefix(ctx, "flow:fn:a:")
+	if err != nil || deleted != 1 {
+		t.Fatalf("want 1 live entry to be deleted, got: %d, %v", deleted, err)
+	}
+	if deleted, err := m.Del(ctx, "flow:fn:b:1"); err != nil || deleted != 1 {
+		t.Fatalf("want 1 entry to be deleted, got: %d, %v", deleted, err)
+	}
+	if deleted, err := m.Del(ctx, "flow:fn:b:1"); err != nil || deleted != 0 {
+		t.Fatalf("want a missing entry not to be counted, got: %d, %v", deleted, err)
+	}
+	if stats := m.Stats(); stats.Entries != 0 || stats.Bytes != 0 {
+		t.Fatalf("want an empty cache, got: %+v", stats)
+	}
+}
+
+func Test_Memory_Collect(t *testing.T) {
+	m := NewMemory(1024, 0)
+	ctx := context.Background()
+
+	m.SetEx(ctx, "a", []byte("value"), time.Minute)
+	m.Get(ctx, "a")
+	m.Get(ctx, "b")
+
+	registry := prometheus.NewPedanticRegistry()
+	if err := registry.Register(m); err != nil {
+		t.Fatalf("want no error, got: %s", err)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err)
+	}
+
+	values := map[string]float64{}
+	for _, family := range families {
+		metric := family.GetMetric()[0]
+		if counter := metric.GetCounter(); counter != nil {
+			values[family.GetName()] = counter.GetValue()
+		} else {
+			values[family.GetName()] = metric.GetGauge().GetValue()
+		}
+	}
+
+	want := map[string]float64{
+		"flow_cache_memory_hits_total":      1,
+		"flow_cache_memory_misses_total":    1,
+		"flow_cache_memory_evictions_total": 0,
+		"flow_cache_memory_entries":         1,
+		"flow_cache_memory_bytes":           6,
+	}
+	for name, value := range want {
+		if values[name] != value {
+			t.Errorf("want %s to be %v, got: %v", name, value, values[name])
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package caching
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danenherdi/faas-provider/types"
+	paperClient "github.com/danenherdi/paper-client-go"
+)
+
+// maxTrackedKeys bounds the key index of a PaperCache client, once it is
+// reached the keys which expire soonest are forgotten
+const maxTrackedKeys = 100000
+
+// PaperCache is a Client backed by PaperCache. PaperCache can not list its
+// keys, so the keys written through this client are tracked in memory for
+// DelPrefix. Keys written by another replica, or before a restart, can only
+// be removed with Del or Wipe, so PaperCache is a Wiper.
+type PaperCache struct {
+	types.PaperCacheClientWrapper
+
+	lock sync.Mutex
+	// keys maps each key written by this client to the time it expires
+	keys map[string]time.Time
+}
+
+// NewPaperCache creates a Client for an existing PaperCache connection
+func NewPaperCache(client *paperClient.PaperClient) *PaperCache {
+	return &PaperCache{
+		PaperCacheClientWrapper: types.PaperCacheClientWrapper{Client: client},
+		keys:                    map[string]time.Time{},
+	}
+}
+
+// SetEx stores value under key for ttl and records the key for DelPrefix
+func (p *PaperCache) SetEx(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := p.PaperCacheClientWrapper.SetEx(ctx, key, value, ttl); err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.keys[key] = time.Now().Add(ttl)
+	if len(p.keys) > maxTrackedKeys {
+		p.pruneLocked(time.Now())
+	}
+	return nil
+}
+
+// Del removes a single key
+func (p *PaperCache) Del(ctx context.Context, key string) (int, error) {
+	p.lock.Lock()
+	delete(p.keys, key)
+	p.lock.Unlock()
+
+	if err := p.Client.Del(key); err != nil {
+		if isPaperNotFound(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return 1, nil
+}
+
+// DelPrefix removes the tracked keys which start with prefix
+func (p *PaperCache) DelPrefix(ctx context.Context, prefix string) (int, error) {
+	p.lock.Lock()
+	now := time.Now()
+	keys := []string{}
+	for key, expires := range p.keys {
+		if strings.HasPrefix(key, prefix) {
+			delete(p.keys, key)
+			if expires.After(now) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	p.lock.Unlock()
+
+	deleted := 0
+	for _, key := range keys {
+		if err := p.Client.Del(key); err != nil {
+			if isPaperNotFound(err) {
+				continue
+			}
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// Wipe removes every entry held by PaperCache, whichever client wrote it.
+// The number removed is the number of entries PaperCache held just before.
+func (p *PaperCache) Wipe(ctx context.Context) (int, error) {
+	status, err := p.Client.Status()
+	if err != nil {
+		return 0, err
+	}
+
+	p.lock.Lock()
+	p.keys = map[string]time.Time{}
+	p.lock.Unlock()
+
+	if err := p.Client.Wipe(); err != nil {
+		return 0, err
+	}
+	return int(status.GetNumObjects()), nil
+}
+
+// pruneLocked forgets expired keys, and then the keys which expire soonest
+// until the index is back under maxTrackedKeys
+func (p *PaperCache) pruneLocked(now time.Time) {
+	for key, expires := range p.keys {
+		if !expires.After(now) {
+			delete(p.keys, key)
+		}
+	}
+
+	if excess := len(p.keys) - maxTrackedKeys; excess > 0 {
+		keys := make([]string, 0, len(p.keys))
+		for key := range p.keys {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return p.keys[keys[i]].Before(p.keys[keys[j]])
+		})
+		for _, key := range keys[:excess] {
+			delete(p.keys, key)
+		}
+	}
+}
+
+// isPaperNotFound returns true when PaperCache reports that a key does not
+// exist, which is not an error when removing it
+func isPaperNotFound(err error) bool {
+	return errors.Is(err, paperClient.PaperErrorKeyNotFound)
+}
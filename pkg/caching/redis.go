@@ -0,0 +1,217 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package caching
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openfaas/faas-netes/pkg/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// scanBatchSize is the number of keys requested from each SCAN and removed
+// with each pipeline of UNLINKs
+const scanBatchSize = 500
+
+// connectTimeout bounds the check made when connecting to Redis
+const connectTimeout = 10 * time.Second
+
+// Redis is a Client backed by a standalone Redis server, a Sentinel
+// managed failover group or a Redis Cluster
+type Redis struct {
+	Client redis.UniversalClient
+}
+
+// NewRedis creates a Client for an existing Redis connection
+func NewRedis(client redis.UniversalClient) *Redis {
+	return &Redis{
+		Client: client,
+	}
+}
+
+// ConnectRedis connects to Redis as configured and checks the connection
+// with a PING, so that a misconfiguration is reported at startup rather
+// than on the first cached request
+func ConnectRedis(ctx context.Context, cfg config.RedisConfig) (*Redis, error) {
+	opts, err := redisOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var client redis.UniversalClient
+	switch {
+	case cfg.Cluster:
+		client = redis.NewClusterClient(opts.Cluster())
+	case len(cfg.SentinelMaster) > 0:
+		client = redis.NewFailoverClient(opts.Failover())
+	default:
+		client = redis.NewClient(opts.Simple())
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("unable to reach redis (%s) at %s: %w", cfg.Mode(), strings.Join(cfg.Addresses, ","), err)
+	}
+
+	return NewRedis(client), nil
+}
+
+// redisOptions builds the client options, reading the password and CA
+// bundle from their mounted secrets
+func redisOptions(cfg config.RedisConfig) (*redis.UniversalOptions, error) {
+	opts := &redis.UniversalOptions{
+		Addrs:      cfg.Addresses,
+		DB:         cfg.DB,
+		Username:   cfg.Username,
+		MasterName: cfg.SentinelMaster,
+	}
+
+	if len(cfg.PasswordFile) > 0 {
+		password, err := readSecret(cfg.PasswordFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read redis password: %w", err)
+		}
+		opts.Password = password
+	}
+
+	if len(cfg.SentinelPasswordFile) > 0 {
+		password, err := readSecret(cfg.SentinelPasswordFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read redis sentinel password: %w", err)
+		}
+		opts.SentinelPassword = password
+	}
+
+	if cfg.TLS {
+		tlsConfig := &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			ServerName:         cfg.TLSServerName,
+			InsecureSkipVerify: cfg.TLSInsecure,
+		}
+
+		if len(cfg.TLSCAFile) > 0 {
+			pem, err := os.ReadFile(cfg.TLSCAFile)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read redis CA bundle: %w", err)
+			}
+
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in redis CA bundle: %s", cfg.TLSCAFile)
+			}
+			tlsConfig.RootCAs = pool
+		}
+
+		opts.TLSConfig = tlsConfig
+	}
+
+	return opts, nil
+}
+
+func readSecret(path string) (string, error) {
+	value, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(value)), nil
+}
+
+// Get returns the value of key
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+	return r.Client.Get(ctx, key).Bytes()
+}
+
+// SetEx stores value under key for ttl
+func (r *Redis) SetEx(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.Client.SetEx(ctx, key, value, ttl).Err()
+}
+
+// Del removes a single key
+func (r *Redis) Del(ctx context.Context, key string) (int, error) {
+	deleted, err := r.Client.Del(ctx, key).Result()
+	return int(deleted), err
+}
+
+// DelPrefix walks the keyspace with SCAN, so that Redis is not blocked as
+// it would be by KEYS, and unlinks the matching keys. In cluster mode every
+// master is scanned.
+func (r *Redis) DelPrefix(ctx context.Context, prefix string) (int, error) {
+	match := escapePattern(prefix) + "*"
+
+	cluster, ok := r.Client.(*redis.ClusterClient)
+	if !ok {
+		return delMatching(ctx, r.Client, match)
+	}
+
+	var lock sync.Mutex
+	deleted := 0
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		n, err := delMatching(ctx, node, match)
+
+		lock.Lock()
+		deleted += n
+		lock.Unlock()
+
+		return err
+	})
+	return deleted, err
+}
+
+// delMatching removes the keys of a single server which match a pattern.
+// Each key is unlinked on its own, as keys in different hash slots can not
+// be passed to one UNLINK in cluster mode.
+func delMatching(ctx context.Context, client redis.Cmdable, match string) (int, error) {
+	deleted := 0
+	var cursor uint64
+	for {
+		keys, next, err := client.Scan(ctx, cursor, match, scanBatchSize).Result()
+		if err != nil {
+			return deleted, err
+		}
+
+		if len(keys) > 0 {
+			cmds, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+				for _, key := range keys {
+					pipe.Unlink(ctx, key)
+				}
+				return nil
+			})
+			if err != nil {
+				return deleted, err
+			}
+			for _, cmd := range cmds {
+				deleted += int(cmd.(*redis.IntCmd).Val())
+			}
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return deleted, nil
+		}
+	}
+}
+
+// escapePattern escapes the characters which have a meaning in a Redis
+// glob-style pattern
+func escapePattern(value string) string {
+	var sb strings.Builder
+	for _, r := range value {
+		switch r {
+		case '*', '?', '[', ']', '^', '\\':
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
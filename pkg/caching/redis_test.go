@@ -0,0 +1,74 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package caching
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openfaas/faas-netes/pkg/config"
+)
+
+func writeSecret(t *testing.T, name, value string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(value), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func Test_redisOptions(t *testing.T) {
+	cfg := config.RedisConfig{
+		Addresses:            []string{"sentinel-0:26379"},
+		DB:                   1,
+		Username:             "faas-netes",
+		PasswordFile:         writeSecret(t, "password", "s3cr3t\n"),
+		SentinelMaster:       "mymaster",
+		SentinelPasswordFile: writeSecret(t, "sentinel-password", "sentinel"),
+		TLS:                  true,
+		TLSServerName:        "redis.internal",
+	}
+
+	opts, err := redisOptions(cfg)
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err)
+	}
+
+	if opts.Password != "s3cr3t" || opts.SentinelPassword != "sentinel" {
+		t.Errorf("want passwords read from their files, got: %q and %q", opts.Password, opts.SentinelPassword)
+	}
+	if opts.MasterName != "mymaster" || opts.DB != 1 || opts.Username != "faas-netes" {
+		t.Errorf("unexpected options: %+v", opts)
+	}
+	if opts.TLSConfig == nil || opts.TLSConfig.ServerName != "redis.internal" {
+		t.Errorf("want TLS with the configured server name, got: %+v", opts.TLSConfig)
+	}
+}
+
+func Test_redisOptions_Errors(t *testing.T) {
+	cases := map[string]config.RedisConfig{
+		"missing password file": {Addresses: []string{"redis:6379"}, PasswordFile: "/does/not/exist"},
+		"invalid CA bundle":     {Addresses: []string{"redis:6379"}, TLS: true, TLSCAFile: writeSecret(t, "ca.crt", "not a certificate")},
+	}
+
+	for name, cfg := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := redisOptions(cfg); err == nil {
+				t.Fatalf("want an error")
+			}
+		})
+	}
+}
+
+func Test_ConnectRedis_Unreachable(t *testing.T) {
+	_, err := ConnectRedis(context.Background(), config.RedisConfig{Addresses: []string{"127.0.0.1:1"}})
+	if err == nil || !strings.Contains(err.Error(), "unable to reach redis (standalone) at 127.0.0.1:1") {
+		t.Fatalf("want a clear error for an unreachable server, got: %v", err)
+	}
+}
@@ -39,6 +39,14 @@ func ForKind(kind schema.GroupVersionKind) interface{} {
 		// Group=openfaas.com, Version=v1
 	case openfaasv1.SchemeGroupVersion.WithKind("AppliedProfile"):
 		return &applyconfigurationopenfaasv1.AppliedProfileApplyConfiguration{}
+	case openfaasv1.SchemeGroupVersion.WithKind("Flow"):
+		return &applyconfigurationopenfaasv1.FlowApplyConfiguration{}
+	case openfaasv1.SchemeGroupVersion.WithKind("FlowCacheKey"):
+		return &applyconfigurationopenfaasv1.FlowCacheKeyApplyConfiguration{}
+	case openfaasv1.SchemeGroupVersion.WithKind("FlowChild"):
+		return &applyconfigurationopenfaasv1.FlowChildApplyConfiguration{}
+	case openfaasv1.SchemeGroupVersion.WithKind("FlowSpec"):
+		return &applyconfigurationopenfaasv1.FlowSpecApplyConfiguration{}
 	case openfaasv1.SchemeGroupVersion.WithKind("Function"):
 		return &applyconfigurationopenfaasv1.FunctionApplyConfiguration{}
 	case openfaasv1.SchemeGroupVersion.WithKind("FunctionResources"):
@@ -53,6 +61,10 @@ func ForKind(kind schema.GroupVersionKind) interface{} {
 		return &applyconfigurationopenfaasv1.ProfileSpecApplyConfiguration{}
 	case openfaasv1.SchemeGroupVersion.WithKind("ResourceRef"):
 		return &applyconfigurationopenfaasv1.ResourceRefApplyConfiguration{}
+	case openfaasv1.SchemeGroupVersion.WithKind("ThirdPartyAuth"):
+		return &applyconfigurationopenfaasv1.ThirdPartyAuthApplyConfiguration{}
+	case openfaasv1.SchemeGroupVersion.WithKind("ThirdPartyRequest"):
+		return &applyconfigurationopenfaasv1.ThirdPartyRequestApplyConfiguration{}
 
 	}
 	return nil
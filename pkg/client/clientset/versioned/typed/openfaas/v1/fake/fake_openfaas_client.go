@@ -18,6 +18,10 @@ type FakeOpenfaasV1 struct {
 	*testing.Fake
 }
 
+func (c *FakeOpenfaasV1) Flows(namespace string) v1.FlowInterface {
+	return &FakeFlows{c, namespace}
+}
+
 func (c *FakeOpenfaasV1) Functions(namespace string) v1.FunctionInterface {
 	return &FakeFunctions{c, namespace}
 }
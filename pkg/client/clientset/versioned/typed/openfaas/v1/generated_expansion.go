@@ -8,6 +8,8 @@ Licensed under the MIT license. See LICENSE file in the project root for full li
 
 package v1
 
+type FlowExpansion interface{}
+
 type FunctionExpansion interface{}
 
 type ProfileExpansion interface{}
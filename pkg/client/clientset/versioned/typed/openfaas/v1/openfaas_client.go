@@ -18,6 +18,7 @@ import (
 
 type OpenfaasV1Interface interface {
 	RESTClient() rest.Interface
+	FlowsGetter
 	FunctionsGetter
 	ProfilesGetter
 }
@@ -27,6 +28,10 @@ type OpenfaasV1Client struct {
 	restClient rest.Interface
 }
 
+func (c *OpenfaasV1Client) Flows(namespace string) FlowInterface {
+	return newFlows(c, namespace)
+}
+
 func (c *OpenfaasV1Client) Functions(namespace string) FunctionInterface {
 	return newFunctions(c, namespace)
 }
@@ -52,6 +52,8 @@ func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Iam().V1().Roles().Informer()}, nil
 
 		// Group=openfaas.com, Version=v1
+	case openfaasv1.SchemeGroupVersion.WithResource("flows"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Openfaas().V1().Flows().Informer()}, nil
 	case openfaasv1.SchemeGroupVersion.WithResource("functions"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Openfaas().V1().Functions().Informer()}, nil
 	case openfaasv1.SchemeGroupVersion.WithResource("profiles"):
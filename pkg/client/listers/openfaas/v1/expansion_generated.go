@@ -8,6 +8,14 @@ Licensed under the MIT license. See LICENSE file in the project root for full li
 
 package v1
 
+// FlowListerExpansion allows custom methods to be added to
+// FlowLister.
+type FlowListerExpansion interface{}
+
+// FlowNamespaceListerExpansion allows custom methods to be added to
+// FlowNamespaceLister.
+type FlowNamespaceListerExpansion interface{}
+
 // FunctionListerExpansion allows custom methods to be added to
 // FunctionLister.
 type FunctionListerExpansion interface{}
@@ -6,7 +6,10 @@
 package config
 
 import (
+	"fmt"
 	"log"
+	"strconv"
+	"strings"
 
 	ftypes "github.com/danenherdi/faas-provider/types"
 )
@@ -25,6 +28,7 @@ func (ReadConfig) Read(hasEnv ftypes.HasEnv) (BootstrapConfig, error) {
 	}
 
 	cfg.FaaSConfig = *faasConfig
+	readOrchestratorConfig(hasEnv, &cfg.FaaSConfig)
 
 	httpProbe := ftypes.ParseBoolValue(hasEnv.Getenv("http_probe"), false)
 	setNonRootUser := ftypes.ParseBoolValue(hasEnv.Getenv("set_nonroot_user"), false)
@@ -34,9 +38,477 @@ func (ReadConfig) Read(hasEnv ftypes.HasEnv) (BootstrapConfig, error) {
 	cfg.HTTPProbe = httpProbe
 	cfg.SetNonRootUser = setNonRootUser
 
+	redis, err := readRedisConfig(hasEnv)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Redis = redis
+
+	memory, err := readMemoryCacheConfig(hasEnv)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.MemoryCache = memory
+
+	asyncFlows, err := readAsyncFlowConfig(hasEnv)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.AsyncFlows = asyncFlows
+
+	cfg.ExecutionHistory = ftypes.ParseIntValue(hasEnv.Getenv("flow_execution_history"), defaultExecutionHistory)
+	cfg.DecisionHistory = ftypes.ParseIntValue(hasEnv.Getenv("orchestrator_decision_history"), defaultDecisionHistory)
+
+	tracing, err := readTracingConfig(hasEnv)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Tracing = tracing
+
+	cacheTrace, err := readCacheTraceConfig(hasEnv)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.CacheTrace = cacheTrace
+
 	return cfg, nil
 }
 
+// readOrchestratorConfig reads the settings of the cache orchestrator,
+// which apply whichever cache backend is used. An invalid value is logged
+// and replaced with its default.
+func readOrchestratorConfig(hasEnv ftypes.HasEnv, faasConfig *ftypes.FaaSConfig) {
+	faasConfig.EnableIntelligentOrchestrator = hasEnv.Getenv("ENABLE_INTELLIGENT_ORCHESTRATOR") == "true"
+
+	faasConfig.OrchestratorEvalInterval = 10 // Default: 10 seconds
+	if value := hasEnv.Getenv("ORCHESTRATOR_EVAL_INTERVAL"); value != "" {
+		if val, err := strconv.ParseUint(value, 10, 64); err == nil {
+			faasConfig.OrchestratorEvalInterval = val
+		} else {
+			log.Printf("Invalid ORCHESTRATOR_EVAL_INTERVAL: %s, using default (10)", value)
+		}
+	}
+
+	faasConfig.OrchestratorStabilityPeriod = 30 // Default: 30 seconds
+	if value := hasEnv.Getenv("ORCHESTRATOR_STABILITY_PERIOD"); value != "" {
+		if val, err := strconv.ParseUint(value, 10, 64); err == nil {
+			faasConfig.OrchestratorStabilityPeriod = val
+		} else {
+			log.Printf("Invalid ORCHESTRATOR_STABILITY_PERIOD: %s, using default (30)", value)
+		}
+	}
+
+	faasConfig.OrchestratorSwitchThreshold = 0.05 // Default: 5%
+	if value := hasEnv.Getenv("ORCHESTRATOR_SWITCH_THRESHOLD"); value != "" {
+		if val, err := strconv.ParseFloat(value, 64); err != nil {
+			log.Printf("Invalid ORCHESTRATOR_SWITCH_THRESHOLD: %s, using default (0.05)", value)
+		} else if val <= 0 || val > 1.0 {
+			log.Printf("Invalid ORCHESTRATOR_SWITCH_THRESHOLD: %s (must be 0-1), using default (0.05)", value)
+		} else {
+			faasConfig.OrchestratorSwitchThreshold = val
+		}
+	}
+
+	faasConfig.OrchestratorMaxMemory = 8 // Default: 8 GB
+	if value := hasEnv.Getenv("ORCHESTRATOR_MAX_MEMORY"); value != "" {
+		if val, err := strconv.ParseUint(value, 10, 64); err == nil {
+			faasConfig.OrchestratorMaxMemory = val
+		} else {
+			log.Printf("Invalid ORCHESTRATOR_MAX_MEMORY: %s, using default (8)", value)
+		}
+	}
+}
+
+func readRedisConfig(hasEnv ftypes.HasEnv) (RedisConfig, error) {
+	redis := RedisConfig{
+		DB:                   ftypes.ParseIntValue(hasEnv.Getenv("redis_db"), 0),
+		Username:             hasEnv.Getenv("redis_username"),
+		PasswordFile:         hasEnv.Getenv("redis_password_file"),
+		TLS:                  ftypes.ParseBoolValue(hasEnv.Getenv("redis_tls"), false),
+		TLSCAFile:            hasEnv.Getenv("redis_tls_ca_file"),
+		TLSServerName:        hasEnv.Getenv("redis_tls_server_name"),
+		TLSInsecure:          ftypes.ParseBoolValue(hasEnv.Getenv("redis_tls_insecure_skip_verify"), false),
+		SentinelMaster:       hasEnv.Getenv("redis_sentinel_master"),
+		SentinelPasswordFile: hasEnv.Getenv("redis_sentinel_password_file"),
+		Cluster:              ftypes.ParseBoolValue(hasEnv.Getenv("redis_cluster"), false),
+	}
+
+	for _, addr := range strings.Split(ftypes.ParseString(hasEnv.Getenv("redis_address"), "localhost:6379"), ",") {
+		if addr = strings.TrimSpace(addr); len(addr) > 0 {
+			redis.Addresses = append(redis.Addresses, addr)
+		}
+	}
+
+	for _, policy := range strings.Split(ftypes.ParseString(hasEnv.Getenv("redis_policies"), defaultRedisPolicies), ",") {
+		if policy = strings.TrimSpace(policy); len(policy) > 0 {
+			if !redisEvictionPolicies[policy] {
+				return redis, fmt.Errorf("redis_policies: %s is not a Redis maxmemory-policy", policy)
+			}
+			redis.Policies = append(redis.Policies, policy)
+		}
+	}
+
+	if len(redis.Addresses) == 0 {
+		return redis, fmt.Errorf("redis_address must not be empty")
+	}
+	if redis.Cluster && len(redis.SentinelMaster) > 0 {
+		return redis, fmt.Errorf("redis_cluster and redis_sentinel_master can not be used together")
+	}
+	if len(redis.TLSCAFile) > 0 || len(redis.TLSServerName) > 0 || redis.TLSInsecure {
+		redis.TLS = true
+	}
+
+	return redis, nil
+}
+
+func readMemoryCacheConfig(hasEnv ftypes.HasEnv) (MemoryCacheConfig, error) {
+	memory := MemoryCacheConfig{
+		MaxBytes:   int64(ftypes.ParseIntValue(hasEnv.Getenv("memory_cache_max_bytes"), defaultMemoryCacheMaxBytes)),
+		MaxEntries: ftypes.ParseIntValue(hasEnv.Getenv("memory_cache_max_entries"), 0),
+	}
+
+	if memory.MaxBytes <= 0 {
+		return memory, fmt.Errorf("memory_cache_max_bytes must be greater than zero")
+	}
+
+	return memory, nil
+}
+
+// readAsyncFlowConfig parses the sizes strictly, rather than with
+// ftypes.ParseIntValue, so that a negative or malformed value is reported
+// instead of replaced with the default
+func readAsyncFlowConfig(hasEnv ftypes.HasEnv) (AsyncFlowConfig, error) {
+	async := AsyncFlowConfig{}
+
+	var err error
+	if async.Workers, err = parseInt("async_flow_workers", hasEnv.Getenv("async_flow_workers"), defaultAsyncFlowWorkers); err != nil {
+		return async, err
+	}
+	if async.QueueSize, err = parseInt("async_flow_queue_size", hasEnv.Getenv("async_flow_queue_size"), defaultAsyncFlowQueueSize); err != nil {
+		return async, err
+	}
+
+	if async.Workers < 1 {
+		return async, fmt.Errorf("async_flow_workers must be greater than zero")
+	}
+	if async.QueueSize < 0 {
+		return async, fmt.Errorf("async_flow_queue_size must not be negative")
+	}
+
+	return async, nil
+}
+
+// readTracingConfig reads the standard OpenTelemetry variables, so that
+// the exporter is configured in the same way as for other services
+func readTracingConfig(hasEnv ftypes.HasEnv) (TracingConfig, error) {
+	tracing := TracingConfig{
+		Exporter:    ftypes.ParseString(hasEnv.Getenv("OTEL_TRACES_EXPORTER"), "none"),
+		Endpoint:    hasEnv.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"),
+		ServiceName: ftypes.ParseString(hasEnv.Getenv("OTEL_SERVICE_NAME"), "faas-netes"),
+	}
+
+	switch tracing.Exporter {
+	case "none", "otlp":
+	default:
+		return tracing, fmt.Errorf("OTEL_TRACES_EXPORTER must be none or otlp, got %q", tracing.Exporter)
+	}
+
+	tracing.Protocol = ftypes.ParseString(hasEnv.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"), ftypes.ParseString(hasEnv.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL"), "http/protobuf"))
+	switch tracing.Protocol {
+	case "http/protobuf", "http/json":
+	default:
+		return tracing, fmt.Errorf("OTEL_EXPORTER_OTLP_PROTOCOL must be http/protobuf or http/json, got %q", tracing.Protocol)
+	}
+
+	if len(tracing.Endpoint) == 0 {
+		endpoint := ftypes.ParseString(hasEnv.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "http://localhost:4318")
+		tracing.Endpoint = strings.TrimSuffix(endpoint, "/") + "/v1/traces"
+	}
+
+	headers, err := parseHeaders("OTEL_EXPORTER_OTLP_HEADERS", hasEnv.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+	if err != nil {
+		return tracing, err
+	}
+	tracing.Headers = headers
+
+	return tracing, nil
+}
+
+// readCacheTraceConfig reads the capture of flow cache reads
+func readCacheTraceConfig(hasEnv ftypes.HasEnv) (CacheTraceConfig, error) {
+	trace := CacheTraceConfig{
+		Sink:         ftypes.ParseString(hasEnv.Getenv("cache_trace"), "none"),
+		Path:         ftypes.ParseString(hasEnv.Getenv("cache_trace_path"), defaultCacheTracePath),
+		URL:          hasEnv.Getenv("cache_trace_url"),
+		SampleRate:   1,
+		MaxFileBytes: int64(ftypes.ParseIntValue(hasEnv.Getenv("cache_trace_max_file_bytes"), defaultCacheTraceMaxFileBytes)),
+		MaxFiles:     ftypes.ParseIntValue(hasEnv.Getenv("cache_trace_max_files"), defaultCacheTraceMaxFiles),
+	}
+
+	switch trace.Sink {
+	case "none":
+	case "file":
+		if trace.MaxFileBytes <= 0 {
+			return trace, fmt.Errorf("cache_trace_max_file_bytes must be greater than zero")
+		}
+		if trace.MaxFiles < 0 {
+			return trace, fmt.Errorf("cache_trace_max_files must not be negative")
+		}
+	case "http":
+		if len(trace.URL) == 0 {
+			return trace, fmt.Errorf("cache_trace_url is required when cache_trace is http")
+		}
+	default:
+		return trace, fmt.Errorf("cache_trace must be none, file or http, got %q", trace.Sink)
+	}
+
+	if rate := hasEnv.Getenv("cache_trace_sample_rate"); len(rate) > 0 {
+		value, err := strconv.ParseFloat(rate, 64)
+		if err != nil || value <= 0 || value > 1 {
+			return trace, fmt.Errorf("cache_trace_sample_rate must be greater than 0 and at most 1, got %q", rate)
+		}
+		trace.SampleRate = value
+	}
+
+	headers, err := parseHeaders("cache_trace_headers", hasEnv.Getenv("cache_trace_headers"))
+	if err != nil {
+		return trace, err
+	}
+	trace.Headers = headers
+
+	return trace, nil
+}
+
+// parseInt parses the value of the variable name as an integer, fallback
+// is returned when it is unset
+func parseInt(name, value string, fallback int) (int, error) {
+	if len(value) == 0 {
+		return fallback, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer, got %q", name, value)
+	}
+	return parsed, nil
+}
+
+// parseHeaders parses the value of the variable name as a list of
+// key=value pairs separated by commas
+func parseHeaders(name, value string) (map[string]string, error) {
+	var headers map[string]string
+	for _, pair := range strings.Split(value, ",") {
+		if len(strings.TrimSpace(pair)) == 0 {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || len(strings.TrimSpace(key)) == 0 {
+			return nil, fmt.Errorf("%s must be a list of key=value pairs", name)
+		}
+		if headers == nil {
+			headers = map[string]string{}
+		}
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
+
+// TracingConfig configures the export of the spans of flows and function
+// invocations
+type TracingConfig struct {
+	// Exporter is none, which records no spans, or otlp.
+	// Set via OTEL_TRACES_EXPORTER, the default is none.
+	Exporter string
+
+	// Endpoint is the URL to which spans are sent with OTLP/HTTP.
+	// Set via OTEL_EXPORTER_OTLP_TRACES_ENDPOINT, or via
+	// OTEL_EXPORTER_OTLP_ENDPOINT with /v1/traces appended, the default is
+	// http://localhost:4318/v1/traces.
+	Endpoint string
+
+	// Protocol is the encoding of the exported spans, http/protobuf or
+	// http/json. Set via OTEL_EXPORTER_OTLP_TRACES_PROTOCOL or
+	// OTEL_EXPORTER_OTLP_PROTOCOL, the default is http/protobuf.
+	Protocol string
+
+	// Headers are sent with each export, for example to authenticate.
+	// Set via OTEL_EXPORTER_OTLP_HEADERS as a list of key=value pairs.
+	Headers map[string]string
+
+	// ServiceName is the service.name of the exported spans.
+	// Set via OTEL_SERVICE_NAME, the default is faas-netes.
+	ServiceName string
+}
+
+// CacheTraceConfig configures the capture of flow cache reads, in the
+// format of the cachetrace package, for replay with cache-replay
+type CacheTraceConfig struct {
+	// Sink is none, which captures nothing, file or http.
+	// Set via cache_trace, the default is none.
+	Sink string
+
+	// Path is the file written by the file sink, rotated files have .1,
+	// .2 and so on appended to it.
+	// Set via cache_trace_path, the default is /tmp/flow-cache-trace.jsonl.
+	Path string
+	// MaxFileBytes is the size at which the file is rotated.
+	// Set via cache_trace_max_file_bytes, the default is 100MB.
+	MaxFileBytes int64
+	// MaxFiles is the number of rotated files which are kept, the oldest
+	// is removed.
+	// Set via cache_trace_max_files, the default is 3.
+	MaxFiles int
+
+	// URL receives a POST of each batch of records for the http sink.
+	// Set via cache_trace_url.
+	URL string
+	// Headers are sent with each batch, for example to authenticate.
+	// Set via cache_trace_headers as a list of key=value pairs.
+	Headers map[string]string
+
+	// SampleRate is the fraction of cache keys whose reads are captured,
+	// every read of a sampled key is captured.
+	// Set via cache_trace_sample_rate, the default is 1.
+	SampleRate float64
+}
+
+const (
+	// defaultCacheTracePath is the file written when cache_trace is file
+	// and cache_trace_path is unset
+	defaultCacheTracePath = "/tmp/flow-cache-trace.jsonl"
+	// defaultCacheTraceMaxFileBytes is the size at which the file is
+	// rotated when cache_trace_max_file_bytes is unset
+	defaultCacheTraceMaxFileBytes = 100 * 1024 * 1024
+	// defaultCacheTraceMaxFiles is the number of rotated files which are
+	// kept when cache_trace_max_files is unset
+	defaultCacheTraceMaxFiles = 3
+)
+
+// defaultExecutionHistory is the number of flow executions which are kept
+// when flow_execution_history is unset
+const defaultExecutionHistory = 100
+
+// defaultDecisionHistory is the number of decisions of the cache
+// orchestrator which are kept when orchestrator_decision_history is unset
+const defaultDecisionHistory = 500
+
+const (
+	// defaultAsyncFlowWorkers is the number of flows invoked through
+	// /async-flow which run at once when async_flow_workers is unset
+	defaultAsyncFlowWorkers = 10
+	// defaultAsyncFlowQueueSize is the number of flows which wait for a
+	// worker when async_flow_queue_size is unset
+	defaultAsyncFlowQueueSize = 100
+)
+
+// AsyncFlowConfig bounds the in-process pool which runs the flows invoked
+// through /async-flow
+type AsyncFlowConfig struct {
+	// Workers is the number of flows which run at once.
+	// Set via async_flow_workers, the default is 10.
+	Workers int
+
+	// QueueSize is the number of flows which wait for a worker, further
+	// requests are rejected with 429 Too Many Requests.
+	// Set via async_flow_queue_size, the default is 100.
+	QueueSize int
+}
+
+// defaultMemoryCacheMaxBytes is the size of the in-memory cache when
+// memory_cache_max_bytes is unset
+const defaultMemoryCacheMaxBytes = 64 * 1024 * 1024
+
+// MemoryCacheConfig bounds the in-memory cache, used when CACHING_METHOD is
+// set to memory
+type MemoryCacheConfig struct {
+	// MaxBytes is the total size of the cached keys and values.
+	// Set via memory_cache_max_bytes, the default is 64MB.
+	MaxBytes int64
+
+	// MaxEntries is the number of cached entries, zero means no limit.
+	// Set via memory_cache_max_entries.
+	MaxEntries int
+}
+
+// RedisConfig configures the connection to Redis when it is used as the
+// cache for flow responses
+type RedisConfig struct {
+	// Addresses of the Redis server, of the Sentinels when SentinelMaster
+	// is set, or of the seed nodes when Cluster is set.
+	// Set via redis_address as a comma-separated list.
+	Addresses []string
+
+	// DB selects the database, it is ignored in cluster mode
+	DB int
+
+	// Username is used for Redis 6 ACL authentication
+	Username string
+
+	// PasswordFile is the path of a mounted secret which holds the password
+	PasswordFile string
+
+	// TLS enables TLS, it is implied by any of the other TLS settings
+	TLS bool
+
+	// TLSCAFile is the path of a PEM bundle used to verify the server
+	TLSCAFile string
+
+	// TLSServerName overrides the name used to verify the server's certificate
+	TLSServerName string
+
+	// TLSInsecure skips verification of the server's certificate
+	TLSInsecure bool
+
+	// SentinelMaster is the name of the master monitored by the Sentinels,
+	// setting it enables Sentinel failover
+	SentinelMaster string
+
+	// SentinelPasswordFile is the path of a mounted secret which holds the
+	// password of the Sentinels, if it differs from the server's
+	SentinelPasswordFile string
+
+	// Cluster connects to a Redis Cluster
+	Cluster bool
+
+	// Policies are the values of maxmemory-policy between which the cache
+	// orchestrator switches. It starts from the policy Redis is using, read
+	// with CONFIG GET, and is disabled when CONFIG is not allowed. Each
+	// switch is made with CONFIG SET, and fails when only CONFIG GET is.
+	// The lru and lfu policies are compared on miss ratios simulated from
+	// the reads of the cache, the others can only be pinned.
+	// Set via redis_policies as a comma-separated list, the default is
+	// allkeys-lru,allkeys-lfu,volatile-ttl,allkeys-random.
+	Policies []string
+}
+
+// defaultRedisPolicies are the candidates of the cache orchestrator when
+// redis_policies is unset. Flow responses are always cached with a TTL, so
+// the volatile- policies other than volatile-ttl act as their allkeys-
+// counterparts.
+const defaultRedisPolicies = "allkeys-lru,allkeys-lfu,volatile-ttl,allkeys-random"
+
+// redisEvictionPolicies are the values of maxmemory-policy which evict,
+// noeviction is left out as it makes writes fail once the cache is full
+var redisEvictionPolicies = map[string]bool{
+	"allkeys-lru":     true,
+	"allkeys-lfu":     true,
+	"allkeys-random":  true,
+	"volatile-lru":    true,
+	"volatile-lfu":    true,
+	"volatile-random": true,
+	"volatile-ttl":    true,
+}
+
+// Mode describes how the Redis servers are connected to
+func (r RedisConfig) Mode() string {
+	switch {
+	case r.Cluster:
+		return "cluster"
+	case len(r.SentinelMaster) > 0:
+		return "sentinel"
+	}
+	return "standalone"
+}
+
 // BootstrapConfig contains the server configuration values as well as default
 // Function configuration parameters that are passed to the function factory.
 type BootstrapConfig struct {
@@ -55,6 +527,32 @@ type BootstrapConfig struct {
 
 	// FaaSConfig contains the configuration for the FaaSProvider
 	FaaSConfig ftypes.FaaSConfig
+
+	// Redis configures the Redis cache backend
+	Redis RedisConfig
+
+	// MemoryCache configures the in-memory cache backend
+	MemoryCache MemoryCacheConfig
+
+	// AsyncFlows configures the workers of /async-flow
+	AsyncFlows AsyncFlowConfig
+
+	// ExecutionHistory is the number of flow executions which are kept
+	// for /system/flows/{name}/executions, zero disables the history.
+	// Set via flow_execution_history, the default is 100.
+	ExecutionHistory int
+
+	// DecisionHistory is the number of decisions of the cache orchestrator
+	// which are kept in memory for /system/cache/orchestrator/decisions,
+	// they are lost on a restart. Zero disables the history. Set via
+	// orchestrator_decision_history, the default is 500.
+	DecisionHistory int
+
+	// Tracing configures the export of spans
+	Tracing TracingConfig
+
+	// CacheTrace configures the capture of flow cache reads
+	CacheTrace CacheTraceConfig
 }
 
 // Fprint pretty-prints the config with the stdlib logger. One line per config value.
@@ -72,5 +570,12 @@ func (c BootstrapConfig) Fprint(verbose bool) {
 		log.Printf("MaxIdleConnsPerHost: %d\n", c.FaaSConfig.MaxIdleConnsPerHost)
 		log.Printf("HTTPProbe: %v\n", c.HTTPProbe)
 		log.Printf("SetNonRootUser: %v\n", c.SetNonRootUser)
+		log.Printf("Redis: %s %s (TLS: %v, policies: %s)\n", c.Redis.Mode(), strings.Join(c.Redis.Addresses, ","), c.Redis.TLS, strings.Join(c.Redis.Policies, ","))
+		log.Printf("MemoryCache: %d bytes, %d entries\n", c.MemoryCache.MaxBytes, c.MemoryCache.MaxEntries)
+		log.Printf("AsyncFlows: %d workers, queue of %d\n", c.AsyncFlows.Workers, c.AsyncFlows.QueueSize)
+		log.Printf("ExecutionHistory: %d\n", c.ExecutionHistory)
+		log.Printf("DecisionHistory: %d\n", c.DecisionHistory)
+		log.Printf("Tracing: %s %s %s (service: %s)\n", c.Tracing.Exporter, c.Tracing.Protocol, c.Tracing.Endpoint, c.Tracing.ServiceName)
+		log.Printf("CacheTrace: %s (sample rate: %.2f)\n", c.CacheTrace.Sink, c.CacheTrace.SampleRate)
 	}
 }
@@ -72,3 +72,326 @@ func TestRead_HTTPProbeConfig_true(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestRead_RedisDefaults(t *testing.T) {
+	config, err := ReadConfig{}.Read(NewEnvBucket())
+	if err != nil {
+		t.Fatalf("Unexpected error while reading env %s", err.Error())
+	}
+
+	if len(config.Redis.Addresses) != 1 || config.Redis.Addresses[0] != "localhost:6379" {
+		t.Errorf("Redis.Addresses incorrect, got: %v", config.Redis.Addresses)
+	}
+	if config.Redis.Mode() != "standalone" || config.Redis.TLS {
+		t.Errorf("Redis mode incorrect, got: %s, TLS: %v", config.Redis.Mode(), config.Redis.TLS)
+	}
+	if len(config.Redis.Policies) != 4 || config.Redis.Policies[0] != "allkeys-lru" {
+		t.Errorf("Redis.Policies incorrect, got: %v", config.Redis.Policies)
+	}
+}
+
+func TestRead_RedisPolicies(t *testing.T) {
+	env := NewEnvBucket()
+	env.Setenv("redis_policies", "allkeys-lfu, volatile-lru")
+
+	config, err := ReadConfig{}.Read(env)
+	if err != nil {
+		t.Fatalf("Unexpected error while reading env %s", err.Error())
+	}
+	if len(config.Redis.Policies) != 2 || config.Redis.Policies[1] != "volatile-lru" {
+		t.Errorf("Redis.Policies incorrect, got: %v", config.Redis.Policies)
+	}
+
+	for _, policies := range []string{"noeviction", "allkeys-lru,lru"} {
+		env := NewEnvBucket()
+		env.Setenv("redis_policies", policies)
+		if _, err := (ReadConfig{}).Read(env); err == nil {
+			t.Errorf("want an error for redis_policies=%s", policies)
+		}
+	}
+}
+
+func TestRead_RedisConfig(t *testing.T) {
+	env := NewEnvBucket()
+	env.Setenv("redis_address", "sentinel-0:26379, sentinel-1:26379")
+	env.Setenv("redis_db", "2")
+	env.Setenv("redis_username", "faas-netes")
+	env.Setenv("redis_password_file", "/var/secrets/redis/password")
+	env.Setenv("redis_tls_ca_file", "/var/secrets/redis/ca.crt")
+	env.Setenv("redis_sentinel_master", "mymaster")
+
+	config, err := ReadConfig{}.Read(env)
+	if err != nil {
+		t.Fatalf("Unexpected error while reading env %s", err.Error())
+	}
+
+	redis := config.Redis
+	if len(redis.Addresses) != 2 || redis.Addresses[1] != "sentinel-1:26379" {
+		t.Errorf("Redis.Addresses incorrect, got: %v", redis.Addresses)
+	}
+	if redis.DB != 2 || redis.Username != "faas-netes" || redis.PasswordFile != "/var/secrets/redis/password" {
+		t.Errorf("Redis auth incorrect, got: %+v", redis)
+	}
+	if !redis.TLS {
+		t.Errorf("Redis.TLS incorrect, want TLS to be implied by a CA file")
+	}
+	if redis.Mode() != "sentinel" {
+		t.Errorf("Redis mode incorrect, got: %s", redis.Mode())
+	}
+}
+
+func TestRead_RedisClusterAndSentinel(t *testing.T) {
+	env := NewEnvBucket()
+	env.Setenv("redis_cluster", "true")
+	env.Setenv("redis_sentinel_master", "mymaster")
+
+	if _, err := (ReadConfig{}).Read(env); err == nil {
+		t.Fatalf("want an error when both cluster and sentinel are configured")
+	}
+}
+
+func TestRead_MemoryCacheConfig(t *testing.T) {
+	config, err := ReadConfig{}.Read(NewEnvBucket())
+	if err != nil {
+		t.Fatalf("Unexpected error while reading env %s", err.Error())
+	}
+	if config.MemoryCache.MaxBytes != 64*1024*1024 || config.MemoryCache.MaxEntries != 0 {
+		t.Errorf("MemoryCache defaults incorrect, got: %+v", config.MemoryCache)
+	}
+
+	env := NewEnvBucket()
+	env.Setenv("memory_cache_max_bytes", "1048576")
+	env.Setenv("memory_cache_max_entries", "500")
+
+	config, err = ReadConfig{}.Read(env)
+	if err != nil {
+		t.Fatalf("Unexpected error while reading env %s", err.Error())
+	}
+	if config.MemoryCache.MaxBytes != 1048576 || config.MemoryCache.MaxEntries != 500 {
+		t.Errorf("MemoryCache incorrect, got: %+v", config.MemoryCache)
+	}
+
+	env.Setenv("memory_cache_max_bytes", "0")
+	if _, err := (ReadConfig{}).Read(env); err == nil {
+		t.Fatalf("want an error for a zero memory_cache_max_bytes")
+	}
+}
+
+func TestRead_AsyncFlowConfig(t *testing.T) {
+	config, err := ReadConfig{}.Read(NewEnvBucket())
+	if err != nil {
+		t.Fatalf("Unexpected error while reading env %s", err.Error())
+	}
+	if config.AsyncFlows.Workers != 10 || config.AsyncFlows.QueueSize != 100 {
+		t.Errorf("AsyncFlows defaults incorrect, got: %+v", config.AsyncFlows)
+	}
+
+	env := NewEnvBucket()
+	env.Setenv("async_flow_workers", "4")
+	env.Setenv("async_flow_queue_size", "0")
+
+	config, err = ReadConfig{}.Read(env)
+	if err != nil {
+		t.Fatalf("Unexpected error while reading env %s", err.Error())
+	}
+	if config.AsyncFlows.Workers != 4 || config.AsyncFlows.QueueSize != 0 {
+		t.Errorf("AsyncFlows incorrect, got: %+v", config.AsyncFlows)
+	}
+
+	env.Setenv("async_flow_workers", "0")
+	if _, err := (ReadConfig{}).Read(env); err == nil {
+		t.Fatalf("want an error for zero async_flow_workers")
+	}
+
+	env.Setenv("async_flow_workers", "-1")
+	if _, err := (ReadConfig{}).Read(env); err == nil {
+		t.Fatalf("want an error for negative async_flow_workers")
+	}
+
+	env.Setenv("async_flow_workers", "4")
+	env.Setenv("async_flow_queue_size", "-1")
+	if _, err := (ReadConfig{}).Read(env); err == nil {
+		t.Fatalf("want an error for a negative async_flow_queue_size")
+	}
+
+	env.Setenv("async_flow_queue_size", "many")
+	if _, err := (ReadConfig{}).Read(env); err == nil {
+		t.Fatalf("want an error for a malformed async_flow_queue_size")
+	}
+}
+
+func TestRead_ExecutionHistory(t *testing.T) {
+	config, err := ReadConfig{}.Read(NewEnvBucket())
+	if err != nil {
+		t.Fatalf("Unexpected error while reading env %s", err.Error())
+	}
+	if config.ExecutionHistory != 100 {
+		t.Errorf("ExecutionHistory default incorrect, got: %d", config.ExecutionHistory)
+	}
+
+	env := NewEnvBucket()
+	env.Setenv("flow_execution_history", "0")
+
+	config, err = ReadConfig{}.Read(env)
+	if err != nil {
+		t.Fatalf("Unexpected error while reading env %s", err.Error())
+	}
+	if config.ExecutionHistory != 0 {
+		t.Errorf("want the history to be disabled, got: %d", config.ExecutionHistory)
+	}
+}
+
+func TestRead_DecisionHistory(t *testing.T) {
+	config, err := ReadConfig{}.Read(NewEnvBucket())
+	if err != nil {
+		t.Fatalf("Unexpected error while reading env %s", err.Error())
+	}
+	if config.DecisionHistory != 500 {
+		t.Errorf("DecisionHistory default incorrect, got: %d", config.DecisionHistory)
+	}
+
+	env := NewEnvBucket()
+	env.Setenv("orchestrator_decision_history", "50")
+
+	config, err = ReadConfig{}.Read(env)
+	if err != nil {
+		t.Fatalf("Unexpected error while reading env %s", err.Error())
+	}
+	if config.DecisionHistory != 50 {
+		t.Errorf("want a history of 50, got: %d", config.DecisionHistory)
+	}
+}
+
+func TestRead_OrchestratorConfig(t *testing.T) {
+	config, err := ReadConfig{}.Read(NewEnvBucket())
+	if err != nil {
+		t.Fatalf("Unexpected error while reading env %s", err.Error())
+	}
+	faasConfig := config.FaaSConfig
+	if faasConfig.EnableIntelligentOrchestrator || faasConfig.OrchestratorEvalInterval != 10 || faasConfig.OrchestratorStabilityPeriod != 30 ||
+		faasConfig.OrchestratorSwitchThreshold != 0.05 || faasConfig.OrchestratorMaxMemory != 8 {
+		t.Errorf("Orchestrator defaults incorrect, got: %+v", faasConfig)
+	}
+
+	// the settings apply to Redis, the default caching method, as much as
+	// to PaperCache
+	env := NewEnvBucket()
+	env.Setenv("ENABLE_INTELLIGENT_ORCHESTRATOR", "true")
+	env.Setenv("ORCHESTRATOR_EVAL_INTERVAL", "5")
+	env.Setenv("ORCHESTRATOR_STABILITY_PERIOD", "60")
+	env.Setenv("ORCHESTRATOR_SWITCH_THRESHOLD", "0.1")
+	env.Setenv("ORCHESTRATOR_MAX_MEMORY", "2")
+
+	config, err = ReadConfig{}.Read(env)
+	if err != nil {
+		t.Fatalf("Unexpected error while reading env %s", err.Error())
+	}
+	faasConfig = config.FaaSConfig
+	if !faasConfig.EnableIntelligentOrchestrator || faasConfig.OrchestratorEvalInterval != 5 || faasConfig.OrchestratorStabilityPeriod != 60 ||
+		faasConfig.OrchestratorSwitchThreshold != 0.1 || faasConfig.OrchestratorMaxMemory != 2 {
+		t.Errorf("Orchestrator config incorrect, got: %+v", faasConfig)
+	}
+
+	env.Setenv("ORCHESTRATOR_EVAL_INTERVAL", "-1")
+	env.Setenv("ORCHESTRATOR_SWITCH_THRESHOLD", "2")
+	config, _ = ReadConfig{}.Read(env)
+	if config.FaaSConfig.OrchestratorEvalInterval != 10 || config.FaaSConfig.OrchestratorSwitchThreshold != 0.05 {
+		t.Errorf("want the defaults for invalid values, got: %+v", config.FaaSConfig)
+	}
+}
+
+func TestRead_TracingConfig(t *testing.T) {
+	config, err := ReadConfig{}.Read(NewEnvBucket())
+	if err != nil {
+		t.Fatalf("Unexpected error while reading env %s", err.Error())
+	}
+	if config.Tracing.Exporter != "none" || config.Tracing.Protocol != "http/protobuf" || config.Tracing.Endpoint != "http://localhost:4318/v1/traces" || config.Tracing.ServiceName != "faas-netes" {
+		t.Errorf("Tracing defaults incorrect, got: %+v", config.Tracing)
+	}
+
+	env := NewEnvBucket()
+	env.Setenv("OTEL_TRACES_EXPORTER", "otlp")
+	env.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4318/")
+	env.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "authorization=Bearer abc, tenant=a")
+	env.Setenv("OTEL_SERVICE_NAME", "gateway")
+
+	config, err = ReadConfig{}.Read(env)
+	if err != nil {
+		t.Fatalf("Unexpected error while reading env %s", err.Error())
+	}
+	if config.Tracing.Endpoint != "http://collector:4318/v1/traces" {
+		t.Errorf("want the traces path appended to the endpoint, got: %s", config.Tracing.Endpoint)
+	}
+	if config.Tracing.Headers["authorization"] != "Bearer abc" || config.Tracing.Headers["tenant"] != "a" || config.Tracing.ServiceName != "gateway" {
+		t.Errorf("Tracing config incorrect, got: %+v", config.Tracing)
+	}
+
+	env.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "https://traces.example.com/otlp")
+	config, _ = ReadConfig{}.Read(env)
+	if config.Tracing.Endpoint != "https://traces.example.com/otlp" {
+		t.Errorf("want the traces endpoint to be used as it is, got: %s", config.Tracing.Endpoint)
+	}
+
+	env.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "http/json")
+	config, _ = ReadConfig{}.Read(env)
+	if config.Tracing.Protocol != "http/json" {
+		t.Errorf("want the http/json protocol, got: %s", config.Tracing.Protocol)
+	}
+	env.Setenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL", "http/protobuf")
+	config, _ = ReadConfig{}.Read(env)
+	if config.Tracing.Protocol != "http/protobuf" {
+		t.Errorf("want the traces protocol to take precedence, got: %s", config.Tracing.Protocol)
+	}
+
+	for key, value := range map[string]string{
+		"OTEL_TRACES_EXPORTER":        "zipkin",
+		"OTEL_EXPORTER_OTLP_PROTOCOL": "grpc",
+		"OTEL_EXPORTER_OTLP_HEADERS":  "authorization",
+	} {
+		env := NewEnvBucket()
+		env.Setenv(key, value)
+		if _, err := (ReadConfig{}).Read(env); err == nil {
+			t.Errorf("want an error for %s=%s", key, value)
+		}
+	}
+}
+
+func TestRead_CacheTraceConfig(t *testing.T) {
+	config, err := ReadConfig{}.Read(NewEnvBucket())
+	if err != nil {
+		t.Fatalf("Unexpected error while reading env %s", err.Error())
+	}
+	if config.CacheTrace.Sink != "none" || config.CacheTrace.SampleRate != 1 || config.CacheTrace.MaxFiles != 3 || config.CacheTrace.MaxFileBytes != 100*1024*1024 {
+		t.Errorf("CacheTrace defaults incorrect, got: %+v", config.CacheTrace)
+	}
+
+	env := NewEnvBucket()
+	env.Setenv("cache_trace", "http")
+	env.Setenv("cache_trace_url", "https://traces.example.com/reads")
+	env.Setenv("cache_trace_headers", "authorization=Bearer abc")
+	env.Setenv("cache_trace_sample_rate", "0.25")
+
+	config, err = ReadConfig{}.Read(env)
+	if err != nil {
+		t.Fatalf("Unexpected error while reading env %s", err.Error())
+	}
+	if config.CacheTrace.URL != "https://traces.example.com/reads" || config.CacheTrace.Headers["authorization"] != "Bearer abc" || config.CacheTrace.SampleRate != 0.25 {
+		t.Errorf("CacheTrace config incorrect, got: %+v", config.CacheTrace)
+	}
+
+	for _, values := range []map[string]string{
+		{"cache_trace": "kafka"},
+		{"cache_trace": "http"},
+		{"cache_trace": "file", "cache_trace_max_file_bytes": "0"},
+		{"cache_trace": "file", "cache_trace_sample_rate": "0"},
+		{"cache_trace": "file", "cache_trace_sample_rate": "1.5"},
+	} {
+		env := NewEnvBucket()
+		for key, value := range values {
+			env.Setenv(key, value)
+		}
+		if _, err := (ReadConfig{}).Read(env); err == nil {
+			t.Errorf("want an error for %v", values)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package flows
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	fhttputil "github.com/danenherdi/faas-provider/httputil"
+	"github.com/danenherdi/faas-provider/types"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// callIDHeader identifies an asynchronous invocation, it is returned
+	// when the flow is accepted and sent along with its callback
+	callIDHeader = "X-Call-Id"
+	// callbackURLHeader is the URL which receives the response of a flow
+	// invoked asynchronously
+	callbackURLHeader = "X-Callback-Url"
+	// functionNameHeader and functionStatusHeader tell the callback which
+	// flow was invoked and the status of its response, in the same way as
+	// the OpenFaaS queue-worker
+	functionNameHeader   = "X-Function-Name"
+	functionStatusHeader = "X-Function-Status"
+
+	// maxAsyncBodySize limits the body of each queued request, as the body
+	// is held in memory until a worker runs the flow
+	maxAsyncBodySize = 1024 * 1024
+)
+
+// ErrQueueFull is returned by AsyncQueue.Queue when every worker is busy
+// and the queue holds as many requests as it can
+var ErrQueueFull = errors.New("the queue for asynchronous flows is full")
+
+// AsyncQueue runs the flows requested through /async-flow in a bounded pool
+// of in-process workers, then POSTs each flow's response to its callback
+// URL. It implements types.RequestQueuer.
+type AsyncQueue struct {
+	requests chan *types.QueueRequest
+	workers  int
+
+	// flow is the handler of /flow, which runs each queued request
+	flow    http.Handler
+	client  *http.Client
+	verbose bool
+
+	start sync.Once
+}
+
+// NewAsyncQueue creates a queue of up to size requests which are run by
+// the given number of workers once the queue is started
+func NewAsyncQueue(flow http.Handler, workers, size int, verbose bool) *AsyncQueue {
+	return &AsyncQueue{
+		requests: make(chan *types.QueueRequest, size),
+		workers:  workers,
+		flow:     flow,
+		client:   &http.Client{Timeout: 30 * time.Second},
+		verbose:  verbose,
+	}
+}
+
+// Start runs the workers until ctx is done, requests which are queued when
+// ctx is done are not run
+func (q *AsyncQueue) Start(ctx context.Context) {
+	q.start.Do(func() {
+		for i := 0; i < q.workers; i++ {
+			go q.work(ctx)
+		}
+	})
+}
+
+// Queue adds a request to the queue without waiting, ErrQueueFull is
+// returned when there is no room for it
+func (q *AsyncQueue) Queue(req *types.QueueRequest) error {
+	select {
+	case q.requests <- req:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (q *AsyncQueue) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case req := <-q.requests:
+			q.run(ctx, req)
+		}
+	}
+}
+
+// run invokes the flow of a queued request and sends its response to the
+// request's callback URL, if it has one
+func (q *AsyncQueue) run(ctx context.Context, req *types.QueueRequest) {
+	callID := req.Header.Get(callIDHeader)
+	started := time.Now()
+
+	r, err := http.NewRequestWithContext(ctx, req.Method, req.Path, bytes.NewReader(req.Body))
+	if err != nil {
+		log.Printf("error creating the request for asynchronous flow %s [%s]: %s", req.Function, callID, err.Error())
+		return
+	}
+	r.Header = req.Header.Clone()
+	r.Host = req.Host
+	r.URL.RawQuery = req.QueryString
+	r = mux.SetURLVars(r, map[string]string{
+		"name":   req.Function,
+		"params": strings.TrimPrefix(strings.TrimPrefix(req.Path, "/flow/"+req.Function), "/"),
+	})
+
+	res := newBufferedResponse()
+	q.flow.ServeHTTP(res, r)
+
+	if q.verbose {
+		log.Printf("asynchronous flow %s [%s] returned %d in %.4fs", req.Function, callID, res.status, time.Since(started).Seconds())
+	}
+
+	if req.CallbackURL == nil {
+		return
+	}
+	if err := q.callback(ctx, req, callID, res); err != nil {
+		log.Printf("error sending the callback of asynchronous flow %s [%s]: %s", req.Function, callID, err.Error())
+	}
+}
+
+// callback POSTs the response of a flow to the request's callback URL
+func (q *AsyncQueue) callback(ctx context.Context, req *types.QueueRequest, callID string, res *bufferedResponse) error {
+	callback, err := http.NewRequestWithContext(ctx, http.MethodPost, req.CallbackURL.String(), bytes.NewReader(res.body.Bytes()))
+	if err != nil {
+		return err
+	}
+
+	copyHeaders(callback.Header, &res.header)
+	callback.Header.Set(callIDHeader, callID)
+	callback.Header.Set(functionNameHeader, req.Function)
+	callback.Header.Set(functionStatusHeader, strconv.Itoa(res.status))
+
+	resp, err := q.client.Do(callback)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// MakeAsyncHandler accepts a request to invoke a flow asynchronously. The
+// request is queued and 202 Accepted is returned straight away with the
+// call's X-Call-Id, the flow's response is later POSTed to the URL in the
+// request's X-Callback-Url header. A sub-path which follows the flow's name
+// is passed on as it is by /flow, and 413 is returned for a body larger
+// than maxAsyncBodySize.
+func MakeAsyncHandler(queue types.RequestQueuer, lookup Lookup) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil {
+			defer r.Body.Close()
+		}
+
+		name := mux.Vars(r)["name"]
+		if len(name) == 0 {
+			fhttputil.Errorf(w, http.StatusBadRequest, "Provide flow name in the request path")
+			return
+		}
+
+		if _, err := lookup.Get(name); err != nil {
+			if IsNotFound(err) {
+				fhttputil.Errorf(w, http.StatusNotFound, "Unable to find flow: %s", name)
+				return
+			}
+			fhttputil.Errorf(w, http.StatusInternalServerError, "Unable to look up flow: %s", err.Error())
+			return
+		}
+
+		var callbackURL *url.URL
+		if value := r.Header.Get(callbackURLHeader); len(value) > 0 {
+			u, err := url.Parse(value)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+				fhttputil.Errorf(w, http.StatusBadRequest, "%s must be an http or https URL", callbackURLHeader)
+				return
+			}
+			callbackURL = u
+		}
+
+		var body []byte
+		if r.Body != nil {
+			var err error
+			if body, err = io.ReadAll(http.MaxBytesReader(w, r.Body, maxAsyncBodySize)); err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					fhttputil.Errorf(w, http.StatusRequestEntityTooLarge, "Request body must not be larger than %d bytes", maxAsyncBodySize)
+					return
+				}
+				fhttputil.Errorf(w, http.StatusBadRequest, "Unable to read request body: %s", err.Error())
+				return
+			}
+		}
+
+		callID := r.Header.Get(callIDHeader)
+		if len(callID) == 0 {
+			callID = uuid.New().String()
+		}
+
+		header := r.Header.Clone()
+		header.Set(callIDHeader, callID)
+		header.Del(callbackURLHeader)
+
+		err := queue.Queue(&types.QueueRequest{
+			Header:      header,
+			Host:        r.Host,
+			Body:        body,
+			Method:      http.MethodPost,
+			Path:        strings.TrimSuffix("/flow/"+name+"/"+mux.Vars(r)["params"], "/"),
+			QueryString: r.URL.RawQuery,
+			Function:    name,
+			CallbackURL: callbackURL,
+		})
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrQueueFull) {
+				status = http.StatusTooManyRequests
+			}
+			fhttputil.Errorf(w, status, "Unable to queue flow %s: %s", name, err.Error())
+			return
+		}
+
+		w.Header().Set(callIDHeader, callID)
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
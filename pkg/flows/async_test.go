@@ -0,0 +1,207 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package flows
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danenherdi/faas-provider/types"
+	"github.com/gorilla/mux"
+	v1 "github.com/openfaas/faas-netes/pkg/apis/openfaas/v1"
+)
+
+// callback is a request received by a callback server
+type callback struct {
+	header http.Header
+	body   string
+}
+
+func newCallbackServer() (*httptest.Server, chan callback) {
+	received := make(chan callback, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- callback{header: r.Header.Clone(), body: string(body)}
+	}))
+	return server, received
+}
+
+func serveAsyncFlow(handler http.HandlerFunc, name string, header http.Header, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/async-flow/"+name, strings.NewReader(body))
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req = mux.SetURLVars(req, map[string]string{"name": name})
+
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+func Test_MakeAsyncHandler_PostsResponseToCallback(t *testing.T) {
+	server, received := newCallbackServer()
+	defer server.Close()
+
+	flow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(mux.Vars(r)["name"] + ":" + string(body) + ":" + r.URL.RawQuery))
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	queue := NewAsyncQueue(flow, 1, 1, false)
+	queue.Start(ctx)
+
+	lookup, _ := newTestLookup(t, "openfaas-fn", map[string]v1.FlowSpec{"checkout": {}})
+	handler := MakeAsyncHandler(queue, lookup)
+
+	req := httptest.NewRequest(http.MethodPost, "/async-flow/checkout?cart=1", strings.NewReader(`{"user":"alex"}`))
+	req.Header.Set(callbackURLHeader, server.URL)
+	req = mux.SetURLVars(req, map[string]string{"name": "checkout"})
+
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("want 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+	callID := rr.Header().Get(callIDHeader)
+	if len(callID) == 0 {
+		t.Fatalf("want a call ID")
+	}
+
+	select {
+	case got := <-received:
+		if got.body != `checkout:{"user":"alex"}:cart=1` {
+			t.Errorf("want the flow's response, got %q", got.body)
+		}
+		if got.header.Get(callIDHeader) != callID {
+			t.Errorf("want call ID %s, got %q", callID, got.header.Get(callIDHeader))
+		}
+		if got.header.Get(functionNameHeader) != "checkout" {
+			t.Errorf("want the flow's name, got %q", got.header.Get(functionNameHeader))
+		}
+		if got.header.Get(functionStatusHeader) != "201" {
+			t.Errorf("want the flow's status, got %q", got.header.Get(functionStatusHeader))
+		}
+		if got.header.Get("Content-Type") != "application/json" {
+			t.Errorf("want the flow's Content-Type, got %q", got.header.Get("Content-Type"))
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("want a callback")
+	}
+}
+
+func Test_MakeAsyncHandler_KeepsCallID(t *testing.T) {
+	queue := &testQueue{}
+	lookup, _ := newTestLookup(t, "openfaas-fn", map[string]v1.FlowSpec{"checkout": {}})
+
+	rr := serveAsyncFlow(MakeAsyncHandler(queue, lookup), "checkout", http.Header{callIDHeader: {"abc"}}, "{}")
+	if rr.Code != http.StatusAccepted || rr.Header().Get(callIDHeader) != "abc" {
+		t.Fatalf("want 202 with the caller's call ID, got %d %q", rr.Code, rr.Header().Get(callIDHeader))
+	}
+	if len(queue.requests) != 1 || queue.requests[0].Function != "checkout" || queue.requests[0].CallbackURL != nil {
+		t.Fatalf("want one request queued without a callback, got %+v", queue.requests)
+	}
+}
+
+func Test_MakeAsyncHandler_PassesSubPath(t *testing.T) {
+	params := make(chan string, 1)
+	flow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params <- mux.Vars(r)["params"]
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	queue := NewAsyncQueue(flow, 1, 1, false)
+	queue.Start(ctx)
+
+	lookup, _ := newTestLookup(t, "openfaas-fn", map[string]v1.FlowSpec{"report": {}})
+	req := httptest.NewRequest(http.MethodPost, "/async-flow/report/eu/2024", strings.NewReader("{}"))
+	req = mux.SetURLVars(req, map[string]string{"name": "report", "params": "eu/2024"})
+
+	rr := httptest.NewRecorder()
+	MakeAsyncHandler(queue, lookup)(rr, req)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("want 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	select {
+	case got := <-params:
+		if got != "eu/2024" {
+			t.Fatalf("want the sub-path passed to the flow, got %q", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("want the flow to run")
+	}
+}
+
+func Test_MakeAsyncHandler_Rejects(t *testing.T) {
+	lookup, _ := newTestLookup(t, "openfaas-fn", map[string]v1.FlowSpec{"checkout": {}})
+
+	block := make(chan struct{})
+	defer close(block)
+	flow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the worker holds one request and the queue another
+	queue := NewAsyncQueue(flow, 1, 1, false)
+	queue.Start(ctx)
+	handler := MakeAsyncHandler(queue, lookup)
+
+	if rr := serveAsyncFlow(handler, "checkout", nil, "{}"); rr.Code != http.StatusAccepted {
+		t.Fatalf("want 202, got %d", rr.Code)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(queue.requests) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if rr := serveAsyncFlow(handler, "checkout", nil, "{}"); rr.Code != http.StatusAccepted {
+		t.Fatalf("want 202, got %d", rr.Code)
+	}
+
+	cases := []struct {
+		name   string
+		flow   string
+		header http.Header
+		body   string
+		want   int
+	}{
+		{"queue full", "checkout", nil, "{}", http.StatusTooManyRequests},
+		{"unknown flow", "missing", nil, "{}", http.StatusNotFound},
+		{"invalid callback", "checkout", http.Header{callbackURLHeader: {"ftp://example.com"}}, "{}", http.StatusBadRequest},
+		{"body too large", "checkout", nil, strings.Repeat("a", maxAsyncBodySize+1), http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if rr := serveAsyncFlow(handler, tc.flow, tc.header, tc.body); rr.Code != tc.want {
+				t.Fatalf("want %d, got %d: %s", tc.want, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+// testQueue records the requests it is given
+type testQueue struct {
+	requests []*types.QueueRequest
+}
+
+func (q *testQueue) Queue(req *types.QueueRequest) error {
+	q.requests = append(q.requests, req)
+	return nil
+}
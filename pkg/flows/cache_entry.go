@@ -0,0 +1,146 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package flows
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	v1 "github.com/openfaas/faas-netes/pkg/apis/openfaas/v1"
+)
+
+// cachedHeaders are the headers of a response which are kept along with
+// it in the cache, other headers such as Date or Set-Cookie belong to a
+// single response and are not replayed
+var cachedHeaders = []string{
+	"Content-Type",
+	"Content-Encoding",
+	"Content-Language",
+	"Content-Disposition",
+	"Etag",
+	"Last-Modified",
+}
+
+const (
+	// cacheStatusHeader tells the caller whether a response was served
+	// from the cache: HIT, STALE or MISS
+	cacheStatusHeader = "X-Cache"
+	// ageHeader is the number of seconds since a cached response was
+	// created
+	ageHeader = "Age"
+)
+
+// cacheEntry is the envelope stored in the cache for a flow's response
+type cacheEntry struct {
+	// Created is when the response was cached, it decides whether the
+	// entry is fresh or stale
+	Created time.Time `json:"created"`
+	// Status is the status code of the response
+	Status int `json:"status,omitempty"`
+	// Header holds the cachedHeaders of the response
+	Header http.Header `json:"header,omitempty"`
+	// Body is the response of the flow's function
+	Body []byte `json:"body"`
+}
+
+// newCacheEntry creates the entry for a response created at now
+func newCacheEntry(res *bufferedResponse, now time.Time) cacheEntry {
+	entry := cacheEntry{
+		Created: now,
+		Status:  res.status,
+		Body:    res.body.Bytes(),
+	}
+
+	for _, name := range cachedHeaders {
+		if values := res.header.Values(name); len(values) > 0 {
+			if entry.Header == nil {
+				entry.Header = http.Header{}
+			}
+			entry.Header[name] = values
+		}
+	}
+	return entry
+}
+
+// response replays the entry with the X-Cache and Age headers which tell
+// the caller it came from the cache
+func (e *cacheEntry) response(now time.Time, freshness cacheFreshness) *bufferedResponse {
+	res := newBufferedResponse()
+	copyHeaders(res.header, &e.Header)
+
+	// entries cached before the status and headers were kept were always
+	// JSON responses with a 200
+	status := e.Status
+	if status == 0 {
+		status = http.StatusOK
+		res.header.Set("Content-Type", "application/json")
+	}
+
+	cacheStatus := "HIT"
+	if freshness == cacheStale {
+		cacheStatus = "STALE"
+	}
+	res.header.Set(cacheStatusHeader, cacheStatus)
+
+	age := now.Sub(e.Created)
+	if age < 0 {
+		age = 0
+	}
+	res.header.Set(ageHeader, strconv.FormatInt(int64(age/time.Second), 10))
+
+	res.WriteHeader(status)
+	res.Write(e.Body)
+	return res
+}
+
+// cacheableStatus returns true when a response with status may be cached
+// for flow, by default only 2xx responses are cached
+func cacheableStatus(flow *v1.FlowSpec, status int) bool {
+	if len(flow.CacheStatusCodes) == 0 {
+		return status >= 200 && status <= 299
+	}
+
+	for _, code := range flow.CacheStatusCodes {
+		if int(code) == status {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheFreshness describes a cached response at the time it is read
+type cacheFreshness int
+
+const (
+	cacheExpired cacheFreshness = iota
+	cacheFresh
+	cacheStale
+)
+
+// freshness returns cacheFresh within the flow's CacheTTL and cacheStale
+// within the StaleTTL which follows it
+func (e *cacheEntry) freshness(now time.Time, cacheTTL, staleTTL time.Duration) cacheFreshness {
+	age := now.Sub(e.Created)
+	switch {
+	case age < cacheTTL:
+		return cacheFresh
+	case age < cacheTTL+staleTTL:
+		return cacheStale
+	}
+	return cacheExpired
+}
+
+func encodeCacheEntry(entry cacheEntry) ([]byte, error) {
+	return json.Marshal(entry)
+}
+
+func decodeCacheEntry(data []byte) (*cacheEntry, error) {
+	entry := &cacheEntry{}
+	if err := json.Unmarshal(data, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
@@ -0,0 +1,106 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package flows
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	v1 "github.com/openfaas/faas-netes/pkg/apis/openfaas/v1"
+)
+
+func Test_cacheEntry_freshness(t *testing.T) {
+	now := time.Now()
+
+	cases := []struct {
+		age      time.Duration
+		staleTTL time.Duration
+		want     cacheFreshness
+	}{
+		{age: 0, want: cacheFresh},
+		{age: 59 * time.Second, want: cacheFresh},
+		{age: time.Minute, want: cacheExpired},
+		{age: time.Minute, staleTTL: 30 * time.Second, want: cacheStale},
+		{age: 90 * time.Second, staleTTL: 30 * time.Second, want: cacheExpired},
+	}
+
+	for _, c := range cases {
+		entry := cacheEntry{Created: now.Add(-c.age)}
+		if got := entry.freshness(now, time.Minute, c.staleTTL); got != c.want {
+			t.Errorf("want %d for age %s and stale TTL %s, got: %d", c.want, c.age, c.staleTTL, got)
+		}
+	}
+}
+
+func Test_cacheEntry_RoundTrip(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	data, err := encodeCacheEntry(cacheEntry{Created: created, Body: []byte(`{"rate":1}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := decodeCacheEntry(data)
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err)
+	}
+	if !entry.Created.Equal(created) || string(entry.Body) != `{"rate":1}` {
+		t.Fatalf("unexpected entry: %+v", entry)
+	}
+
+	if _, err := decodeCacheEntry([]byte("not an entry")); err == nil {
+		t.Fatalf("want an error for a value which is not an entry")
+	}
+}
+
+func Test_cacheEntry_response(t *testing.T) {
+	now := time.Now()
+
+	res := newBufferedResponse()
+	res.Header().Set("Content-Type", "text/csv")
+	res.Header().Set("Set-Cookie", "session=1")
+	res.WriteHeader(http.StatusCreated)
+	res.Write([]byte("a,b"))
+
+	entry := newCacheEntry(res, now.Add(-90*time.Second))
+	if _, ok := entry.Header["Set-Cookie"]; ok {
+		t.Fatalf("want only the cached headers to be kept, got: %v", entry.Header)
+	}
+
+	replayed := entry.response(now, cacheFresh)
+	if replayed.status != http.StatusCreated || replayed.body.String() != "a,b" {
+		t.Fatalf("want the original status and body, got: %d %q", replayed.status, replayed.body.String())
+	}
+
+	want := map[string]string{
+		"Content-Type":    "text/csv",
+		cacheStatusHeader: "HIT",
+		ageHeader:         "90",
+	}
+	for name, value := range want {
+		if got := replayed.header.Get(name); got != value {
+			t.Errorf("want %s: %s, got: %q", name, value, got)
+		}
+	}
+
+	if got := entry.response(now, cacheStale).header.Get(cacheStatusHeader); got != "STALE" {
+		t.Errorf("want a stale entry to be marked STALE, got: %q", got)
+	}
+}
+
+func Test_cacheableStatus(t *testing.T) {
+	byDefault := &v1.FlowSpec{}
+	if !cacheableStatus(byDefault, http.StatusOK) || !cacheableStatus(byDefault, http.StatusNoContent) {
+		t.Errorf("want 2xx responses to be cached by default")
+	}
+	if cacheableStatus(byDefault, http.StatusNotFound) || cacheableStatus(byDefault, http.StatusInternalServerError) {
+		t.Errorf("want other responses not to be cached by default")
+	}
+
+	listed := &v1.FlowSpec{CacheStatusCodes: []int32{200, 404}}
+	if !cacheableStatus(listed, http.StatusNotFound) || cacheableStatus(listed, http.StatusCreated) {
+		t.Errorf("want only the listed status codes to be cached")
+	}
+}
@@ -0,0 +1,165 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package flows
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/openfaas/faas-netes/pkg/caching"
+)
+
+// maxInvalidationBodySize limits the size of the args accepted to invalidate
+// a cached response
+const maxInvalidationBodySize = 64 * 1024
+
+// CacheInvalidationResponse reports how many cached responses were removed
+type CacheInvalidationResponse struct {
+	Deleted int `json:"deleted"`
+	// Wiped is true when the whole cache was wiped, along with any entry
+	// which was not a flow response of the namespace
+	Wiped bool `json:"wiped,omitempty"`
+}
+
+// MakeCacheInvalidationHandler removes the cached responses of the flow
+// named in the path. Without a body, every response cached for the flow is
+// removed, whichever version of its definition it was cached for. When the
+// body holds a JSON object of args, only the response cached for those args
+// under the current definition is removed. The headers and query string of
+// the request, and the sub-path which follows /cache/, are used for the key
+// just as they are when the flow is invoked. A key which includes the
+// outputs of children can not be derived from args, so those flows can only
+// have all of their responses removed. 413 is returned when the body is
+// larger than maxInvalidationBodySize.
+func MakeCacheInvalidationHandler(namespace string, cacheClient caching.Client, lookup Lookup) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if cacheClient == nil {
+			http.Error(w, "Caching is not enabled", http.StatusNotImplemented)
+			return
+		}
+
+		name := mux.Vars(r)["name"]
+		if name == "" {
+			http.Error(w, "Provide flow name in the request path", http.StatusBadRequest)
+			return
+		}
+
+		var body []byte
+		if r.Body != nil {
+			defer r.Body.Close()
+
+			var err error
+			if body, err = io.ReadAll(http.MaxBytesReader(w, r.Body, maxInvalidationBodySize)); err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					http.Error(w, fmt.Sprintf("Request body must not be larger than %d bytes", maxInvalidationBodySize), http.StatusRequestEntityTooLarge)
+					return
+				}
+				http.Error(w, "Unable to read request body", http.StatusBadRequest)
+				return
+			}
+		}
+
+		if len(bytes.TrimSpace(body)) == 0 {
+			prefix := strings.Join([]string{cacheKeyPrefix, namespace, name}, ":") + ":"
+
+			deleted, err := cacheClient.DelPrefix(r.Context(), prefix)
+			if err != nil {
+				log.Printf("error invalidating cache of flow %s: %s", name, err.Error())
+				http.Error(w, "Unable to invalidate cache", http.StatusInternalServerError)
+				return
+			}
+
+			writeInvalidationResponse(w, CacheInvalidationResponse{Deleted: deleted})
+			return
+		}
+
+		var args map[string]interface{}
+		if err := json.Unmarshal(body, &args); err != nil {
+			http.Error(w, "Request body must be a JSON object of args", http.StatusBadRequest)
+			return
+		}
+
+		flow, err := lookup.Get(name)
+		if err != nil {
+			if IsNotFound(err) {
+				http.Error(w, "Unable to find flow: "+name, http.StatusNotFound)
+				return
+			}
+			log.Printf("error looking up flow %s: %s", name, err.Error())
+			http.Error(w, "Unable to look up flow: "+name, http.StatusInternalServerError)
+			return
+		}
+
+		if keyedChildren(flow) {
+			http.Error(w, "The cache key of flow "+name+" includes the outputs of its children, invalidate without a body to remove every response of the flow", http.StatusBadRequest)
+			return
+		}
+
+		key, err := flowCacheKey(namespace, name, flow, r, args, nil)
+		if err != nil {
+			log.Printf("error creating cache key of flow %s: %s", name, err.Error())
+			http.Error(w, "Unable to create cache key", http.StatusInternalServerError)
+			return
+		}
+
+		deleted, err := cacheClient.Del(r.Context(), key)
+		if err != nil {
+			log.Printf("error invalidating cache key of flow %s: %s", name, err.Error())
+			http.Error(w, "Unable to invalidate cache", http.StatusInternalServerError)
+			return
+		}
+
+		writeInvalidationResponse(w, CacheInvalidationResponse{Deleted: deleted})
+	}
+}
+
+// MakeCachePurgeHandler removes every cached response of the flows in the
+// namespace. A cache which can not list its keys, such as PaperCache, is
+// wiped instead, as DelPrefix would only reach the keys written by this
+// replica since it started.
+func MakeCachePurgeHandler(namespace string, cacheClient caching.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if cacheClient == nil {
+			http.Error(w, "Caching is not enabled", http.StatusNotImplemented)
+			return
+		}
+
+		if wiper, ok := cacheClient.(caching.Wiper); ok {
+			deleted, err := wiper.Wipe(r.Context())
+			if err != nil {
+				log.Printf("error wiping flow cache: %s", err.Error())
+				http.Error(w, "Unable to purge cache", http.StatusInternalServerError)
+				return
+			}
+
+			writeInvalidationResponse(w, CacheInvalidationResponse{Deleted: deleted, Wiped: true})
+			return
+		}
+
+		deleted, err := cacheClient.DelPrefix(r.Context(), cacheKeyPrefix+":"+namespace+":")
+		if err != nil {
+			log.Printf("error purging flow cache: %s", err.Error())
+			http.Error(w, "Unable to purge cache", http.StatusInternalServerError)
+			return
+		}
+
+		writeInvalidationResponse(w, CacheInvalidationResponse{Deleted: deleted})
+	}
+}
+
+func writeInvalidationResponse(w http.ResponseWriter, res CacheInvalidationResponse) {
+	jsonResp, _ := json.Marshal(res)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResp)
+}
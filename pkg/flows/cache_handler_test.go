@@ -0,0 +1,224 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package flows
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	v1 "github.com/openfaas/faas-netes/pkg/apis/openfaas/v1"
+	"github.com/openfaas/faas-netes/pkg/caching"
+)
+
+// mapCache is an in-memory caching.Client for tests
+type mapCache struct {
+	lock    sync.Mutex
+	entries map[string][]byte
+}
+
+func newMapCache() *mapCache {
+	return &mapCache{entries: map[string][]byte{}}
+}
+
+func (c *mapCache) Get(ctx context.Context, key string) ([]byte, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	value, ok := c.entries[key]
+	if !ok {
+		return nil, caching.ErrNotFound
+	}
+	return value, nil
+}
+
+func (c *mapCache) SetEx(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.entries[key] = value
+	return nil
+}
+
+func (c *mapCache) Del(ctx context.Context, key string) (int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.entries[key]; !ok {
+		return 0, nil
+	}
+	delete(c.entries, key)
+	return 1, nil
+}
+
+func (c *mapCache) DelPrefix(ctx context.Context, prefix string) (int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	deleted := 0
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.entries, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func serveInvalidation(t *testing.T, handler http.HandlerFunc, name, params, body string) CacheInvalidationResponse {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodDelete, "/system/flows/"+name+"/cache/"+params, strings.NewReader(body))
+	req = mux.SetURLVars(req, map[string]string{"name": name, "params": params})
+
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("want status %d, got: %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var res CacheInvalidationResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatalf("unable to decode response: %s", err)
+	}
+	return res
+}
+
+func Test_MakeCacheInvalidationHandler(t *testing.T) {
+	specs := map[string]v1.FlowSpec{
+		"checkout": {Args: []string{"user"}, Caching: true},
+		"cart":     {Args: []string{"user"}, Caching: true},
+	}
+	lookup, _ := newTestLookup(t, "openfaas-fn", specs)
+
+	cache := newMapCache()
+	keyFor := func(name, user string) string {
+		spec := specs[name]
+		req := httptest.NewRequest(http.MethodPost, "/flow/"+name, nil)
+		key, err := flowCacheKey("openfaas-fn", name, &spec, req, map[string]interface{}{"user": user}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cache.SetEx(context.Background(), key, []byte(user), time.Minute)
+		return key
+	}
+
+	alex := keyFor("checkout", "alex")
+	keyFor("checkout", "sam")
+	cart := keyFor("cart", "alex")
+	cache.SetEx(context.Background(), "flow:openfaas-fn:checkout:oldversion:abc", []byte("old"), time.Minute)
+
+	handler := MakeCacheInvalidationHandler("openfaas-fn", cache, lookup)
+
+	if res := serveInvalidation(t, handler, "checkout", "", `{"user": "alex"}`); res.Deleted != 1 {
+		t.Fatalf("want 1 key deleted, got: %d", res.Deleted)
+	}
+	if _, err := cache.Get(context.Background(), alex); err == nil {
+		t.Fatalf("want key for the supplied args to be deleted")
+	}
+	if res := serveInvalidation(t, handler, "checkout", "", `{"user": "alex"}`); res.Deleted != 0 {
+		t.Fatalf("want a key which is not cached not to be counted, got: %d", res.Deleted)
+	}
+
+	if res := serveInvalidation(t, handler, "checkout", "", ""); res.Deleted != 2 {
+		t.Fatalf("want the remaining 2 keys of checkout deleted, got: %d", res.Deleted)
+	}
+	if _, err := cache.Get(context.Background(), cart); err != nil {
+		t.Fatalf("want keys of other flows to be kept")
+	}
+
+	purge := MakeCachePurgeHandler("openfaas-fn", cache)
+	rr := httptest.NewRecorder()
+	purge(rr, httptest.NewRequest(http.MethodDelete, "/system/flows/cache", nil))
+	if rr.Code != http.StatusOK || len(cache.entries) != 0 {
+		t.Fatalf("want every key purged, got status %d and %d keys", rr.Code, len(cache.entries))
+	}
+}
+
+func Test_MakeCacheInvalidationHandler_Path(t *testing.T) {
+	spec := v1.FlowSpec{Args: []string{"user"}, Caching: true, CacheKey: &v1.FlowCacheKey{Path: true}}
+	lookup, _ := newTestLookup(t, "openfaas-fn", map[string]v1.FlowSpec{"report": spec})
+
+	cache := newMapCache()
+	for _, params := range []string{"eu", "us"} {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/flow/report/"+params, nil), map[string]string{"name": "report", "params": params})
+		key, err := flowCacheKey("openfaas-fn", "report", &spec, req, map[string]interface{}{"user": "alex"}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cache.SetEx(context.Background(), key, []byte(params), time.Minute)
+	}
+
+	handler := MakeCacheInvalidationHandler("openfaas-fn", cache, lookup)
+	if res := serveInvalidation(t, handler, "report", "eu", `{"user": "alex"}`); res.Deleted != 1 {
+		t.Fatalf("want the key of the sub-path deleted, got: %d", res.Deleted)
+	}
+	if len(cache.entries) != 1 {
+		t.Fatalf("want the key of the other sub-path to be kept, got %d keys", len(cache.entries))
+	}
+}
+
+func Test_MakeCacheInvalidationHandler_BodyTooLarge(t *testing.T) {
+	lookup, _ := newTestLookup(t, "openfaas-fn", map[string]v1.FlowSpec{"checkout": {Args: []string{"user"}, Caching: true}})
+	handler := MakeCacheInvalidationHandler("openfaas-fn", newMapCache(), lookup)
+
+	body := `{"user": "` + strings.Repeat("a", maxInvalidationBodySize) + `"}`
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/system/flows/checkout/cache", strings.NewReader(body)), map[string]string{"name": "checkout"})
+
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("want status %d, got: %d", http.StatusRequestEntityTooLarge, rr.Code)
+	}
+}
+
+// wipingCache is a mapCache which can also be wiped, as PaperCache can
+type wipingCache struct {
+	*mapCache
+}
+
+func (c wipingCache) Wipe(ctx context.Context) (int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	deleted := len(c.entries)
+	c.entries = map[string][]byte{}
+	return deleted, nil
+}
+
+func Test_MakeCachePurgeHandler_Wipes(t *testing.T) {
+	cache := wipingCache{newMapCache()}
+	cache.SetEx(context.Background(), "flow:openfaas-fn:checkout:v1:abc", []byte("1"), time.Minute)
+	cache.SetEx(context.Background(), "flow:other:checkout:v1:abc", []byte("2"), time.Minute)
+
+	purge := MakeCachePurgeHandler("openfaas-fn", cache)
+	rr := httptest.NewRecorder()
+	purge(rr, httptest.NewRequest(http.MethodDelete, "/system/flows/cache", nil))
+
+	var res CacheInvalidationResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatalf("unable to decode response: %s", err)
+	}
+	if rr.Code != http.StatusOK || res.Deleted != 2 || !res.Wiped || len(cache.entries) != 0 {
+		t.Fatalf("want the whole cache wiped, got status %d, %+v and %d keys", rr.Code, res, len(cache.entries))
+	}
+}
+
+func Test_MakeCacheInvalidationHandler_CachingDisabled(t *testing.T) {
+	lookup, _ := newTestLookup(t, "openfaas-fn", nil)
+	handler := MakeCacheInvalidationHandler("openfaas-fn", nil, lookup)
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/system/flows/checkout/cache", nil), map[string]string{"name": "checkout"})
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	if rr.Code != http.StatusNotImplemented {
+		t.Fatalf("want status %d, got: %d", http.StatusNotImplemented, rr.Code)
+	}
+}
@@ -0,0 +1,92 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package flows
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// cacheTraceHeader lists the cacheable nodes of a flow which were read
+// from the cache while serving a request, for example:
+//
+//	X-Flow-Cache-Trace: checkout=miss, checkout/fx=hit, checkout/stock=stale
+//
+// Each node is named by its path of aliases from the flow which was
+// requested. A node served from the cache is not run, so its own children
+// are not listed.
+const cacheTraceHeader = "X-Flow-Cache-Trace"
+
+// cacheTrace records the cache result of each node of a request
+type cacheTrace struct {
+	lock  sync.Mutex
+	nodes map[string]cacheFreshness
+}
+
+func newCacheTrace() *cacheTrace {
+	return &cacheTrace{nodes: map[string]cacheFreshness{}}
+}
+
+// record notes the cache result of the node at path, a nil trace records
+// nothing
+func (t *cacheTrace) record(path string, freshness cacheFreshness) {
+	if t == nil {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.nodes[path] = freshness
+}
+
+// String formats the trace for cacheTraceHeader
+func (t *cacheTrace) String() string {
+	if t == nil {
+		return ""
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	nodes := make([]string, 0, len(t.nodes))
+	for _, path := range sortedKeys(t.nodes) {
+		nodes = append(nodes, path+"="+cacheResult(t.nodes[path]))
+	}
+	return strings.Join(nodes, ", ")
+}
+
+func cacheResult(freshness cacheFreshness) string {
+	switch freshness {
+	case cacheFresh:
+		return "hit"
+	case cacheStale:
+		return "stale"
+	}
+	return "miss"
+}
+
+type cacheTraceKey struct{}
+
+// withCacheTrace returns a context which records the cache results of the
+// nodes run with it in trace
+func withCacheTrace(ctx context.Context, trace *cacheTrace) context.Context {
+	return context.WithValue(ctx, cacheTraceKey{}, trace)
+}
+
+// cacheTraceFrom returns the trace of ctx, or nil
+func cacheTraceFrom(ctx context.Context) *cacheTrace {
+	trace, _ := ctx.Value(cacheTraceKey{}).(*cacheTrace)
+	return trace
+}
+
+// setCacheTrace adds the trace of ctx to the headers of w, when any node
+// was cacheable
+func setCacheTrace(w http.ResponseWriter, ctx context.Context) {
+	if trace := cacheTraceFrom(ctx).String(); len(trace) > 0 {
+		w.Header().Set(cacheTraceHeader, trace)
+	}
+}
@@ -0,0 +1,128 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package flows
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/danenherdi/faas-provider/types"
+	"github.com/gorilla/mux"
+	v1 "github.com/openfaas/faas-netes/pkg/apis/openfaas/v1"
+)
+
+// cacheKeyPrefix starts the key of every cached flow response
+const cacheKeyPrefix = "flow"
+
+// definitionVersionLength is the number of hex characters of the hash of a
+// flow's spec which are used as the version in its cache keys
+const definitionVersionLength = 16
+
+// cacheKeyInput is hashed to form the cache key of a request, the fields
+// are serialised as JSON so that map keys are always in the same order
+type cacheKeyInput struct {
+	Args    map[string]interface{} `json:"args"`
+	Headers map[string]string      `json:"headers,omitempty"`
+	Query   map[string][]string    `json:"query,omitempty"`
+	Path    string                 `json:"path,omitempty"`
+	// PathParts holds the selected segments of the path, an empty string
+	// for a segment which is not in the request
+	PathParts []string `json:"pathParts,omitempty"`
+	// Children maps the alias of each selected child to its output, null
+	// when the child was skipped
+	Children map[string][]byte `json:"children,omitempty"`
+}
+
+// flowCachePrefix returns the prefix shared by the cache keys of a flow,
+// in the form flow:<namespace>:<name>:<version>: where the version is
+// derived from the flow's spec, so that editing a flow invalidates the
+// responses cached for its previous definition.
+func flowCachePrefix(namespace, name string, flow *v1.FlowSpec) (string, error) {
+	specBytes, err := json.Marshal(flow)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(specBytes)
+	version := hex.EncodeToString(sum[:])[:definitionVersionLength]
+
+	return strings.Join([]string{cacheKeyPrefix, namespace, name, version}, ":") + ":", nil
+}
+
+// flowCacheKey derives the cache key of a request to a flow from the parts
+// of the request selected by flow.CacheKey. A flow invoked as the child of
+// another has no request of its own, r is nil and only its args are used.
+// children holds the outputs of the flow's children, it is only needed when
+// the key includes them, see keyedChildren.
+func flowCacheKey(namespace, name string, flow *v1.FlowSpec, r *http.Request, args map[string]interface{}, children map[string]*types.FlowOutput) (string, error) {
+	prefix, err := flowCachePrefix(namespace, name, flow)
+	if err != nil {
+		return "", err
+	}
+
+	input := cacheKeyInput{Args: args}
+
+	if spec := flow.CacheKey; spec != nil {
+		if len(spec.Args) > 0 {
+			input.Args = make(map[string]interface{}, len(spec.Args))
+			for _, arg := range spec.Args {
+				input.Args[arg] = args[arg]
+			}
+		}
+
+		if len(spec.Headers) > 0 && r != nil {
+			input.Headers = make(map[string]string, len(spec.Headers))
+			for _, header := range spec.Headers {
+				input.Headers[http.CanonicalHeaderKey(header)] = strings.Join(r.Header.Values(header), ",")
+			}
+		}
+
+		if len(spec.Query) > 0 && r != nil {
+			query := r.URL.Query()
+			input.Query = make(map[string][]string, len(spec.Query))
+			for _, key := range spec.Query {
+				input.Query[key] = query[key]
+			}
+		}
+
+		if spec.Path && r != nil {
+			input.Path = mux.Vars(r)["params"]
+		} else if len(spec.PathParts) > 0 && r != nil {
+			segments := strings.Split(strings.Trim(mux.Vars(r)["params"], "/"), "/")
+			input.PathParts = make([]string, len(spec.PathParts))
+			for i, part := range spec.PathParts {
+				if part >= 0 && int(part) < len(segments) {
+					input.PathParts[i] = segments[part]
+				}
+			}
+		}
+
+		if len(spec.Children) > 0 {
+			input.Children = make(map[string][]byte, len(spec.Children))
+			for _, alias := range spec.Children {
+				if output, ok := children[alias]; ok && output != nil {
+					input.Children[alias] = output.Data
+				} else {
+					input.Children[alias] = nil
+				}
+			}
+		}
+	}
+
+	inputBytes, err := json.Marshal(input)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(inputBytes)
+
+	return prefix + hex.EncodeToString(sum[:]), nil
+}
+
+// keyedChildren returns true when the cache key of flow includes the
+// outputs of its children, which must then run before the cache is read
+func keyedChildren(flow *v1.FlowSpec) bool {
+	return flow.CacheKey != nil && len(flow.CacheKey.Children) > 0
+}
@@ -0,0 +1,190 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package flows
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/danenherdi/faas-provider/types"
+	"github.com/gorilla/mux"
+	v1 "github.com/openfaas/faas-netes/pkg/apis/openfaas/v1"
+)
+
+func cacheKeyRequest(target string, params string, headers map[string]string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, target, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return mux.SetURLVars(req, map[string]string{"name": "checkout", "params": params})
+}
+
+func mustCacheKey(t *testing.T, flow *v1.FlowSpec, r *http.Request, args map[string]interface{}) string {
+	t.Helper()
+
+	key, err := flowCacheKey("openfaas-fn", "checkout", flow, r, args, nil)
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err)
+	}
+	return key
+}
+
+func Test_flowCacheKey_Prefix(t *testing.T) {
+	flow := &v1.FlowSpec{Args: []string{"user"}, Caching: true}
+	key := mustCacheKey(t, flow, cacheKeyRequest("/flow/checkout", "", nil), map[string]interface{}{"user": "alex"})
+
+	parts := strings.Split(key, ":")
+	if len(parts) != 5 || parts[0] != "flow" || parts[1] != "openfaas-fn" || parts[2] != "checkout" {
+		t.Fatalf("want key in the form flow:<namespace>:<name>:<version>:<hash>, got: %s", key)
+	}
+	if len(parts[3]) != definitionVersionLength || len(parts[4]) != 64 {
+		t.Fatalf("want a %d character version and a SHA-256 hash, got: %s", definitionVersionLength, key)
+	}
+
+	flow.CacheTTL = 60
+	updated := mustCacheKey(t, flow, cacheKeyRequest("/flow/checkout", "", nil), map[string]interface{}{"user": "alex"})
+	if updated == key {
+		t.Fatalf("want the key to change when the flow's definition changes")
+	}
+}
+
+func Test_flowCacheKey_Selection(t *testing.T) {
+	args := map[string]interface{}{"user": "alex", "trace": "1"}
+
+	cases := []struct {
+		name     string
+		cacheKey *v1.FlowCacheKey
+		a, b     *http.Request
+		argsB    map[string]interface{}
+		wantSame bool
+	}{
+		{
+			name:     "args only by default",
+			a:        cacheKeyRequest("/flow/checkout?page=1", "", map[string]string{"X-Tenant": "a"}),
+			b:        cacheKeyRequest("/flow/checkout?page=2", "/v2", map[string]string{"X-Tenant": "b"}),
+			wantSame: true,
+		},
+		{
+			name:     "different args",
+			a:        cacheKeyRequest("/flow/checkout", "", nil),
+			b:        cacheKeyRequest("/flow/checkout", "", nil),
+			argsB:    map[string]interface{}{"user": "alex", "trace": "2"},
+			wantSame: false,
+		},
+		{
+			name:     "selected args",
+			cacheKey: &v1.FlowCacheKey{Args: []string{"user"}},
+			a:        cacheKeyRequest("/flow/checkout", "", nil),
+			b:        cacheKeyRequest("/flow/checkout", "", nil),
+			argsB:    map[string]interface{}{"user": "alex", "trace": "2"},
+			wantSame: true,
+		},
+		{
+			name:     "headers",
+			cacheKey: &v1.FlowCacheKey{Headers: []string{"x-tenant", "Accept-Language"}},
+			a:        cacheKeyRequest("/flow/checkout", "", map[string]string{"X-Tenant": "a", "Accept-Language": "nl"}),
+			b:        cacheKeyRequest("/flow/checkout", "", map[string]string{"X-Tenant": "a", "Accept-Language": "en"}),
+			wantSame: false,
+		},
+		{
+			name:     "unselected headers",
+			cacheKey: &v1.FlowCacheKey{Headers: []string{"X-Tenant"}},
+			a:        cacheKeyRequest("/flow/checkout", "", map[string]string{"X-Tenant": "a", "X-Request-Id": "1"}),
+			b:        cacheKeyRequest("/flow/checkout", "", map[string]string{"X-Tenant": "a", "X-Request-Id": "2"}),
+			wantSame: true,
+		},
+		{
+			name:     "query",
+			cacheKey: &v1.FlowCacheKey{Query: []string{"page"}},
+			a:        cacheKeyRequest("/flow/checkout?page=1&debug=1", "", nil),
+			b:        cacheKeyRequest("/flow/checkout?page=2&debug=1", "", nil),
+			wantSame: false,
+		},
+		{
+			name:     "unselected query",
+			cacheKey: &v1.FlowCacheKey{Query: []string{"page"}},
+			a:        cacheKeyRequest("/flow/checkout?page=1&debug=1", "", nil),
+			b:        cacheKeyRequest("/flow/checkout?page=1&debug=2", "", nil),
+			wantSame: true,
+		},
+		{
+			name:     "path",
+			cacheKey: &v1.FlowCacheKey{Path: true},
+			a:        cacheKeyRequest("/flow/checkout/v1", "v1", nil),
+			b:        cacheKeyRequest("/flow/checkout/v2", "v2", nil),
+			wantSame: false,
+		},
+		{
+			name:     "path parts",
+			cacheKey: &v1.FlowCacheKey{PathParts: []int32{1}},
+			a:        cacheKeyRequest("/flow/checkout/eu/1", "eu/1", nil),
+			b:        cacheKeyRequest("/flow/checkout/eu/2", "eu/2", nil),
+			wantSame: false,
+		},
+		{
+			name:     "unselected path parts",
+			cacheKey: &v1.FlowCacheKey{PathParts: []int32{0}},
+			a:        cacheKeyRequest("/flow/checkout/eu/1", "eu/1", nil),
+			b:        cacheKeyRequest("/flow/checkout/eu/2", "/eu/2", nil),
+			wantSame: true,
+		},
+		{
+			name:     "missing path parts",
+			cacheKey: &v1.FlowCacheKey{PathParts: []int32{0, 3}},
+			a:        cacheKeyRequest("/flow/checkout/eu", "eu", nil),
+			b:        cacheKeyRequest("/flow/checkout/eu/1", "eu/1", nil),
+			wantSame: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			flow := &v1.FlowSpec{Args: []string{"user", "trace"}, CacheKey: tc.cacheKey}
+
+			argsB := args
+			if tc.argsB != nil {
+				argsB = tc.argsB
+			}
+
+			a := mustCacheKey(t, flow, tc.a, args)
+			b := mustCacheKey(t, flow, tc.b, argsB)
+			if (a == b) != tc.wantSame {
+				t.Fatalf("want same key: %t, got: %s and %s", tc.wantSame, a, b)
+			}
+		})
+	}
+}
+
+func Test_flowCacheKey_Children(t *testing.T) {
+	flow := &v1.FlowSpec{
+		Args:     []string{"user"},
+		CacheKey: &v1.FlowCacheKey{Children: []string{"price"}},
+		Children: map[string]v1.FlowChild{"price": {Function: "prices"}, "stock": {Function: "stock"}},
+	}
+	args := map[string]interface{}{"user": "alex"}
+	req := cacheKeyRequest("/flow/checkout", "", nil)
+
+	key := func(outputs map[string]*types.FlowOutput) string {
+		t.Helper()
+
+		key, err := flowCacheKey("openfaas-fn", "checkout", flow, req, args, outputs)
+		if err != nil {
+			t.Fatalf("want no error, got: %s", err)
+		}
+		return key
+	}
+
+	price1 := key(map[string]*types.FlowOutput{"price": {Data: []byte("1")}, "stock": {Data: []byte("10")}})
+	if price2 := key(map[string]*types.FlowOutput{"price": {Data: []byte("2")}, "stock": {Data: []byte("10")}}); price1 == price2 {
+		t.Fatalf("want the key to change with the output of a selected child")
+	}
+	if stock := key(map[string]*types.FlowOutput{"price": {Data: []byte("1")}, "stock": {Data: []byte("20")}}); price1 != stock {
+		t.Fatalf("want the key to ignore the output of an unselected child")
+	}
+	if skipped := key(map[string]*types.FlowOutput{"stock": {Data: []byte("10")}}); price1 == skipped {
+		t.Fatalf("want a skipped child to give a different key")
+	}
+}
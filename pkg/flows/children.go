@@ -0,0 +1,539 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package flows
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/danenherdi/faas-provider/types"
+	v1 "github.com/openfaas/faas-netes/pkg/apis/openfaas/v1"
+	"github.com/openfaas/faas-netes/pkg/tracing"
+)
+
+// defaultRetryBackoff is the delay before the first retry of a child when
+// its RetryBackoff is unset
+const defaultRetryBackoff = 100 * time.Millisecond
+
+// maxRetries bounds the retries of a child, as the backoff doubles on each
+const maxRetries = 10
+
+// ChildError is returned when a child of a flow fails and its OnError
+// policy is to fail the flow
+type ChildError struct {
+	// Alias of the child within its parent flow
+	Alias string
+	// Function is the flow which the child invokes
+	Function string
+	// StatusCode is the status returned by the child, or zero when no
+	// response was received
+	StatusCode int
+	// Attempts is the number of calls made to the child
+	Attempts int
+	Err      error
+}
+
+func (e *ChildError) Error() string {
+	return fmt.Sprintf("child %s [%s] failed after %d attempt(s): %s", e.Alias, e.Function, e.Attempts, e.Err.Error())
+}
+
+func (e *ChildError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout returns true when the child did not respond in time
+func (e *ChildError) Timeout() bool {
+	var netErr net.Error
+	return errors.Is(e.Err, context.DeadlineExceeded) || (errors.As(e.Err, &netErr) && netErr.Timeout())
+}
+
+// HTTPStatus is the status returned to the caller of the flow, 504 when the
+// child timed out and 502 for any other failure
+func (e *ChildError) HTTPStatus() int {
+	if e.Timeout() {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusBadGateway
+}
+
+// childResult is the outcome of invoking one child of a flow
+type childResult struct {
+	alias  string
+	output *types.FlowOutput
+	err    error
+}
+
+// childOutputs are the results of running the children of a flow
+type childOutputs struct {
+	// outputs of the children keyed by alias
+	outputs map[string]*types.FlowOutput
+	// skipped maps the alias of each skipped child to the reason
+	skipped map[string]string
+	// args are the parent's args along with those set by the OutputMap of
+	// each child
+	args map[string]interface{}
+}
+
+// runChildren invokes the children of a flow concurrently, with at most
+// flow.MaxConcurrency in flight. The children form a graph: a child which
+// depends on a sibling, by listing it in DependsOn, by referring to it in
+// its When condition or ArgsMap, or by using an arg set by its OutputMap,
+// is started once that sibling has completed. Children which are ready are
+// started in alias order, so that the order of execution is the same on
+// every run, and independent branches of the graph run in parallel.
+//
+// The outputs of the children are returned keyed by alias, along with the
+// reason each skipped child was skipped and the args set from their
+// outputs. When a child fails and its OnError policy is to fail the flow,
+// the remaining children are cancelled and the child's error is returned.
+// The path of each child is its alias appended to the path of its parent.
+func (rn *runner) runChildren(ctx context.Context, path string, flow *v1.FlowSpec, args map[string]interface{}) (*childOutputs, error) {
+	aliases := sortedKeys(flow.Children)
+
+	limit := len(aliases)
+	if flow.MaxConcurrency > 0 && int(flow.MaxConcurrency) < limit {
+		limit = int(flow.MaxConcurrency)
+	}
+
+	conditions := make(map[string]*expression, len(aliases))
+	selectors := make(map[string]map[string]*selector, len(aliases))
+	childPaths := make(map[string]map[string]*expression, len(aliases))
+	for _, alias := range aliases {
+		child := flow.Children[alias]
+
+		for _, arg := range sortedKeys(child.ArgsMap) {
+			if !isChildPath(child.ArgsMap[arg]) {
+				continue
+			}
+			path, err := parseChildPath(child.ArgsMap[arg])
+			if err != nil {
+				return nil, &ChildError{Alias: alias, Function: child.Function, Err: fmt.Errorf("invalid argsMap for %s: %w", arg, err)}
+			}
+			if childPaths[alias] == nil {
+				childPaths[alias] = map[string]*expression{}
+			}
+			childPaths[alias][arg] = path
+		}
+
+		if len(child.When) > 0 {
+			condition, err := parseExpression(child.When)
+			if err != nil {
+				return nil, &ChildError{Alias: alias, Function: child.Function, Err: fmt.Errorf("invalid when condition: %w", err)}
+			}
+			conditions[alias] = condition
+		}
+
+		for _, arg := range sortedKeys(child.OutputMap) {
+			sel, err := parseSelector(child.OutputMap[arg])
+			if err != nil {
+				return nil, &ChildError{Alias: alias, Function: child.Function, Err: fmt.Errorf("invalid outputMap for %s: %w", arg, err)}
+			}
+			if selectors[alias] == nil {
+				selectors[alias] = map[string]*selector{}
+			}
+			selectors[alias][arg] = sel
+		}
+	}
+	dependencies := childDependencies(flow)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	scope := make(map[string]interface{}, len(args))
+	for arg, value := range args {
+		scope[arg] = value
+	}
+
+	outputs := make(map[string]*types.FlowOutput, len(aliases))
+	values := make(map[string]interface{}, len(aliases))
+	skipped := map[string]string{}
+
+	// complete records the output of a child and sets the args of its
+	// OutputMap
+	complete := func(alias string, output *types.FlowOutput) {
+		outputs[alias] = output
+		values[alias] = outputValue(output.Data)
+
+		for arg, sel := range selectors[alias] {
+			scope[arg] = sel.selectFrom(values[alias])
+		}
+	}
+
+	started := make(map[string]bool, len(aliases))
+	done := make(map[string]bool, len(aliases))
+
+	// skip completes a child which is not invoked
+	skip := func(alias, reason string) {
+		skipped[alias] = reason
+		done[alias] = true
+
+		_, span := tracing.Start(ctx, "node "+path+"/"+alias, tracing.KindInternal)
+		span.SetAttribute("flow.node", path+"/"+alias)
+		span.SetAttribute("flow.function", flow.Children[alias].Function)
+		span.SetAttribute("flow.node.state", NodeSkipped)
+		span.SetAttribute("flow.node.reason", reason)
+		span.End()
+		observeNode(alias, flow.Children[alias].Function, NodeSkipped, 0)
+
+		executionFrom(ctx).update(path+"/"+alias, func(node *NodeRecord) {
+			node.Function = flow.Children[alias].Function
+			node.State = NodeSkipped
+			node.Reason = reason
+		})
+	}
+	results := make(chan childResult)
+	running := 0
+
+	var failed error
+
+	ready := func(alias string) bool {
+		for _, dependency := range dependencies[alias] {
+			if !done[dependency] {
+				return false
+			}
+		}
+		return true
+	}
+
+	for len(done) < len(aliases) {
+		// Start every child which is ready, evaluating conditions may
+		// complete children and so make others ready
+		for progress := true; progress && failed == nil && ctx.Err() == nil; {
+			progress = false
+
+			for _, alias := range aliases {
+				if running >= limit {
+					break
+				}
+				if started[alias] || !ready(alias) {
+					continue
+				}
+				started[alias] = true
+				progress = true
+
+				child := flow.Children[alias]
+				if dependency, ok := skippedDependency(child, skipped); ok {
+					if rn.verbose {
+						log.Printf("skipping %s [%s]: dependency %s was skipped", alias, child.Function, dependency)
+					}
+					skip(alias, fmt.Sprintf("dependency %s was skipped", dependency))
+					continue
+				}
+
+				if condition, ok := conditions[alias]; ok && !condition.eval(scope, values) {
+					if rn.verbose {
+						log.Printf("skipping %s [%s]: condition not met: %s", alias, child.Function, child.When)
+					}
+					skip(alias, "condition not met")
+					continue
+				}
+
+				if rn.verbose {
+					log.Printf("processing %s [%s]", alias, child.Function)
+				}
+
+				// the child reads a copy of the args, as the args of a
+				// child's OutputMap are set while others are running
+				childArgs := make(map[string]interface{}, len(child.ArgsMap))
+				for argField, mapField := range child.ArgsMap {
+					if path, ok := childPaths[alias][argField]; ok {
+						childArgs[argField] = path.value(scope, values)
+					} else {
+						childArgs[argField] = scope[mapField]
+					}
+				}
+
+				running++
+				go func(alias string, child v1.FlowChild) {
+					output, err := rn.callChild(ctx, path, alias, child, childArgs)
+					results <- childResult{alias: alias, output: output, err: err}
+				}(alias, child)
+			}
+		}
+
+		if running == 0 {
+			if failed == nil && ctx.Err() == nil && len(done) < len(aliases) {
+				failed = fmt.Errorf("children of the flow can not be ordered, they depend on each other")
+			}
+			break
+		}
+
+		res := <-results
+		running--
+		done[res.alias] = true
+
+		if res.err == nil {
+			complete(res.alias, res.output)
+			continue
+		}
+
+		child := flow.Children[res.alias]
+		switch child.OnError {
+		case v1.ChildErrorSkip:
+			log.Printf("skipping %s: %s", res.alias, res.err.Error())
+			skip(res.alias, res.err.Error())
+		case v1.ChildErrorFallback:
+			log.Printf("using fallback for %s: %s", res.alias, res.err.Error())
+			complete(res.alias, &types.FlowOutput{
+				Data:     []byte(child.Fallback),
+				Function: child.Function,
+			})
+			executionFrom(ctx).update(path+"/"+res.alias, func(node *NodeRecord) {
+				node.State = NodeFallback
+				node.Output = truncatePayload([]byte(child.Fallback))
+			})
+		default:
+			if failed == nil {
+				failed = res.err
+				cancel()
+			}
+		}
+	}
+
+	if failed != nil {
+		return nil, failed
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return &childOutputs{outputs: outputs, skipped: skipped, args: scope}, nil
+}
+
+// skippedDependency returns a sibling in the DependsOn of child which was
+// skipped, if any
+func skippedDependency(child v1.FlowChild, skipped map[string]string) (string, bool) {
+	for _, dependency := range child.DependsOn {
+		if _, ok := skipped[dependency]; ok {
+			return dependency, true
+		}
+	}
+	return "", false
+}
+
+// childDependencies returns the siblings which each child of flow waits
+// for: those in its DependsOn, those referred to by its When condition or
+// ArgsMap and those whose OutputMap sets an arg which it uses. Conditions
+// and paths which do not parse are ignored.
+func childDependencies(flow *v1.FlowSpec) map[string][]string {
+	producers := map[string]string{}
+	for _, alias := range sortedKeys(flow.Children) {
+		for arg := range flow.Children[alias].OutputMap {
+			if _, ok := producers[arg]; !ok {
+				producers[arg] = alias
+			}
+		}
+	}
+
+	dependencies := map[string][]string{}
+	for _, alias := range sortedKeys(flow.Children) {
+		child := flow.Children[alias]
+		seen := map[string]bool{alias: true}
+
+		add := func(dependency string) {
+			if _, ok := flow.Children[dependency]; ok && !seen[dependency] {
+				seen[dependency] = true
+				dependencies[alias] = append(dependencies[alias], dependency)
+			}
+		}
+
+		for _, dependency := range child.DependsOn {
+			add(dependency)
+		}
+
+		for _, arg := range sortedKeys(child.ArgsMap) {
+			mapField := child.ArgsMap[arg]
+			if isChildPath(mapField) {
+				if path, err := parseChildPath(mapField); err == nil {
+					add(path.paths[0][1])
+				}
+			} else if producer, ok := producers[mapField]; ok {
+				add(producer)
+			}
+		}
+
+		if len(child.When) == 0 {
+			continue
+		}
+		condition, err := parseExpression(child.When)
+		if err != nil {
+			continue
+		}
+		for _, path := range condition.paths {
+			if len(path) < 2 {
+				continue
+			}
+			switch path[0] {
+			case childrenRoot:
+				add(path[1])
+			case argsRoot:
+				if producer, ok := producers[path[1]]; ok {
+					add(producer)
+				}
+			}
+		}
+	}
+
+	return dependencies
+}
+
+// callChild invokes a single child of a flow with the args mapped from its
+// parent's args. A third-party child is called over HTTP and any other
+// child is run in-process, in both cases its response is read from the
+// cache when the child's flow is cacheable. Failed calls are retried up to
+// child.Retries times when the failure may be transient. The outcome of
+// the child is recorded in the flow's execution and as a span.
+func (rn *runner) callChild(ctx context.Context, parent, alias string, child v1.FlowChild, args map[string]interface{}) (output *types.FlowOutput, err error) {
+	path := parent + "/" + alias
+
+	ctx, span := tracing.Start(ctx, "node "+path, tracing.KindInternal)
+
+	started := time.Now()
+	status, attempts := 0, 0
+	defer func() {
+		span.SetAttribute("flow.node", path)
+		span.SetAttribute("flow.function", child.Function)
+		span.SetAttribute("flow.attempts", attempts)
+		if status > 0 {
+			span.SetAttribute("http.status_code", status)
+		}
+		span.SetError(err)
+		span.End()
+
+		state := NodeSucceeded
+		if err != nil {
+			state = NodeFailed
+		}
+		observeNode(alias, child.Function, state, time.Since(started))
+
+		executionFrom(ctx).update(path, func(node *NodeRecord) {
+			node.Function = child.Function
+			node.Started = started
+			node.DurationMs = durationMs(time.Since(started))
+			node.Status = status
+			node.Attempts = attempts
+			node.Input = recordedJSON(args)
+
+			if err != nil {
+				node.State = NodeFailed
+				node.Reason = err.Error()
+			} else {
+				node.State = NodeSucceeded
+				node.Output = truncatePayload(output.Data)
+			}
+		})
+	}()
+
+	childFlow, err := rn.lookup.Get(child.Function)
+	if err != nil {
+		return nil, &ChildError{Alias: alias, Function: child.Function, Err: fmt.Errorf("unable to look up flow: %w", err)}
+	}
+
+	var call func(ctx context.Context) *bufferedResponse
+	if childFlow.IsThirdParty && childFlow.ThirdPartyURL != nil {
+		call, err = rn.thirdPartyCall(path, child.Function, childFlow, args)
+		if err != nil {
+			return nil, &ChildError{Alias: alias, Function: child.Function, Err: err}
+		}
+	} else {
+		call = func(ctx context.Context) *bufferedResponse {
+			return rn.invokeFlow(ctx, path, child.Function, childFlow, args)
+		}
+	}
+
+	backoff := defaultRetryBackoff
+	if child.RetryBackoff != nil && child.RetryBackoff.Duration > 0 {
+		backoff = child.RetryBackoff.Duration
+	}
+
+	for {
+		attempts++
+
+		var data []byte
+		data, status, err = callChildOnce(ctx, child, call)
+		if err == nil {
+			return &types.FlowOutput{
+				Data:     data,
+				Function: child.Function,
+			}, nil
+		}
+
+		if attempts > min(int(child.Retries), maxRetries) || !retryable(status) || ctx.Err() != nil {
+			return nil, &ChildError{Alias: alias, Function: child.Function, StatusCode: status, Attempts: attempts, Err: err}
+		}
+
+		log.Printf("retrying %s in %s: %s", alias, backoff, err.Error())
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, &ChildError{Alias: alias, Function: child.Function, StatusCode: status, Attempts: attempts, Err: ctx.Err()}
+		}
+		backoff *= 2
+	}
+}
+
+// thirdPartyCall returns a call to the URL of a third-party flow, cached
+// when the flow is cacheable. The request is rendered once for all
+// attempts, so that a template or secret which can not be used fails the
+// child without it being retried.
+func (rn *runner) thirdPartyCall(path, name string, flow *v1.FlowSpec, args map[string]interface{}) (func(ctx context.Context) *bufferedResponse, error) {
+	req, err := rn.newThirdPartyRequest(flow, args)
+	if err != nil {
+		return nil, err
+	}
+
+	fetch := func(ctx context.Context) (*bufferedResponse, bool) {
+		res := req.do(ctx)
+		return res, res.err == nil
+	}
+
+	if !rn.cacheable(flow) {
+		return func(ctx context.Context) *bufferedResponse {
+			res, _ := fetch(ctx)
+			return res
+		}, nil
+	}
+
+	key, err := flowCacheKey(rn.namespace, name, flow, nil, args, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create cache key: %w", err)
+	}
+
+	return func(ctx context.Context) *bufferedResponse {
+		return rn.serve(ctx, path, flow, key, fetch)
+	}, nil
+}
+
+// callChildOnce makes a single call to a child, a response outside of the
+// 2xx range is returned as an error along with its status code
+func callChildOnce(ctx context.Context, child v1.FlowChild, call func(ctx context.Context) *bufferedResponse) ([]byte, int, error) {
+	if child.Timeout != nil && child.Timeout.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, child.Timeout.Duration)
+		defer cancel()
+	}
+
+	res := call(ctx)
+	if res.err != nil {
+		return nil, res.status, res.err
+	}
+
+	if !res.ok() {
+		return nil, res.status, fmt.Errorf("unexpected status code: %d", res.status)
+	}
+
+	return res.body.Bytes(), res.status, nil
+}
+
+// retryable returns true when a call which failed with status may succeed
+// if it is made again. A status of zero means no response was received.
+func retryable(status int) bool {
+	return status == 0 || status == http.StatusTooManyRequests || status >= 500
+}
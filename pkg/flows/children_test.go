@@ -0,0 +1,396 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package flows
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1 "github.com/openfaas/faas-netes/pkg/apis/openfaas/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newEchoServer returns a third-party endpoint which echoes its "id" arg
+// after a short delay and records the peak number of requests in flight
+func newEchoServer(delay time.Duration) (*httptest.Server, *int32, *[]string) {
+	var inFlight, peak int32
+	var order []string
+	var mu sync.Mutex
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if current <= p || atomic.CompareAndSwapInt32(&peak, p, current) {
+				break
+			}
+		}
+
+		var args map[string]string
+		json.NewDecoder(r.Body).Decode(&args)
+
+		mu.Lock()
+		order = append(order, args["id"])
+		mu.Unlock()
+
+		time.Sleep(delay)
+		w.Write([]byte(args["id"]))
+	}))
+
+	return server, &peak, &order
+}
+
+// testRunner returns a runner without caching for flows whose children
+// are all third-party
+func testRunner(lookup Lookup) *runner {
+	return &runner{lookup: lookup, namespace: "openfaas-fn", flights: newFlightGroup()}
+}
+
+func echoChildren(server *httptest.Server, ids ...string) (map[string]v1.FlowSpec, map[string]v1.FlowChild) {
+	url := server.URL
+	specs := map[string]v1.FlowSpec{
+		"echo": {Args: []string{"id"}, IsThirdParty: true, ThirdPartyURL: &url},
+	}
+
+	children := map[string]v1.FlowChild{}
+	for _, id := range ids {
+		children[id] = v1.FlowChild{Function: "echo", ArgsMap: map[string]string{"id": id}}
+	}
+	return specs, children
+}
+
+func Test_runChildren_RunsConcurrently(t *testing.T) {
+	server, peak, _ := newEchoServer(100 * time.Millisecond)
+	defer server.Close()
+
+	specs, children := echoChildren(server, "a", "b", "c", "d", "e")
+	lookup, _ := newTestLookup(t, "openfaas-fn", specs)
+
+	args := map[string]interface{}{"a": "a", "b": "b", "c": "c", "d": "d", "e": "e"}
+	flow := &v1.FlowSpec{Children: children}
+
+	start := time.Now()
+	result, err := testRunner(lookup).runChildren(context.Background(), "test", flow, args)
+	elapsed := time.Since(start)
+
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err)
+	}
+	if elapsed >= 400*time.Millisecond {
+		t.Fatalf("want children to run concurrently, took: %s", elapsed)
+	}
+	if *peak != 5 {
+		t.Fatalf("want 5 children in flight, got: %d", *peak)
+	}
+	for alias, output := range result.outputs {
+		if string(output.Data) != alias {
+			t.Fatalf("want output of %s to be gathered under its alias, got: %s", alias, string(output.Data))
+		}
+	}
+}
+
+func Test_runChildren_RespectsMaxConcurrency(t *testing.T) {
+	server, peak, order := newEchoServer(20 * time.Millisecond)
+	defer server.Close()
+
+	specs, children := echoChildren(server, "e", "d", "c", "b", "a")
+	lookup, _ := newTestLookup(t, "openfaas-fn", specs)
+
+	args := map[string]interface{}{"a": "a", "b": "b", "c": "c", "d": "d", "e": "e"}
+
+	flow := &v1.FlowSpec{Children: children, MaxConcurrency: 2}
+	if _, err := testRunner(lookup).runChildren(context.Background(), "test", flow, args); err != nil {
+		t.Fatalf("want no error, got: %s", err)
+	}
+	if *peak > 2 {
+		t.Fatalf("want at most 2 children in flight, got: %d", *peak)
+	}
+
+	*order = nil
+	flow.MaxConcurrency = 1
+	if _, err := testRunner(lookup).runChildren(context.Background(), "test", flow, args); err != nil {
+		t.Fatalf("want no error, got: %s", err)
+	}
+
+	want := []string{"a", "b", "c", "d", "e"}
+	if len(*order) != len(want) {
+		t.Fatalf("want %d calls, got: %v", len(want), *order)
+	}
+	for i := range want {
+		if (*order)[i] != want[i] {
+			t.Fatalf("want children to start in alias order %v, got: %v", want, *order)
+		}
+	}
+}
+
+// newFailingServer returns a third-party endpoint which responds with status
+// to the first failures calls and with "ok" after that
+func newFailingServer(failures int32, status int) (*httptest.Server, *int32) {
+	var calls int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+
+	return server, &calls
+}
+
+func thirdPartyLookup(t *testing.T, server *httptest.Server) Lookup {
+	url := server.URL
+	lookup, _ := newTestLookup(t, "openfaas-fn", map[string]v1.FlowSpec{
+		"upstream": {IsThirdParty: true, ThirdPartyURL: &url},
+	})
+	return lookup
+}
+
+func Test_runChildren_RetriesTransientFailures(t *testing.T) {
+	server, calls := newFailingServer(2, http.StatusServiceUnavailable)
+	defer server.Close()
+
+	flow := &v1.FlowSpec{Children: map[string]v1.FlowChild{
+		"up": {Function: "upstream", Retries: 2, RetryBackoff: &metav1.Duration{Duration: time.Millisecond}},
+	}}
+
+	result, err := testRunner(thirdPartyLookup(t, server)).runChildren(context.Background(), "test", flow, nil)
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err)
+	}
+	if string(result.outputs["up"].Data) != "ok" {
+		t.Fatalf("want output ok, got: %q", string(result.outputs["up"].Data))
+	}
+	if *calls != 3 {
+		t.Fatalf("want 3 calls, got: %d", *calls)
+	}
+}
+
+func Test_runChildren_DoesNotRetryClientErrors(t *testing.T) {
+	server, calls := newFailingServer(1, http.StatusBadRequest)
+	defer server.Close()
+
+	flow := &v1.FlowSpec{Children: map[string]v1.FlowChild{
+		"up": {Function: "upstream", Retries: 3},
+	}}
+
+	_, err := testRunner(thirdPartyLookup(t, server)).runChildren(context.Background(), "test", flow, nil)
+
+	var childErr *ChildError
+	if !errors.As(err, &childErr) {
+		t.Fatalf("want ChildError, got: %v", err)
+	}
+	if childErr.Alias != "up" || childErr.StatusCode != http.StatusBadRequest || childErr.HTTPStatus() != http.StatusBadGateway {
+		t.Fatalf("unexpected error: %+v", childErr)
+	}
+	if *calls != 1 {
+		t.Fatalf("want 1 call, got: %d", *calls)
+	}
+}
+
+func Test_runChildren_OnErrorPolicies(t *testing.T) {
+	server, _ := newFailingServer(100, http.StatusInternalServerError)
+	defer server.Close()
+
+	flow := &v1.FlowSpec{Children: map[string]v1.FlowChild{
+		"skipped":  {Function: "upstream", OnError: v1.ChildErrorSkip},
+		"fallback": {Function: "upstream", OnError: v1.ChildErrorFallback, Fallback: `{"rate":1}`},
+	}}
+
+	result, err := testRunner(thirdPartyLookup(t, server)).runChildren(context.Background(), "test", flow, nil)
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err)
+	}
+	if _, ok := result.outputs["skipped"]; ok {
+		t.Fatalf("want skipped child to be left out of the outputs")
+	}
+	if _, ok := result.skipped["skipped"]; !ok {
+		t.Fatalf("want skipped child to be marked as skipped, got: %v", result.skipped)
+	}
+	if string(result.outputs["fallback"].Data) != `{"rate":1}` {
+		t.Fatalf("want fallback output, got: %q", string(result.outputs["fallback"].Data))
+	}
+}
+
+func Test_runChildren_Timeout(t *testing.T) {
+	server, _, _ := newEchoServer(500 * time.Millisecond)
+	defer server.Close()
+
+	flow := &v1.FlowSpec{Children: map[string]v1.FlowChild{
+		"slow": {Function: "upstream", Timeout: &metav1.Duration{Duration: 20 * time.Millisecond}},
+	}}
+
+	start := time.Now()
+	_, err := testRunner(thirdPartyLookup(t, server)).runChildren(context.Background(), "test", flow, nil)
+
+	var childErr *ChildError
+	if !errors.As(err, &childErr) {
+		t.Fatalf("want ChildError, got: %v", err)
+	}
+	if childErr.HTTPStatus() != http.StatusGatewayTimeout {
+		t.Fatalf("want status %d, got: %d", http.StatusGatewayTimeout, childErr.HTTPStatus())
+	}
+	if elapsed := time.Since(start); elapsed >= 400*time.Millisecond {
+		t.Fatalf("want the child to time out, took: %s", elapsed)
+	}
+}
+
+func Test_runChildren_Conditions(t *testing.T) {
+	server, _, order := newEchoServer(0)
+	defer server.Close()
+
+	url := server.URL
+	lookup, _ := newTestLookup(t, "openfaas-fn", map[string]v1.FlowSpec{
+		"echo": {Args: []string{"id"}, IsThirdParty: true, ThirdPartyURL: &url},
+	})
+
+	flow := &v1.FlowSpec{Children: map[string]v1.FlowChild{
+		// a is started after z, as its condition uses z's output
+		"a":     {Function: "echo", ArgsMap: map[string]string{"id": "a"}, When: `children.z == "z"`},
+		"fraud": {Function: "echo", ArgsMap: map[string]string{"id": "fraud"}, When: "args.amount > 1000"},
+		"z":     {Function: "echo", ArgsMap: map[string]string{"id": "z"}},
+	}}
+	args := map[string]interface{}{"a": "a", "fraud": "fraud", "z": "z", "amount": float64(10)}
+
+	result, err := testRunner(lookup).runChildren(context.Background(), "test", flow, args)
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err)
+	}
+
+	if _, ok := result.outputs["fraud"]; ok {
+		t.Errorf("want fraud to be skipped")
+	}
+	if result.skipped["fraud"] != "condition not met" {
+		t.Errorf("want fraud to be marked as skipped, got: %v", result.skipped)
+	}
+	if string(result.outputs["a"].Data) != "a" {
+		t.Errorf("want a to run once z has returned, got: %v", result.outputs)
+	}
+	if len(*order) != 2 || (*order)[0] != "z" || (*order)[1] != "a" {
+		t.Errorf("want z to run before a, got: %v", *order)
+	}
+}
+
+func Test_runChildren_OutputMapFeedsSiblings(t *testing.T) {
+	echo, _, order := newEchoServer(0)
+	defer echo.Close()
+
+	profile := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"user":{"id":"u-1"},"items":[{"sku":"a"},{"sku":"b"}]}`))
+	}))
+	defer profile.Close()
+
+	echoURL, profileURL := echo.URL, profile.URL
+	lookup, _ := newTestLookup(t, "openfaas-fn", map[string]v1.FlowSpec{
+		"echo":    {Args: []string{"id"}, IsThirdParty: true, ThirdPartyURL: &echoURL},
+		"profile": {IsThirdParty: true, ThirdPartyURL: &profileURL},
+	})
+
+	flow := &v1.FlowSpec{Children: map[string]v1.FlowChild{
+		// orders is started after profile, as it uses an arg which
+		// profile's output sets
+		"orders":  {Function: "echo", ArgsMap: map[string]string{"id": "userId"}},
+		"profile": {Function: "profile", OutputMap: map[string]string{"userId": "$.user.id", "skus": "$.items[*].sku"}},
+		"vip":     {Function: "echo", ArgsMap: map[string]string{"id": "userId"}, When: `args.userId == "u-2"`},
+	}}
+
+	result, err := testRunner(lookup).runChildren(context.Background(), "test", flow, map[string]interface{}{"region": "eu"})
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err)
+	}
+
+	if string(result.outputs["orders"].Data) != "u-1" {
+		t.Errorf("want orders to receive the user's id, got: %q", string(result.outputs["orders"].Data))
+	}
+	if result.skipped["vip"] != "condition not met" {
+		t.Errorf("want vip to be skipped by a condition on a mapped arg, got: %v", result.skipped)
+	}
+	if len(*order) != 1 || (*order)[0] != "u-1" {
+		t.Errorf("want only orders to call echo, got: %v", *order)
+	}
+
+	want := map[string]interface{}{"region": "eu", "userId": "u-1", "skus": []interface{}{"a", "b"}}
+	if !reflect.DeepEqual(result.args, want) {
+		t.Errorf("want args %v, got: %v", want, result.args)
+	}
+}
+
+func Test_runChildren_DependsOnRunsBranchesInParallel(t *testing.T) {
+	server, peak, order := newEchoServer(50 * time.Millisecond)
+	defer server.Close()
+
+	url := server.URL
+	lookup, _ := newTestLookup(t, "openfaas-fn", map[string]v1.FlowSpec{
+		"echo": {Args: []string{"id"}, IsThirdParty: true, ThirdPartyURL: &url},
+	})
+
+	// a diamond: b and c wait for a, d waits for b and reads c's output
+	flow := &v1.FlowSpec{Children: map[string]v1.FlowChild{
+		"a": {Function: "echo", ArgsMap: map[string]string{"id": "a"}},
+		"b": {Function: "echo", ArgsMap: map[string]string{"id": "b"}, DependsOn: []string{"a"}},
+		"c": {Function: "echo", ArgsMap: map[string]string{"id": "c"}, DependsOn: []string{"a"}},
+		"d": {Function: "echo", ArgsMap: map[string]string{"id": "children.c"}, DependsOn: []string{"b"}},
+	}}
+	args := map[string]interface{}{"a": "a", "b": "b", "c": "c"}
+
+	result, err := testRunner(lookup).runChildren(context.Background(), "test", flow, args)
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err)
+	}
+
+	if *peak != 2 {
+		t.Errorf("want b and c to run in parallel, got a peak of %d in flight", *peak)
+	}
+	if len(*order) != 4 || (*order)[0] != "a" || (*order)[3] != "c" {
+		t.Errorf("want a first and d, with c's output, last, got: %v", *order)
+	}
+	if string(result.outputs["d"].Data) != "c" {
+		t.Errorf("want d to receive c's output, got: %q", string(result.outputs["d"].Data))
+	}
+}
+
+func Test_runChildren_SkipsDependantsOfSkippedChildren(t *testing.T) {
+	server, _, order := newEchoServer(0)
+	defer server.Close()
+
+	url := server.URL
+	lookup, _ := newTestLookup(t, "openfaas-fn", map[string]v1.FlowSpec{
+		"echo": {Args: []string{"id"}, IsThirdParty: true, ThirdPartyURL: &url},
+	})
+
+	flow := &v1.FlowSpec{Children: map[string]v1.FlowChild{
+		"a": {Function: "echo", ArgsMap: map[string]string{"id": "a"}, When: "args.enabled"},
+		"b": {Function: "echo", ArgsMap: map[string]string{"id": "b"}, DependsOn: []string{"a"}},
+		"c": {Function: "echo", ArgsMap: map[string]string{"id": "c"}, DependsOn: []string{"b"}},
+		"d": {Function: "echo", ArgsMap: map[string]string{"id": "d"}},
+	}}
+	args := map[string]interface{}{"a": "a", "b": "b", "c": "c", "d": "d", "enabled": false}
+
+	result, err := testRunner(lookup).runChildren(context.Background(), "test", flow, args)
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err)
+	}
+
+	want := map[string]string{
+		"a": "condition not met",
+		"b": "dependency a was skipped",
+		"c": "dependency b was skipped",
+	}
+	if !reflect.DeepEqual(result.skipped, want) {
+		t.Errorf("want skipped %v, got: %v", want, result.skipped)
+	}
+	if len(*order) != 1 || (*order)[0] != "d" {
+		t.Errorf("want only d to run, got: %v", *order)
+	}
+}
@@ -0,0 +1,117 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package flows
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+)
+
+// flightGroup coalesces concurrent executions of a flow which share a cache
+// key, so that when a cached response expires only one request runs the
+// flow and every other request waits for its response
+type flightGroup struct {
+	lock    sync.Mutex
+	flights map[string]*flight
+}
+
+type flight struct {
+	done     chan struct{}
+	response *bufferedResponse
+}
+
+func newFlightGroup() *flightGroup {
+	return &flightGroup{flights: map[string]*flight{}}
+}
+
+// start runs fn in the background unless a call for key is already
+// running, it returns the call and whether it was started
+func (g *flightGroup) start(key string, fn func() *bufferedResponse) (*flight, bool) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if f, ok := g.flights[key]; ok {
+		return f, false
+	}
+
+	f := &flight{done: make(chan struct{})}
+	g.flights[key] = f
+
+	go func() {
+		f.response = fn()
+
+		g.lock.Lock()
+		delete(g.flights, key)
+		g.lock.Unlock()
+		close(f.done)
+	}()
+
+	return f, true
+}
+
+// do runs fn unless a call for key is already running, and waits for the
+// response of whichever call runs. The response is returned along with
+// whether it was shared with another caller. When ctx is done before the
+// call completes its error is returned, and the call carries on so that
+// other callers still receive its response.
+func (g *flightGroup) do(ctx context.Context, key string, fn func() *bufferedResponse) (*bufferedResponse, bool, error) {
+	f, started := g.start(key, fn)
+
+	select {
+	case <-f.done:
+		return f.response, !started, nil
+	case <-ctx.Done():
+		return nil, !started, ctx.Err()
+	}
+}
+
+// bufferedResponse is an http.ResponseWriter which holds a response in
+// memory so that it can be written to any number of callers
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+	// err is set when no response was received
+	err error
+}
+
+func newBufferedResponse() *bufferedResponse {
+	return &bufferedResponse{header: http.Header{}}
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *bufferedResponse) Write(data []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(data)
+}
+
+// ok returns true for a response with a 2xx status
+func (b *bufferedResponse) ok() bool {
+	return b.err == nil && b.status >= 200 && b.status <= 299
+}
+
+// writeTo copies the buffered response to w
+func (b *bufferedResponse) writeTo(w http.ResponseWriter) {
+	copyHeaders(w.Header(), &b.header)
+
+	status := b.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	w.Write(b.body.Bytes())
+}
@@ -0,0 +1,342 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package flows
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// executionIDHeader identifies the run of a flow, by which it can be
+	// looked up in the ExecutionStore
+	executionIDHeader = "X-Flow-Execution-Id"
+
+	// maxRecordedPayload is the number of bytes of the input and output of
+	// each node which are kept with an execution
+	maxRecordedPayload = 2048
+)
+
+// Node states of an execution
+const (
+	NodeSucceeded = "succeeded"
+	NodeFailed    = "failed"
+	NodeSkipped   = "skipped"
+	NodeFallback  = "fallback"
+)
+
+// Execution is the record of a single run of a flow
+type Execution struct {
+	// ID is returned to the caller in the X-Flow-Execution-Id header, it is
+	// the X-Call-Id of a flow invoked through /async-flow
+	ID   string `json:"id"`
+	Flow string `json:"flow"`
+
+	Started    time.Time `json:"started"`
+	DurationMs float64   `json:"durationMs"`
+	// Status is the status code returned to the caller
+	Status int `json:"status"`
+	// Cache is hit, stale or miss for a cacheable flow
+	Cache string `json:"cache,omitempty"`
+
+	// Input and Output are truncated to maxRecordedPayload bytes
+	Input  string `json:"input,omitempty"`
+	Output string `json:"output,omitempty"`
+
+	// Nodes are the children of the flow and of any child which is itself
+	// a flow, ordered by when they were started
+	Nodes []NodeRecord `json:"nodes,omitempty"`
+}
+
+// NodeRecord is the record of one child in an execution
+type NodeRecord struct {
+	// Path is the alias of the node appended to the path of its parent,
+	// starting from the flow which was invoked
+	Path     string `json:"path"`
+	Function string `json:"function"`
+	// State is one of succeeded, failed, skipped or fallback
+	State string `json:"state"`
+
+	Started    time.Time `json:"started"`
+	DurationMs float64   `json:"durationMs"`
+	// Status is the last status code returned by the child, zero when no
+	// response was received
+	Status   int `json:"status,omitempty"`
+	Attempts int `json:"attempts,omitempty"`
+	// Cache is hit, stale or miss for a cacheable node
+	Cache string `json:"cache,omitempty"`
+	// Reason explains why the node was skipped or why it failed
+	Reason string `json:"reason,omitempty"`
+
+	// Input and Output are truncated to maxRecordedPayload bytes
+	Input  string `json:"input,omitempty"`
+	Output string `json:"output,omitempty"`
+}
+
+// ExecutionSummary is an Execution without its nodes, input or output
+type ExecutionSummary struct {
+	ID         string    `json:"id"`
+	Flow       string    `json:"flow"`
+	Started    time.Time `json:"started"`
+	DurationMs float64   `json:"durationMs"`
+	Status     int       `json:"status"`
+	Cache      string    `json:"cache,omitempty"`
+	Nodes      int       `json:"nodes"`
+}
+
+// ExecutionStore keeps the most recent executions of every flow in a ring
+// buffer, so that the memory it uses is bounded. A nil store holds nothing.
+type ExecutionStore struct {
+	lock       sync.RWMutex
+	executions []*Execution
+	next       int
+	byID       map[string]*Execution
+}
+
+// NewExecutionStore creates a store of up to size executions, the oldest
+// is dropped to make room for the next
+func NewExecutionStore(size int) *ExecutionStore {
+	return &ExecutionStore{
+		executions: make([]*Execution, size),
+		byID:       make(map[string]*Execution, size),
+	}
+}
+
+// add stores an execution, replacing the oldest when the store is full
+func (s *ExecutionStore) add(execution *Execution) {
+	if s == nil || len(s.executions) == 0 {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if oldest := s.executions[s.next]; oldest != nil && s.byID[oldest.ID] == oldest {
+		delete(s.byID, oldest.ID)
+	}
+
+	s.executions[s.next] = execution
+	s.byID[execution.ID] = execution
+
+	s.next = (s.next + 1) % len(s.executions)
+}
+
+// List returns the summaries of the executions of a flow, newest first
+func (s *ExecutionStore) List(flow string) []ExecutionSummary {
+	summaries := []ExecutionSummary{}
+	if s == nil {
+		return summaries
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	for i := 1; i <= len(s.executions); i++ {
+		execution := s.executions[(s.next-i+len(s.executions))%len(s.executions)]
+		if execution == nil {
+			break
+		}
+		if execution.Flow != flow {
+			continue
+		}
+
+		summaries = append(summaries, ExecutionSummary{
+			ID:         execution.ID,
+			Flow:       execution.Flow,
+			Started:    execution.Started,
+			DurationMs: execution.DurationMs,
+			Status:     execution.Status,
+			Cache:      execution.Cache,
+			Nodes:      len(execution.Nodes),
+		})
+	}
+	return summaries
+}
+
+// Get returns the execution with id, or nil when it is unknown or has been
+// dropped from the store
+func (s *ExecutionStore) Get(id string) *Execution {
+	if s == nil {
+		return nil
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.byID[id]
+}
+
+// executionRecorder collects the nodes of an execution while the flow
+// runs, a nil recorder records nothing
+type executionRecorder struct {
+	lock      sync.Mutex
+	execution *Execution
+	nodes     map[string]*NodeRecord
+	finished  bool
+}
+
+// newExecutionRecorder starts recording a run of flow, callID is used as
+// the ID of the execution when it is set
+func newExecutionRecorder(flow, callID string, input interface{}) *executionRecorder {
+	id := callID
+	if len(id) == 0 {
+		id = uuid.New().String()
+	}
+
+	return &executionRecorder{
+		execution: &Execution{
+			ID:      id,
+			Flow:    flow,
+			Started: time.Now(),
+			Input:   recordedJSON(input),
+		},
+		nodes: map[string]*NodeRecord{},
+	}
+}
+
+// id returns the ID of the execution, or an empty string
+func (e *executionRecorder) id() string {
+	if e == nil {
+		return ""
+	}
+	return e.execution.ID
+}
+
+// update changes the record of the node at path, nodes updated once the
+// execution has finished, by a refresh in the background, are ignored
+func (e *executionRecorder) update(path string, fn func(node *NodeRecord)) {
+	if e == nil {
+		return
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.finished {
+		return
+	}
+
+	node, ok := e.nodes[path]
+	if !ok {
+		node = &NodeRecord{Path: path, Started: time.Now()}
+		e.nodes[path] = node
+	}
+	fn(node)
+}
+
+// finish completes the execution with the response returned to the caller
+// and the cache results of its nodes
+func (e *executionRecorder) finish(status int, output []byte, trace *cacheTrace) *Execution {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.finished = true
+
+	execution := e.execution
+	execution.DurationMs = durationMs(time.Since(execution.Started))
+	execution.Status = status
+	execution.Output = truncatePayload(output)
+
+	cache := map[string]cacheFreshness{}
+	if trace != nil {
+		trace.lock.Lock()
+		for path, freshness := range trace.nodes {
+			cache[path] = freshness
+		}
+		trace.lock.Unlock()
+	}
+
+	if freshness, ok := cache[execution.Flow]; ok {
+		execution.Cache = cacheResult(freshness)
+	}
+
+	execution.Nodes = make([]NodeRecord, 0, len(e.nodes))
+	for _, node := range e.nodes {
+		if freshness, ok := cache[node.Path]; ok {
+			node.Cache = cacheResult(freshness)
+		}
+		execution.Nodes = append(execution.Nodes, *node)
+	}
+	sort.SliceStable(execution.Nodes, func(i, j int) bool {
+		if !execution.Nodes[i].Started.Equal(execution.Nodes[j].Started) {
+			return execution.Nodes[i].Started.Before(execution.Nodes[j].Started)
+		}
+		return execution.Nodes[i].Path < execution.Nodes[j].Path
+	})
+
+	return execution
+}
+
+type executionKey struct{}
+
+// withExecution returns a context which records the nodes run with it in
+// recorder
+func withExecution(ctx context.Context, recorder *executionRecorder) context.Context {
+	return context.WithValue(ctx, executionKey{}, recorder)
+}
+
+// executionFrom returns the recorder of ctx, or nil
+func executionFrom(ctx context.Context) *executionRecorder {
+	recorder, _ := ctx.Value(executionKey{}).(*executionRecorder)
+	return recorder
+}
+
+// recordingWriter keeps the status and the start of the body written to
+// the caller of a flow
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   []byte
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if room := maxRecordedPayload + 1 - len(w.body); room > 0 {
+		if len(data) < room {
+			room = len(data)
+		}
+		w.body = append(w.body, data[:room]...)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// recordedJSON encodes value for a record, truncated to maxRecordedPayload
+func recordedJSON(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return truncatePayload(data)
+}
+
+// truncatePayload returns up to maxRecordedPayload bytes of data, marking
+// data which was truncated with a trailing ellipsis
+func truncatePayload(data []byte) string {
+	if len(data) <= maxRecordedPayload {
+		return string(data)
+	}
+	return string(data[:maxRecordedPayload]) + "..."
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,53 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package flows
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// MakeExecutionListHandler lists the recent executions of the flow named
+// in the path, newest first
+func MakeExecutionListHandler(executions *ExecutionStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		if name == "" {
+			http.Error(w, "Provide flow name in the request path", http.StatusBadRequest)
+			return
+		}
+
+		writeExecutionJSON(w, executions.List(name))
+	}
+}
+
+// MakeExecutionHandler returns a single execution of the flow named in the
+// path, with the record of each of its nodes
+func MakeExecutionHandler(executions *ExecutionStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		execution := executions.Get(vars["id"])
+		if execution == nil || execution.Flow != vars["name"] {
+			http.Error(w, "Unable to find execution: "+vars["id"], http.StatusNotFound)
+			return
+		}
+
+		writeExecutionJSON(w, execution)
+	}
+}
+
+func writeExecutionJSON(w http.ResponseWriter, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		http.Error(w, "Unable to marshal response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
@@ -0,0 +1,139 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package flows
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/danenherdi/faas-provider/types"
+	"github.com/gorilla/mux"
+	v1 "github.com/openfaas/faas-netes/pkg/apis/openfaas/v1"
+)
+
+func Test_ExecutionStore_DropsOldest(t *testing.T) {
+	store := NewExecutionStore(2)
+	store.add(&Execution{ID: "1", Flow: "a"})
+	store.add(&Execution{ID: "2", Flow: "b"})
+	store.add(&Execution{ID: "3", Flow: "a"})
+
+	if store.Get("1") != nil {
+		t.Fatalf("want the oldest execution to be dropped")
+	}
+	if store.Get("2") == nil || store.Get("3") == nil {
+		t.Fatalf("want the newest executions to be kept")
+	}
+
+	store.add(&Execution{ID: "4", Flow: "a"})
+
+	got := []string{}
+	for _, summary := range store.List("a") {
+		got = append(got, summary.ID)
+	}
+	if strings.Join(got, ",") != "4,3" {
+		t.Fatalf("want executions of a newest first, got: %v", got)
+	}
+	if len(store.List("b")) != 0 {
+		t.Fatalf("want the execution of b to be dropped")
+	}
+}
+
+func Test_truncatePayload(t *testing.T) {
+	short := strings.Repeat("a", maxRecordedPayload)
+	if got := truncatePayload([]byte(short)); got != short {
+		t.Fatalf("want a payload within the limit to be kept, got %d bytes", len(got))
+	}
+
+	long := strings.Repeat("a", maxRecordedPayload+10)
+	if got := truncatePayload([]byte(long)); got != short+"..." {
+		t.Fatalf("want a payload over the limit to be truncated, got %d bytes", len(got))
+	}
+}
+
+func Test_NewHandler_RecordsExecutions(t *testing.T) {
+	server, _, _ := newEchoServer(0)
+	defer server.Close()
+
+	function := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("done"))
+	}))
+	defer function.Close()
+	functionURL, _ := url.Parse(function.URL)
+
+	echoURL := server.URL
+	lookup, _ := newTestLookup(t, "openfaas-fn", map[string]v1.FlowSpec{
+		"checkout": {
+			Args: []string{"id"},
+			Children: map[string]v1.FlowChild{
+				"stock": {Function: "echo", ArgsMap: map[string]string{"id": "id"}},
+				"promo": {Function: "echo", When: "args.id == \"vip\""},
+			},
+		},
+		"echo": {Args: []string{"id"}, IsThirdParty: true, ThirdPartyURL: &echoURL},
+	})
+
+	executions := NewExecutionStore(10)
+	handler := NewHandler(types.FaaSConfig{}, "openfaas-fn", nil, testResolver{url: functionURL}, lookup, nil, executions, nil, nil, false)
+
+	rr := serveFlow(handler, "checkout", `{"id":"sku-1"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("want status %d, got: %d, body: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	id := rr.Header().Get(executionIDHeader)
+	execution := executions.Get(id)
+	if execution == nil {
+		t.Fatalf("want execution %q to be recorded", id)
+	}
+	if execution.Flow != "checkout" || execution.Status != http.StatusCreated || execution.Input != `{"id":"sku-1"}` || execution.Output != "done" {
+		t.Fatalf("want the flow's request and response, got: %+v", execution)
+	}
+
+	nodes := map[string]NodeRecord{}
+	for _, node := range execution.Nodes {
+		nodes[node.Path] = node
+	}
+
+	stock := nodes["checkout/stock"]
+	if stock.State != NodeSucceeded || stock.Status != http.StatusOK || stock.Attempts != 1 || stock.Input != `{"id":"sku-1"}` || stock.Output != "sku-1" {
+		t.Errorf("want stock to be recorded, got: %+v", stock)
+	}
+	promo := nodes["checkout/promo"]
+	if promo.State != NodeSkipped || promo.Reason != "condition not met" {
+		t.Errorf("want promo to be recorded as skipped, got: %+v", promo)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/system/flows/checkout/executions/"+id, nil)
+	req = mux.SetURLVars(req, map[string]string{"name": "checkout", "id": id})
+	rr = httptest.NewRecorder()
+	MakeExecutionHandler(executions)(rr, req)
+
+	var got Execution
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil || got.ID != id || len(got.Nodes) != 2 {
+		t.Fatalf("want the execution as JSON, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/system/flows/other/executions/"+id, nil)
+	req = mux.SetURLVars(req, map[string]string{"name": "other", "id": id})
+	rr = httptest.NewRecorder()
+	MakeExecutionHandler(executions)(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("want 404 for the execution of another flow, got %d", rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/system/flows/checkout/executions", nil)
+	req = mux.SetURLVars(req, map[string]string{"name": "checkout"})
+	rr = httptest.NewRecorder()
+	MakeExecutionListHandler(executions)(rr, req)
+
+	var summaries []ExecutionSummary
+	if err := json.Unmarshal(rr.Body.Bytes(), &summaries); err != nil || len(summaries) != 1 || summaries[0].ID != id || summaries[0].Nodes != 2 {
+		t.Fatalf("want a summary of the execution, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,507 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package flows
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The expression language used by FlowChild.When is deliberately small, it
+// has no function calls or assignments so that a flow definition can never
+// do more than compare values:
+//
+//	expr       = or
+//	or         = and { "||" and }
+//	and        = unary { "&&" unary }
+//	unary      = "!" unary | comparison
+//	comparison = operand [ ( "==" | "!=" | "<" | "<=" | ">" | ">=" ) operand ]
+//	operand    = number | string | "true" | "false" | "null" | path | "(" expr ")"
+//	path       = ( "args" | "children" ) { "." name | "[" number "]" }
+//
+// For example: args.amount > 1000 && children.fraud.score < 0.5
+//
+// A path which does not resolve evaluates to null, and values of different
+// types are never ordered, so evaluation itself can not fail.
+
+const (
+	argsRoot     = "args"
+	childrenRoot = "children"
+)
+
+// expression is a parsed FlowChild.When condition
+type expression struct {
+	root exprNode
+	// paths lists every path used in the expression
+	paths [][]string
+}
+
+// parseExpression parses a condition, the zero-length condition is not valid
+func parseExpression(src string) (*expression, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+	}
+
+	return &expression{root: root, paths: p.paths}, nil
+}
+
+// eval evaluates the expression against the parent's args and the outputs
+// of the children which have completed
+func (e *expression) eval(args map[string]interface{}, children map[string]interface{}) bool {
+	return truthy(e.value(args, children))
+}
+
+// isChildPath returns true when an ArgsMap value is a path to the output
+// of a sibling, such as children.user.id, rather than the name of an arg
+func isChildPath(src string) bool {
+	return strings.HasPrefix(src, childrenRoot+".") || strings.HasPrefix(src, childrenRoot+"[")
+}
+
+// parseChildPath parses an ArgsMap value which is a path to the output of
+// a sibling
+func parseChildPath(src string) (*expression, error) {
+	expr, err := parseExpression(src)
+	if err != nil {
+		return nil, err
+	}
+
+	if path, ok := expr.root.(pathNode); !ok || path.path[0] != childrenRoot || len(path.path) < 2 {
+		return nil, fmt.Errorf("%q must be a path such as children.alias.field", src)
+	}
+	return expr, nil
+}
+
+// value evaluates an expression which is a path, such as one parsed by
+// parseChildPath
+func (e *expression) value(args map[string]interface{}, children map[string]interface{}) interface{} {
+	env := map[string]interface{}{
+		argsRoot:     args,
+		childrenRoot: children,
+	}
+	return e.root.eval(env)
+}
+
+// outputValue decodes a child's output for use in an expression, output
+// which is not JSON is used as a string
+func outputValue(data []byte) interface{} {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return string(data)
+	}
+	return value
+}
+
+type exprNode interface {
+	eval(env map[string]interface{}) interface{}
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n literalNode) eval(map[string]interface{}) interface{} {
+	return n.value
+}
+
+type pathNode struct {
+	path []string
+}
+
+func (n pathNode) eval(env map[string]interface{}) interface{} {
+	var current interface{} = env
+	for _, part := range n.path {
+		switch v := current.(type) {
+		case map[string]interface{}:
+			current = v[part]
+		case []interface{}:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil
+			}
+			current = v[i]
+		default:
+			return nil
+		}
+	}
+	return current
+}
+
+type notNode struct {
+	operand exprNode
+}
+
+func (n notNode) eval(env map[string]interface{}) interface{} {
+	return !truthy(n.operand.eval(env))
+}
+
+type logicalNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n logicalNode) eval(env map[string]interface{}) interface{} {
+	left := truthy(n.left.eval(env))
+	if n.op == "&&" {
+		return left && truthy(n.right.eval(env))
+	}
+	return left || truthy(n.right.eval(env))
+}
+
+type compareNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n compareNode) eval(env map[string]interface{}) interface{} {
+	left := n.left.eval(env)
+	right := n.right.eval(env)
+
+	switch n.op {
+	case "==":
+		return equal(left, right)
+	case "!=":
+		return !equal(left, right)
+	}
+
+	cmp, ok := compare(left, right)
+	if !ok {
+		return false
+	}
+
+	switch n.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+func equal(a, b interface{}) bool {
+	if cmp, ok := compare(a, b); ok {
+		return cmp == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// compare orders two numbers or two strings
+func compare(a, b interface{}) (int, bool) {
+	switch x := a.(type) {
+	case float64:
+		if y, ok := b.(float64); ok {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), true
+		}
+	}
+	return 0, false
+}
+
+func truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return len(v) > 0
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	}
+	return true
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenOp
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value interface{}
+	pos   int
+}
+
+func tokenize(src string) ([]token, error) {
+	tokens := []token{}
+	runes := []rune(src)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1]) && expectsOperand(tokens)):
+			start := i
+			i++
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			text := string(runes[start:i])
+			value, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at position %d", text, start)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: text, value: value, pos: start})
+
+		case r == '"' || r == '\'':
+			start := i
+			i++
+			var sb strings.Builder
+			for ; i < len(runes) && runes[i] != r; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				sb.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated string at position %d", start)
+			}
+			i++
+			tokens = append(tokens, token{kind: tokenString, text: string(runes[start:i]), value: sb.String(), pos: start})
+
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '-') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: string(runes[start:i]), pos: start})
+
+		default:
+			op := ""
+			if i+1 < len(runes) {
+				switch two := string(runes[i : i+2]); two {
+				case "==", "!=", "<=", ">=", "&&", "||":
+					op = two
+				}
+			}
+			if op == "" {
+				switch r {
+				case '<', '>', '!', '(', ')', '.', '[', ']':
+					op = string(r)
+				default:
+					return nil, fmt.Errorf("unexpected %q at position %d", string(r), i)
+				}
+			}
+			tokens = append(tokens, token{kind: tokenOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, text: "end of expression", pos: len(runes)}), nil
+}
+
+// expectsOperand returns true when a '-' starts a negative number rather
+// than following an operand
+func expectsOperand(tokens []token) bool {
+	if len(tokens) == 0 {
+		return true
+	}
+	last := tokens[len(tokens)-1]
+	return last.kind == tokenOp && last.text != ")" && last.text != "]"
+}
+
+type exprParser struct {
+	tokens []token
+	pos    int
+	paths  [][]string
+}
+
+func (p *exprParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *exprParser) isOp(text string) bool {
+	tok := p.peek()
+	return tok.kind == tokenOp && tok.text == text
+}
+
+func (p *exprParser) expectOp(text string) error {
+	if !p.isOp(text) {
+		tok := p.peek()
+		return fmt.Errorf("expected %q but found %q at position %d", text, tok.text, tok.pos)
+	}
+	p.next()
+	return nil
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("&&") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.isOp("!") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (exprNode, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.isOp(op) {
+			p.next()
+			right, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			return compareNode{op: op, left: left, right: right}, nil
+		}
+	}
+
+	return left, nil
+}
+
+func (p *exprParser) parseOperand() (exprNode, error) {
+	tok := p.next()
+
+	switch tok.kind {
+	case tokenNumber, tokenString:
+		return literalNode{value: tok.value}, nil
+
+	case tokenIdent:
+		switch tok.text {
+		case "true":
+			return literalNode{value: true}, nil
+		case "false":
+			return literalNode{value: false}, nil
+		case "null":
+			return literalNode{value: nil}, nil
+		case argsRoot, childrenRoot:
+			return p.parsePath(tok.text)
+		}
+		return nil, fmt.Errorf("unknown name %q at position %d, paths must start with args or children", tok.text, tok.pos)
+
+	case tokenOp:
+		if tok.text == "(" {
+			inner, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp(")"); err != nil {
+				return nil, err
+			}
+			return inner, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+}
+
+func (p *exprParser) parsePath(root string) (exprNode, error) {
+	path := []string{root}
+
+	for {
+		switch {
+		case p.isOp("."):
+			p.next()
+			tok := p.next()
+			if tok.kind != tokenIdent {
+				return nil, fmt.Errorf("expected a name after '.' at position %d", tok.pos)
+			}
+			path = append(path, tok.text)
+
+		case p.isOp("["):
+			p.next()
+			tok := p.next()
+			switch tok.kind {
+			case tokenNumber:
+				path = append(path, tok.text)
+			case tokenString:
+				path = append(path, tok.value.(string))
+			default:
+				return nil, fmt.Errorf("expected an index or a key at position %d", tok.pos)
+			}
+			if err := p.expectOp("]"); err != nil {
+				return nil, err
+			}
+
+		default:
+			p.paths = append(p.paths, path)
+			return pathNode{path: path}, nil
+		}
+	}
+}
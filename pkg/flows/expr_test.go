@@ -0,0 +1,79 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package flows
+
+import "testing"
+
+func Test_parseExpression_Eval(t *testing.T) {
+	args := map[string]interface{}{
+		"amount":  float64(1500),
+		"country": "NL",
+		"vip":     true,
+		"items":   []interface{}{"a", "b"},
+	}
+	children := map[string]interface{}{
+		"fraud":   map[string]interface{}{"score": 0.2, "flags": []interface{}{"new-card"}},
+		"plain":   "ok",
+		"user-id": map[string]interface{}{"id": "42"},
+	}
+
+	cases := []struct {
+		expr string
+		want bool
+	}{
+		{"args.amount > 1000", true},
+		{"args.amount <= 1000", false},
+		{"args.amount >= -5", true},
+		{`args.country == "NL"`, true},
+		{`args.country != 'NL'`, false},
+		{"args.vip", true},
+		{"!args.vip", false},
+		{"args.missing", false},
+		{"args.missing == null", true},
+		{"args.items[1] == \"b\"", true},
+		{"args.items[5] == null", true},
+		{"args.amount > 1000 && children.fraud.score < 0.5", true},
+		{"args.amount < 1000 || children.fraud.flags[0] == \"new-card\"", true},
+		{"!(args.amount > 1000 && args.vip)", false},
+		{`children.plain == "ok"`, true},
+		{`children["user-id"].id == "42"`, true},
+		{`children.user-id.id == "42"`, true},
+		{`args.country > 1`, false},
+		{"true && !false", true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.expr, func(t *testing.T) {
+			expr, err := parseExpression(tc.expr)
+			if err != nil {
+				t.Fatalf("want no error, got: %s", err)
+			}
+			if got := expr.eval(args, children); got != tc.want {
+				t.Fatalf("want %t, got: %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func Test_parseExpression_Errors(t *testing.T) {
+	cases := []string{
+		"",
+		"amount > 1000",
+		"args.amount >",
+		"args.amount > 1000)",
+		"(args.amount > 1000",
+		`args.country == "NL`,
+		"args.amount = 1000",
+		"args.",
+		"len(args.items) > 1",
+	}
+
+	for _, tc := range cases {
+		t.Run(tc, func(t *testing.T) {
+			if _, err := parseExpression(tc); err == nil {
+				t.Fatalf("want an error for %q", tc)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+// Package flows implements the flow proxy, which invokes the children of a
+// Flow before passing their responses on to the flow's own function.
+package flows
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	fhttputil "github.com/danenherdi/faas-provider/httputil"
+	"github.com/danenherdi/faas-provider/proxy"
+	"github.com/danenherdi/faas-provider/types"
+	"github.com/gorilla/mux"
+	"github.com/openfaas/faas-netes/pkg/cachetrace"
+	"github.com/openfaas/faas-netes/pkg/k8s"
+	"github.com/openfaas/faas-netes/pkg/orchestrator"
+	"github.com/openfaas/faas-netes/pkg/tracing"
+)
+
+// Shared HTTP client used to call the third-party children of a flow. It
+// has no timeout of its own, so that the timeout of each child applies
+// in full, see thirdPartyRequest.do.
+var sharedHTTPClient = &http.Client{
+	Transport: &http.Transport{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 100,
+		MaxConnsPerHost:     100,
+		IdleConnTimeout:     90 * time.Second,
+	},
+}
+
+// defaultThirdPartyTimeout limits a call to a third-party API when the
+// child which makes it has no timeout
+var defaultThirdPartyTimeout = 30 * time.Second
+
+// NewHandler creates the flow proxy. Flows are looked up on every request so
+// that changes to Flow resources take effect without a restart. The
+// namespace of the flows is part of the key of each cached response, and
+// the namespace from which secrets are read for third-party APIs. Each run
+// of a flow is recorded in executions, unless it is nil, and as a span with
+// a child span for each of its nodes. The accesses to the cache are
+// recorded by orchestrator and captured by cacheTrace, unless they are nil.
+func NewHandler(config types.FaaSConfig, namespace string, cacheClient types.CacheClient, resolver proxy.BaseURLResolver, lookup Lookup, secrets k8s.SecretsClient, executions *ExecutionStore, orchestrator *orchestrator.Orchestrator, cacheTrace *cachetrace.Recorder, verbose bool) http.HandlerFunc {
+	if resolver == nil {
+		panic("NewHandler: empty proxy handler resolver, cannot be nil")
+	}
+
+	rn := &runner{
+		lookup:      lookup,
+		namespace:   namespace,
+		flights:     newFlightGroup(),
+		secrets:     newSecretStore(secrets, namespace),
+		proxyClient: proxy.NewProxyClientFromConfig(config),
+		resolver:    resolver,
+		verbose:     verbose,
+	}
+	if config.EnableCaching && cacheClient != nil {
+		rn.cacheClient = cacheClient
+		rn.cacheBackend = cacheBackend(cacheClient)
+		rn.orchestrator = orchestrator
+		rn.cacheTrace = cacheTrace
+	}
+
+	return tracing.Handler("flow", func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil {
+			defer r.Body.Close()
+		}
+
+		// every request is counted, including those rejected before the
+		// flow runs, the flow is only named once it has been found
+		started := time.Now()
+		ww := fhttputil.NewHttpWriteInterceptor(w)
+		w = ww
+		metricName := ""
+		defer func() {
+			observeFlow(metricName, ww.Status(), time.Since(started))
+		}()
+
+		functionName := mux.Vars(r)["name"]
+		if functionName == "" {
+			fhttputil.Errorf(w, http.StatusBadRequest, "Provide function name in the request path")
+			return
+		}
+
+		flow, err := lookup.Get(functionName)
+		if err != nil {
+			if IsNotFound(err) {
+				fhttputil.Errorf(w, http.StatusNotFound, "Unable to find flow: %s", functionName)
+				return
+			}
+			log.Printf("error looking up flow %s: %s", functionName, err.Error())
+			fhttputil.Errorf(w, http.StatusInternalServerError, "Unable to look up flow: %s", functionName)
+			return
+		}
+
+		metricName = functionName
+
+		problems, err := ValidateReachable(functionName, lookup)
+		if err != nil {
+			log.Printf("error validating flow %s: %s", functionName, err.Error())
+			fhttputil.Errorf(w, http.StatusInternalServerError, "Unable to validate flow: %s", functionName)
+			return
+		}
+		if len(problems) > 0 {
+			fhttputil.Errorf(w, http.StatusInternalServerError, "Flow %s is invalid: %s", functionName, joinProblems(problems))
+			return
+		}
+
+		var requestBody map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			log.Printf("error decoding request body of flow %s: %s", functionName, err.Error())
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		trace := newCacheTrace()
+		ctx := withCacheTrace(r.Context(), trace)
+
+		if executions != nil {
+			recorder := newExecutionRecorder(functionName, r.Header.Get(callIDHeader), requestBody)
+			ctx = withExecution(ctx, recorder)
+
+			recording := &recordingWriter{ResponseWriter: w}
+			w = recording
+			w.Header().Set(executionIDHeader, recorder.id())
+			tracing.SpanFrom(ctx).SetAttribute("flow.execution_id", recorder.id())
+
+			defer func() {
+				executions.add(recorder.finish(recording.status, recording.body, trace))
+			}()
+		}
+		r = r.WithContext(ctx)
+
+		// the children are run before the cache is read when their outputs
+		// are part of the key
+		var children *childOutputs
+		var cacheKey string
+		if rn.cacheable(flow) {
+			var outputs map[string]*types.FlowOutput
+			if keyedChildren(flow) {
+				if children, err = rn.runChildren(ctx, functionName, flow, requestBody); err != nil {
+					writeChildError(w, functionName, err)
+					return
+				}
+				outputs = children.outputs
+			}
+
+			cacheKey, err = flowCacheKey(namespace, functionName, flow, r, requestBody, outputs)
+			if err != nil {
+				log.Printf("error creating cache key of flow %s: %s", functionName, err.Error())
+			}
+		}
+
+		if len(cacheKey) == 0 {
+			rn.runFlow(w, r, functionName, functionName, flow, requestBody, children, false)
+			return
+		}
+
+		res := rn.serve(ctx, functionName, flow, cacheKey, func(ctx context.Context) (*bufferedResponse, bool) {
+			res := newBufferedResponse()
+			cacheResponse := rn.runFlow(res, r.Clone(ctx), functionName, functionName, flow, requestBody, children, true)
+			return res, cacheResponse != nil
+		})
+		if res.err != nil {
+			// no response was received, as when the request gave up waiting
+			// for a response shared with another
+			log.Printf("error serving flow %s: %s", functionName, res.err.Error())
+			status := http.StatusBadGateway
+			if errors.Is(res.err, context.DeadlineExceeded) {
+				status = http.StatusGatewayTimeout
+			}
+			fhttputil.Errorf(w, status, "Unable to serve flow: %s", functionName)
+			return
+		}
+
+		// a response from the cache replaces this with HIT or STALE
+		w.Header().Set(cacheStatusHeader, "MISS")
+		setCacheTrace(w, ctx)
+		res.writeTo(w)
+	})
+}
+
+// FlowInput is the body passed to the function of a flow. It extends
+// types.FlowInput with the children which were skipped, either because
+// their When condition was not met or because they failed with an OnError
+// policy of skip.
+type FlowInput struct {
+	types.FlowInput
+
+	// Skipped maps the alias of each skipped child to the reason
+	Skipped map[string]string `json:"skipped,omitempty"`
+}
+
+// ChildErrorResponse is returned to the caller of a flow when one of its
+// children fails
+type ChildErrorResponse struct {
+	// Flow is the name of the flow which was invoked
+	Flow string `json:"flow"`
+	// Child is the alias of the child which failed
+	Child string `json:"child"`
+	// Function is the flow invoked by the failed child
+	Function string `json:"function"`
+	// StatusCode is the status returned by the child, if any
+	StatusCode int `json:"statusCode,omitempty"`
+	// Attempts is the number of calls made to the child
+	Attempts int `json:"attempts"`
+	// Error describes the failure
+	Error string `json:"error"`
+}
+
+// writeChildError reports a failed child to the caller of a flow, with a
+// 504 when the child timed out and a 502 for any other failure
+func writeChildError(w http.ResponseWriter, functionName string, err error) {
+	log.Printf("error invoking children of flow %s: %s", functionName, err.Error())
+
+	var childErr *ChildError
+	if !errors.As(err, &childErr) {
+		fhttputil.Errorf(w, http.StatusServiceUnavailable, "Unable to invoke children of flow: %s", functionName)
+		return
+	}
+
+	res := ChildErrorResponse{
+		Flow:       functionName,
+		Child:      childErr.Alias,
+		Function:   childErr.Function,
+		StatusCode: childErr.StatusCode,
+		Attempts:   childErr.Attempts,
+		Error:      childErr.Err.Error(),
+	}
+	jsonResp, _ := json.Marshal(res)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(openFaaSInternalHeader, "proxy")
+	w.WriteHeader(childErr.HTTPStatus())
+	w.Write(jsonResp)
+}
@@ -0,0 +1,433 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package flows
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/danenherdi/faas-provider/types"
+	"github.com/gorilla/mux"
+	v1 "github.com/openfaas/faas-netes/pkg/apis/openfaas/v1"
+	"github.com/openfaas/faas-netes/pkg/cachetrace"
+)
+
+type testResolver struct {
+	url *url.URL
+}
+
+func (r testResolver) Resolve(name string) (url.URL, error) {
+	return *r.url, nil
+}
+
+func serveFlow(handler http.HandlerFunc, name, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/flow/"+name, strings.NewReader(body))
+	req = mux.SetURLVars(req, map[string]string{"name": name})
+
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+func Test_NewHandler_UnknownFlow(t *testing.T) {
+	lookup, _ := newTestLookup(t, "openfaas-fn", nil)
+	handler := NewHandler(types.FaaSConfig{}, "openfaas-fn", nil, testResolver{url: &url.URL{}}, lookup, nil, nil, nil, nil, false)
+
+	rr := serveFlow(handler, "missing", `{}`)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("want status %d, got: %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func Test_NewHandler_PassesChildrenToFunction(t *testing.T) {
+	thirdParty := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var args map[string]interface{}
+		json.Unmarshal(body, &args)
+		w.Write([]byte(`{"rate":"` + args["currency"].(string) + `"}`))
+	}))
+	defer thirdParty.Close()
+
+	var received types.FlowInput
+	function := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+		w.Write([]byte("done"))
+	}))
+	defer function.Close()
+	functionURL, _ := url.Parse(function.URL)
+
+	thirdPartyURL := thirdParty.URL
+	lookup, _ := newTestLookup(t, "openfaas-fn", map[string]v1.FlowSpec{
+		"convert": {
+			Args: []string{"amount", "to"},
+			Children: map[string]v1.FlowChild{
+				"fx": {Function: "rates", ArgsMap: map[string]string{"currency": "to"}},
+			},
+		},
+		"rates": {Args: []string{"currency"}, IsThirdParty: true, ThirdPartyURL: &thirdPartyURL},
+	})
+
+	handler := NewHandler(types.FaaSConfig{}, "openfaas-fn", nil, testResolver{url: functionURL}, lookup, nil, nil, nil, nil, false)
+
+	rr := serveFlow(handler, "convert", `{"amount": 10, "to": "EUR"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("want status %d, got: %d, body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if rr.Body.String() != "done" {
+		t.Fatalf("want body from function, got: %s", rr.Body.String())
+	}
+
+	fx, ok := received.Children["fx"]
+	if !ok {
+		t.Fatalf("want child fx in the function's input, got: %+v", received.Children)
+	}
+	if string(fx.Data) != `{"rate":"EUR"}` {
+		t.Fatalf("want child data to be passed through, got: %s", string(fx.Data))
+	}
+	if received.Args["to"] != "EUR" {
+		t.Fatalf("want args to be passed through, got: %v", received.Args)
+	}
+}
+
+func Test_NewHandler_ReportsFailedChild(t *testing.T) {
+	server, _ := newFailingServer(100, http.StatusInternalServerError)
+	defer server.Close()
+
+	upstreamURL := server.URL
+	lookup, _ := newTestLookup(t, "openfaas-fn", map[string]v1.FlowSpec{
+		"upstream": {IsThirdParty: true, ThirdPartyURL: &upstreamURL},
+		"checkout": {Children: map[string]v1.FlowChild{"rate": {Function: "upstream"}}},
+	})
+	handler := NewHandler(types.FaaSConfig{}, "openfaas-fn", nil, testResolver{url: &url.URL{}}, lookup, nil, nil, nil, nil, false)
+
+	rr := serveFlow(handler, "checkout", `{}`)
+	if rr.Code != http.StatusBadGateway {
+		t.Fatalf("want status %d, got: %d", http.StatusBadGateway, rr.Code)
+	}
+
+	var res ChildErrorResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatalf("unable to decode response: %s", err)
+	}
+	if res.Flow != "checkout" || res.Child != "rate" || res.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("unexpected response: %+v", res)
+	}
+}
+
+// newCountingFunction returns a function which responds with its body after
+// delay and counts its calls
+func newCountingFunction(delay time.Duration, body string) (*httptest.Server, *int32) {
+	var calls int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(delay)
+		w.Write([]byte(body))
+	}))
+	return server, &calls
+}
+
+func Test_NewHandler_CoalescesCacheMisses(t *testing.T) {
+	function, calls := newCountingFunction(100*time.Millisecond, "done")
+	defer function.Close()
+	functionURL, _ := url.Parse(function.URL)
+
+	lookup, _ := newTestLookup(t, "openfaas-fn", map[string]v1.FlowSpec{
+		"report": {Args: []string{"id"}, Caching: true, CacheTTL: 60},
+	})
+	handler := NewHandler(types.FaaSConfig{EnableCaching: true}, "openfaas-fn", newMapCache(), testResolver{url: functionURL}, lookup, nil, nil, nil, nil, false)
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 10)
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			bodies[i] = serveFlow(handler, "report", `{"id": 1}`).Body.String()
+		}(i)
+	}
+	wg.Wait()
+
+	if *calls != 1 {
+		t.Fatalf("want 1 call to the function, got: %d", *calls)
+	}
+	for _, body := range bodies {
+		if body != "done" {
+			t.Fatalf("want every caller to get the response, got: %q", body)
+		}
+	}
+}
+
+func Test_NewHandler_CoalescedWaitersSeeFailure(t *testing.T) {
+	var calls int32
+	function := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(200 * time.Millisecond)
+		http.Error(w, "failed", http.StatusInternalServerError)
+	}))
+	defer function.Close()
+	functionURL, _ := url.Parse(function.URL)
+
+	lookup, _ := newTestLookup(t, "openfaas-fn", map[string]v1.FlowSpec{
+		"report": {Args: []string{"id"}, Caching: true, CacheTTL: 60},
+	})
+	handler := NewHandler(types.FaaSConfig{EnableCaching: true}, "openfaas-fn", newMapCache(), testResolver{url: functionURL}, lookup, nil, nil, nil, nil, false)
+
+	var wg sync.WaitGroup
+	codes := make([]int, 5)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i] = serveFlow(handler, "report", `{"id": 1}`).Code
+		}(i)
+	}
+
+	// a waiter which gives up before the leader completes
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	time.Sleep(10 * time.Millisecond)
+	req := httptest.NewRequest(http.MethodPost, "/flow/report", strings.NewReader(`{"id": 1}`)).WithContext(ctx)
+	rr := httptest.NewRecorder()
+	handler(rr, mux.SetURLVars(req, map[string]string{"name": "report"}))
+	if rr.Code != http.StatusGatewayTimeout {
+		t.Fatalf("want a waiter which timed out to get %d, got: %d", http.StatusGatewayTimeout, rr.Code)
+	}
+
+	wg.Wait()
+	if calls != 1 {
+		t.Fatalf("want 1 call to the function, got: %d", calls)
+	}
+	for _, code := range codes {
+		if code != http.StatusInternalServerError {
+			t.Fatalf("want every waiter to get the leader's failure, got: %d", code)
+		}
+	}
+}
+
+func Test_NewHandler_ServesStaleWhileRevalidating(t *testing.T) {
+	function, calls := newCountingFunction(0, "new")
+	defer function.Close()
+	functionURL, _ := url.Parse(function.URL)
+
+	lookup, _ := newTestLookup(t, "openfaas-fn", map[string]v1.FlowSpec{
+		"report": {Args: []string{"id"}, Caching: true, CacheTTL: 1, StaleTTL: 60},
+	})
+	cacheClient := newMapCache()
+	handler := NewHandler(types.FaaSConfig{EnableCaching: true}, "openfaas-fn", cacheClient, testResolver{url: functionURL}, lookup, nil, nil, nil, nil, false)
+
+	flow, _ := lookup.Get("report")
+	req := httptest.NewRequest(http.MethodPost, "/flow/report", nil)
+	key, err := flowCacheKey("openfaas-fn", "report", flow, req, map[string]interface{}{"id": float64(1)}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale, _ := encodeCacheEntry(cacheEntry{Created: time.Now().Add(-2 * time.Second), Body: []byte("old")})
+	cacheClient.SetEx(context.Background(), key, stale, time.Minute)
+
+	if body := serveFlow(handler, "report", `{"id": 1}`).Body.String(); body != "old" {
+		t.Fatalf("want the stale response, got: %q", body)
+	}
+
+	for i := 0; i < 100 && atomic.LoadInt32(calls) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if atomic.LoadInt32(calls) != 1 {
+		t.Fatalf("want the response to be refreshed in the background")
+	}
+
+	for i := 0; i < 100; i++ {
+		if body := serveFlow(handler, "report", `{"id": 1}`).Body.String(); body == "new" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("want the refreshed response to be served")
+}
+
+func Test_NewHandler_CachesChildrenInProcess(t *testing.T) {
+	var childCalls int32
+	function := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var input types.FlowInput
+		json.NewDecoder(r.Body).Decode(&input)
+
+		// the flow's function is called with its child's output, and the
+		// child's function with the child's args
+		if profile, ok := input.Children["profile"]; ok {
+			w.Write(profile.Data)
+			return
+		}
+		atomic.AddInt32(&childCalls, 1)
+		w.Write([]byte(`{"user":"` + input.Args["id"].(string) + `"}`))
+	}))
+	defer function.Close()
+	functionURL, _ := url.Parse(function.URL)
+
+	lookup, _ := newTestLookup(t, "openfaas-fn", map[string]v1.FlowSpec{
+		"page": {
+			Args:     []string{"user"},
+			Children: map[string]v1.FlowChild{"profile": {Function: "user", ArgsMap: map[string]string{"id": "user"}}},
+		},
+		"user": {Args: []string{"id"}, Caching: true, CacheTTL: 60},
+	})
+	handler := NewHandler(types.FaaSConfig{EnableCaching: true}, "openfaas-fn", newMapCache(), testResolver{url: functionURL}, lookup, nil, nil, nil, nil, false)
+
+	for i, want := range []string{"page/profile=miss", "page/profile=hit"} {
+		rr := serveFlow(handler, "page", `{"user": "alex"}`)
+		if rr.Code != http.StatusOK || rr.Body.String() != `{"user":"alex"}` {
+			t.Fatalf("want the child's output, got: %d %s", rr.Code, rr.Body.String())
+		}
+		if got := rr.Header().Get(cacheTraceHeader); got != want {
+			t.Fatalf("request %d: want trace %q, got: %q", i+1, want, got)
+		}
+	}
+
+	if childCalls != 1 {
+		t.Fatalf("want the child to be served from the cache, got %d calls", childCalls)
+	}
+}
+
+func Test_NewHandler_KeysOnChildOutputs(t *testing.T) {
+	var flowCalls int32
+	price := "1"
+	function := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var input types.FlowInput
+		json.NewDecoder(r.Body).Decode(&input)
+
+		if child, ok := input.Children["price"]; ok {
+			atomic.AddInt32(&flowCalls, 1)
+			w.Write([]byte("total=" + string(child.Data)))
+			return
+		}
+		w.Write([]byte(price))
+	}))
+	defer function.Close()
+	functionURL, _ := url.Parse(function.URL)
+
+	lookup, _ := newTestLookup(t, "openfaas-fn", map[string]v1.FlowSpec{
+		"checkout": {
+			Caching:  true,
+			CacheTTL: 60,
+			CacheKey: &v1.FlowCacheKey{Children: []string{"price"}},
+			Children: map[string]v1.FlowChild{"price": {Function: "prices"}},
+		},
+		"prices": {},
+	})
+	handler := NewHandler(types.FaaSConfig{EnableCaching: true}, "openfaas-fn", newMapCache(), testResolver{url: functionURL}, lookup, nil, nil, nil, nil, false)
+
+	for i, tc := range []struct {
+		price, want, status string
+	}{
+		{price: "1", want: "total=1", status: "MISS"},
+		{price: "1", want: "total=1", status: "HIT"},
+		{price: "2", want: "total=2", status: "MISS"},
+	} {
+		price = tc.price
+		rr := serveFlow(handler, "checkout", `{}`)
+		if rr.Body.String() != tc.want || rr.Header().Get(cacheStatusHeader) != tc.status {
+			t.Fatalf("request %d: want %s %q, got: %s %q", i+1, tc.status, tc.want, rr.Header().Get(cacheStatusHeader), rr.Body.String())
+		}
+	}
+
+	if flowCalls != 2 {
+		t.Fatalf("want the flow's function to be called once for each output of its child, got %d calls", flowCalls)
+	}
+}
+
+func Test_NewHandler_CachesStatusAndHeaders(t *testing.T) {
+	status := http.StatusInternalServerError
+	function := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		w.WriteHeader(status)
+		w.Write([]byte("a,b"))
+	}))
+	defer function.Close()
+	functionURL, _ := url.Parse(function.URL)
+
+	lookup, _ := newTestLookup(t, "openfaas-fn", map[string]v1.FlowSpec{
+		"export": {Caching: true, CacheTTL: 60},
+	})
+	handler := NewHandler(types.FaaSConfig{EnableCaching: true}, "openfaas-fn", newMapCache(), testResolver{url: functionURL}, lookup, nil, nil, nil, nil, false)
+
+	// an error is not cached, so the next request reaches the function
+	if rr := serveFlow(handler, "export", `{}`); rr.Code != http.StatusInternalServerError || rr.Header().Get(cacheStatusHeader) != "MISS" {
+		t.Fatalf("want a 500 MISS, got: %d %s", rr.Code, rr.Header().Get(cacheStatusHeader))
+	}
+
+	status = http.StatusAccepted
+	if rr := serveFlow(handler, "export", `{}`); rr.Code != http.StatusAccepted || rr.Header().Get(cacheStatusHeader) != "MISS" {
+		t.Fatalf("want a 202 MISS, got: %d %s", rr.Code, rr.Header().Get(cacheStatusHeader))
+	}
+
+	status = http.StatusInternalServerError
+	rr := serveFlow(handler, "export", `{}`)
+	if rr.Code != http.StatusAccepted || rr.Body.String() != "a,b" {
+		t.Fatalf("want the cached 202, got: %d %s", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("Content-Type") != "text/csv" || rr.Header().Get(cacheStatusHeader) != "HIT" || rr.Header().Get(ageHeader) != "0" {
+		t.Fatalf("unexpected headers: %v", rr.Header())
+	}
+}
+
+// memorySink keeps the records of a cache trace
+type memorySink struct {
+	lock    sync.Mutex
+	records []cachetrace.Record
+}
+
+func (s *memorySink) WriteRecords(records []cachetrace.Record) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.records = append(s.records, records...)
+	return nil
+}
+
+func (s *memorySink) Close() error { return nil }
+
+func Test_NewHandler_CapturesCacheReads(t *testing.T) {
+	function, _ := newCountingFunction(0, "done")
+	defer function.Close()
+	functionURL, _ := url.Parse(function.URL)
+
+	lookup, _ := newTestLookup(t, "openfaas-fn", map[string]v1.FlowSpec{
+		"captured": {Args: []string{"id"}, Caching: true, CacheTTL: 60, StaleTTL: 30},
+	})
+	sink := &memorySink{}
+	recorder := cachetrace.NewRecorder(sink, 1)
+	handler := NewHandler(types.FaaSConfig{EnableCaching: true}, "openfaas-fn", newMapCache(), testResolver{url: functionURL}, lookup, nil, nil, nil, recorder, false)
+
+	for i := 0; i < 2; i++ {
+		if rr := serveFlow(handler, "captured", `{"id": 1}`); rr.Code != http.StatusOK {
+			t.Fatalf("want status %d, got: %d", http.StatusOK, rr.Code)
+		}
+	}
+	if err := recorder.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sink.records) != 2 {
+		t.Fatalf("want 2 records, got: %+v", sink.records)
+	}
+	miss, hit := sink.records[0], sink.records[1]
+	if miss.Hit || !hit.Hit {
+		t.Fatalf("want a miss and then a hit, got: %+v", sink.records)
+	}
+	if len(miss.Key) != 16 || miss.Key != hit.Key {
+		t.Fatalf("want the same hashed key for both reads, got: %s and %s", miss.Key, hit.Key)
+	}
+	for _, record := range sink.records {
+		if record.Flow != "captured" || record.Size != len("done") || record.TTL != 90 || record.Time.IsZero() {
+			t.Errorf("unexpected record: %+v", record)
+		}
+	}
+}
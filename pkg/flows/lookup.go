@@ -0,0 +1,102 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package flows
+
+import (
+	"fmt"
+
+	v1 "github.com/openfaas/faas-netes/pkg/apis/openfaas/v1"
+	listers "github.com/openfaas/faas-netes/pkg/client/listers/openfaas/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/discovery"
+)
+
+// Lookup resolves Flow definitions by name
+type Lookup interface {
+	// Get returns the spec of the named flow, or ErrFlowNotFound
+	Get(name string) (*v1.FlowSpec, error)
+	// List returns the spec of every known flow keyed by name
+	List() (map[string]v1.FlowSpec, error)
+}
+
+// ErrFlowNotFound is returned by a Lookup when no Flow exists with the given name
+type ErrFlowNotFound struct {
+	Name string
+}
+
+func (e *ErrFlowNotFound) Error() string {
+	return fmt.Sprintf("flow %q not found", e.Name)
+}
+
+// IsNotFound returns true when err is an ErrFlowNotFound
+func IsNotFound(err error) bool {
+	_, ok := err.(*ErrFlowNotFound)
+	return ok
+}
+
+// CheckInstalled returns an error when the Flow CRD is not served by the
+// API server. The informer of a resource which does not exist never syncs,
+// so this is checked before waiting for it.
+func CheckInstalled(client discovery.DiscoveryInterface) error {
+	groupVersion := v1.SchemeGroupVersion.String()
+
+	resources, err := client.ServerResourcesForGroupVersion(groupVersion)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("unable to discover %s: %w", groupVersion, err)
+	}
+	if resources != nil {
+		for _, resource := range resources.APIResources {
+			if resource.Name == "flows" {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("the Flow CRD (flows.%s) is not installed, apply artifacts/crds/openfaas.com_flows.yaml", v1.SchemeGroupVersion.Group)
+}
+
+// NewLookup creates a Lookup which reads Flow resources from an informer's
+// lister, so that changes made with kubectl are seen without a restart.
+func NewLookup(namespace string, lister listers.FlowLister) *InformerLookup {
+	return &InformerLookup{
+		namespace: namespace,
+		lister:    lister,
+	}
+}
+
+// InformerLookup is a Lookup backed by the Flow informer cache
+type InformerLookup struct {
+	namespace string
+	lister    listers.FlowLister
+}
+
+// Get returns the spec of the named flow. The returned value must be treated
+// as read-only as it is shared with the informer cache.
+func (l *InformerLookup) Get(name string) (*v1.FlowSpec, error) {
+	flow, err := l.lister.Flows(l.namespace).Get(name)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, &ErrFlowNotFound{Name: name}
+		}
+		return nil, err
+	}
+
+	return &flow.Spec, nil
+}
+
+// List returns the spec of every flow in the namespace keyed by name
+func (l *InformerLookup) List() (map[string]v1.FlowSpec, error) {
+	res, err := l.lister.Flows(l.namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	specs := make(map[string]v1.FlowSpec, len(res))
+	for _, flow := range res {
+		specs[flow.Name] = flow.Spec
+	}
+
+	return specs, nil
+}
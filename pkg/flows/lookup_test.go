@@ -0,0 +1,113 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package flows
+
+import (
+	"testing"
+
+	v1 "github.com/openfaas/faas-netes/pkg/apis/openfaas/v1"
+	listers "github.com/openfaas/faas-netes/pkg/client/listers/openfaas/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newTestLookup(t *testing.T, namespace string, specs map[string]v1.FlowSpec) (*InformerLookup, cache.Indexer) {
+	t.Helper()
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for name, spec := range specs {
+		flow := &v1.Flow{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec:       spec,
+		}
+		if err := indexer.Add(flow); err != nil {
+			t.Fatalf("unable to add flow %s: %s", name, err)
+		}
+	}
+
+	return NewLookup(namespace, listers.NewFlowLister(indexer)), indexer
+}
+
+func Test_InformerLookup_Get(t *testing.T) {
+	lookup, _ := newTestLookup(t, "openfaas-fn", map[string]v1.FlowSpec{
+		"checkout": {Args: []string{"user"}, Caching: true},
+	})
+
+	spec, err := lookup.Get("checkout")
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err)
+	}
+	if !spec.Caching || len(spec.Args) != 1 {
+		t.Fatalf("unexpected spec: %+v", spec)
+	}
+
+	_, err = lookup.Get("missing")
+	if !IsNotFound(err) {
+		t.Fatalf("want ErrFlowNotFound, got: %v", err)
+	}
+}
+
+func Test_InformerLookup_SeesUpdates(t *testing.T) {
+	lookup, indexer := newTestLookup(t, "openfaas-fn", nil)
+
+	if _, err := lookup.Get("checkout"); !IsNotFound(err) {
+		t.Fatalf("want ErrFlowNotFound before the flow is created, got: %v", err)
+	}
+
+	indexer.Add(&v1.Flow{
+		ObjectMeta: metav1.ObjectMeta{Name: "checkout", Namespace: "openfaas-fn"},
+		Spec:       v1.FlowSpec{CacheTTL: 30},
+	})
+
+	spec, err := lookup.Get("checkout")
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err)
+	}
+	if spec.CacheTTL != 30 {
+		t.Fatalf("want cacheTTL 30, got: %d", spec.CacheTTL)
+	}
+}
+
+func Test_InformerLookup_List_OnlyNamespace(t *testing.T) {
+	lookup, indexer := newTestLookup(t, "openfaas-fn", map[string]v1.FlowSpec{
+		"a": {},
+		"b": {},
+	})
+	indexer.Add(&v1.Flow{
+		ObjectMeta: metav1.ObjectMeta{Name: "c", Namespace: "other"},
+	})
+
+	specs, err := lookup.List()
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err)
+	}
+	if len(specs) != 2 {
+		t.Fatalf("want 2 flows, got: %d", len(specs))
+	}
+	if _, ok := specs["c"]; ok {
+		t.Fatalf("flow from another namespace should not be listed")
+	}
+}
+
+func Test_CheckInstalled(t *testing.T) {
+	client := &fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{}}
+	if err := CheckInstalled(client); err == nil {
+		t.Fatalf("want an error when the group is not served")
+	}
+
+	client.Resources = []*metav1.APIResourceList{{
+		GroupVersion: v1.SchemeGroupVersion.String(),
+		APIResources: []metav1.APIResource{{Name: "functions"}, {Name: "profiles"}},
+	}}
+	if err := CheckInstalled(client); err == nil {
+		t.Fatalf("want an error when the Flow CRD is not installed")
+	}
+
+	client.Resources[0].APIResources = append(client.Resources[0].APIResources, metav1.APIResource{Name: "flows"})
+	if err := CheckInstalled(client); err != nil {
+		t.Fatalf("want no error, got: %s", err)
+	}
+}
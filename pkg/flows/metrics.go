@@ -0,0 +1,78 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package flows
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/danenherdi/faas-provider/types"
+	"github.com/openfaas/faas-netes/pkg/caching"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Results of a cache read counted by flowCacheRequests, along with those of
+// cacheResult
+const cacheError = "error"
+
+var (
+	flowInvocations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "flow_invocations_total",
+		Help: "Requests to /flow by flow and status code.",
+	}, []string{"flow", "code"})
+
+	flowDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "flow_invocation_duration_seconds",
+		Help:    "Seconds spent serving requests to /flow by flow and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"flow", "code"})
+
+	nodeInvocations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "flow_node_invocations_total",
+		Help: "Children run by flows by alias, function and state.",
+	}, []string{"node", "function", "state"})
+
+	nodeDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "flow_node_duration_seconds",
+		Help:    "Seconds spent running the children of flows, including retries, by alias and function.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"node", "function"})
+
+	flowCacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "flow_cache_requests_total",
+		Help: "Reads of the flow cache by backend and result: hit, stale, miss or error.",
+	}, []string{"backend", "result"})
+)
+
+// observeFlow counts a request to /flow, name is empty when the flow was not
+// found so that the label is bounded by the flows which exist
+func observeFlow(name string, status int, duration time.Duration) {
+	code := strconv.Itoa(status)
+	flowInvocations.WithLabelValues(name, code).Inc()
+	flowDuration.WithLabelValues(name, code).Observe(duration.Seconds())
+}
+
+// observeNode counts a child of a flow by its alias in the spec rather than
+// its path, which grows with each level of nesting. A skipped child has no
+// duration.
+func observeNode(alias, function, state string, duration time.Duration) {
+	nodeInvocations.WithLabelValues(alias, function, state).Inc()
+	if state != NodeSkipped {
+		nodeDuration.WithLabelValues(alias, function).Observe(duration.Seconds())
+	}
+}
+
+// cacheBackend names the backend of cacheClient for flowCacheRequests
+func cacheBackend(cacheClient types.CacheClient) string {
+	switch cacheClient.(type) {
+	case *caching.Redis:
+		return "redis"
+	case *caching.Memory:
+		return "memory"
+	case *caching.PaperCache:
+		return "papercache"
+	}
+	return "other"
+}
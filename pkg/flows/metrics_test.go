@@ -0,0 +1,119 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package flows
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/danenherdi/faas-provider/types"
+	v1 "github.com/openfaas/faas-netes/pkg/apis/openfaas/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// counterValue reads a counter of a vector
+func counterValue(t *testing.T, counter prometheus.Counter) float64 {
+	var metric dto.Metric
+	if err := counter.Write(&metric); err != nil {
+		t.Fatal(err)
+	}
+	return metric.GetCounter().GetValue()
+}
+
+// failingCache fails every read
+type failingCache struct {
+	*mapCache
+}
+
+func (c failingCache) Get(ctx context.Context, key string) ([]byte, error) {
+	return nil, errors.New("connection refused")
+}
+
+func Test_NewHandler_CountsFlowsNodesAndCacheReads(t *testing.T) {
+	function, _ := newCountingFunction(0, "done")
+	defer function.Close()
+	functionURL, _ := url.Parse(function.URL)
+
+	lookup, _ := newTestLookup(t, "openfaas-fn", map[string]v1.FlowSpec{
+		"metrics-page": {
+			Args: []string{"vip"},
+			Children: map[string]v1.FlowChild{
+				"profile": {Function: "metrics-user"},
+				"promo":   {Function: "metrics-user", When: "args.vip == true"},
+			},
+		},
+		"metrics-user": {Caching: true, CacheTTL: 60},
+	})
+
+	before := map[string]float64{
+		"flow":      counterValue(t, flowInvocations.WithLabelValues("metrics-page", "200")),
+		"invalid":   counterValue(t, flowInvocations.WithLabelValues("metrics-page", "400")),
+		"unknown":   counterValue(t, flowInvocations.WithLabelValues("", "404")),
+		"succeeded": counterValue(t, nodeInvocations.WithLabelValues("profile", "metrics-user", NodeSucceeded)),
+		"skipped":   counterValue(t, nodeInvocations.WithLabelValues("promo", "metrics-user", NodeSkipped)),
+		"miss":      counterValue(t, flowCacheRequests.WithLabelValues("other", "miss")),
+		"hit":       counterValue(t, flowCacheRequests.WithLabelValues("other", "hit")),
+		"error":     counterValue(t, flowCacheRequests.WithLabelValues("other", cacheError)),
+	}
+
+	handler := NewHandler(types.FaaSConfig{EnableCaching: true}, "openfaas-fn", newMapCache(), testResolver{url: functionURL}, lookup, nil, nil, nil, nil, false)
+	for i := 0; i < 2; i++ {
+		if rr := serveFlow(handler, "metrics-page", `{}`); rr.Code != http.StatusOK {
+			t.Fatalf("want status %d, got: %d", http.StatusOK, rr.Code)
+		}
+	}
+
+	failing := NewHandler(types.FaaSConfig{EnableCaching: true}, "openfaas-fn", failingCache{newMapCache()}, testResolver{url: functionURL}, lookup, nil, nil, nil, nil, false)
+	if rr := serveFlow(failing, "metrics-page", `{}`); rr.Code != http.StatusOK {
+		t.Fatalf("want a failed read of the cache to be served as a miss, got: %d", rr.Code)
+	}
+
+	if rr := serveFlow(handler, "metrics-page", `{`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("want status %d for an invalid body, got: %d", http.StatusBadRequest, rr.Code)
+	}
+	if rr := serveFlow(handler, "metrics-missing", `{}`); rr.Code != http.StatusNotFound {
+		t.Fatalf("want status %d for an unknown flow, got: %d", http.StatusNotFound, rr.Code)
+	}
+
+	after := map[string]float64{
+		"flow":      counterValue(t, flowInvocations.WithLabelValues("metrics-page", "200")),
+		"invalid":   counterValue(t, flowInvocations.WithLabelValues("metrics-page", "400")),
+		"unknown":   counterValue(t, flowInvocations.WithLabelValues("", "404")),
+		"succeeded": counterValue(t, nodeInvocations.WithLabelValues("profile", "metrics-user", NodeSucceeded)),
+		"skipped":   counterValue(t, nodeInvocations.WithLabelValues("promo", "metrics-user", NodeSkipped)),
+		"miss":      counterValue(t, flowCacheRequests.WithLabelValues("other", "miss")),
+		"hit":       counterValue(t, flowCacheRequests.WithLabelValues("other", "hit")),
+		"error":     counterValue(t, flowCacheRequests.WithLabelValues("other", cacheError)),
+	}
+
+	want := map[string]float64{"flow": 3, "invalid": 1, "unknown": 1, "succeeded": 3, "skipped": 3, "miss": 1, "hit": 1, "error": 1}
+	for name, delta := range want {
+		if got := after[name] - before[name]; got != delta {
+			t.Errorf("want %s to increase by %v, got: %v", name, delta, got)
+		}
+	}
+}
+
+func Test_cacheBackend(t *testing.T) {
+	if got := cacheBackend(newMapCache()); got != "other" {
+		t.Fatalf("want other for an unknown client, got: %s", got)
+	}
+}
+
+func Test_observeNode_SkippedHasNoDuration(t *testing.T) {
+	observeNode("metrics-skip", "fn", NodeSkipped, time.Second)
+
+	var metric dto.Metric
+	if err := nodeDuration.WithLabelValues("metrics-skip", "fn").(prometheus.Histogram).Write(&metric); err != nil {
+		t.Fatal(err)
+	}
+	if metric.GetHistogram().GetSampleCount() != 0 {
+		t.Fatalf("want no duration for a skipped node")
+	}
+}
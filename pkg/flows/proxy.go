@@ -0,0 +1,165 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package flows
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	fhttputil "github.com/danenherdi/faas-provider/httputil"
+	"github.com/danenherdi/faas-provider/proxy"
+	"github.com/gorilla/mux"
+	"github.com/openfaas/faas-netes/pkg/tracing"
+)
+
+const (
+	watchdogPort           = "8080"
+	defaultContentType     = "text/plain"
+	openFaaSInternalHeader = "X-OpenFaaS-Internal"
+)
+
+// proxyRequest resolves functionName and copies its response to w. When
+// returnBody is set, the response body is also returned so that it can be
+// cached. The call is recorded as a span, which the function receives as
+// its traceparent.
+func proxyRequest(w http.ResponseWriter, originalReq *http.Request, functionName string, proxyClient *http.Client, resolver proxy.BaseURLResolver, verbose bool, returnBody bool) *bytes.Reader {
+	ctx, span := tracing.Start(originalReq.Context(), "call "+functionName, tracing.KindClient)
+	defer span.End()
+	span.SetAttribute("faas.function", functionName)
+
+	functionAddr, err := resolver.Resolve(functionName)
+	if err != nil {
+		w.Header().Add(openFaaSInternalHeader, "proxy")
+
+		log.Printf("resolver error: no endpoints for %s: %s\n", functionName, err.Error())
+		span.SetError(err)
+		fhttputil.Errorf(w, http.StatusServiceUnavailable, "No endpoints available for: %s.", functionName)
+		return nil
+	}
+
+	proxyReq, err := buildProxyRequest(originalReq, functionAddr, mux.Vars(originalReq)["params"])
+	if err != nil {
+		w.Header().Add(openFaaSInternalHeader, "proxy")
+
+		fhttputil.Errorf(w, http.StatusInternalServerError, "Failed to resolve service: %s.", functionName)
+		return nil
+	}
+
+	if proxyReq.Body != nil {
+		defer proxyReq.Body.Close()
+	}
+	tracing.Inject(ctx, proxyReq.Header)
+
+	if verbose {
+		start := time.Now()
+		defer func() {
+			seconds := time.Since(start)
+			log.Printf("%s took %f seconds\n", functionName, seconds.Seconds())
+		}()
+	}
+
+	response, err := proxyClient.Do(proxyReq.WithContext(ctx))
+	if err != nil {
+		log.Printf("error with proxy request to: %s, %s\n", proxyReq.URL.String(), err.Error())
+		span.SetError(err)
+
+		w.Header().Add(openFaaSInternalHeader, "proxy")
+
+		fhttputil.Errorf(w, http.StatusInternalServerError, "Can't reach service for: %s.", functionName)
+		return nil
+	}
+
+	if response.Body != nil {
+		defer response.Body.Close()
+	}
+
+	// Copy the headers and status code from the response
+	copyHeaders(w.Header(), &response.Header)
+	w.Header().Set("Content-Type", getContentType(originalReq.Header, response.Header))
+	w.WriteHeader(response.StatusCode)
+
+	span.SetAttribute("http.status_code", response.StatusCode)
+	if response.StatusCode >= 500 {
+		span.SetError(fmt.Errorf("unexpected status code: %d", response.StatusCode))
+	}
+
+	var cacheReader *bytes.Reader
+	if response.Body != nil {
+		if !returnBody {
+			io.Copy(w, response.Body)
+		} else {
+			responseBody, _ := io.ReadAll(response.Body)
+			cacheReader = bytes.NewReader(responseBody)
+
+			w.Write(responseBody)
+		}
+	}
+
+	return cacheReader
+}
+
+// buildProxyRequest creates a request object for the proxy request, it will ensure that
+// the original request headers are preserved as well as setting openfaas system headers
+func buildProxyRequest(originalReq *http.Request, baseURL url.URL, extraPath string) (*http.Request, error) {
+	host := baseURL.Host
+	if baseURL.Port() == "" {
+		host = baseURL.Host + ":" + watchdogPort
+	}
+
+	url := url.URL{
+		Scheme:   baseURL.Scheme,
+		Host:     host,
+		Path:     extraPath,
+		RawQuery: originalReq.URL.RawQuery,
+	}
+
+	upstreamReq, err := http.NewRequest(originalReq.Method, url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	copyHeaders(upstreamReq.Header, &originalReq.Header)
+
+	if len(originalReq.Host) > 0 && upstreamReq.Header.Get("X-Forwarded-Host") == "" {
+		upstreamReq.Header["X-Forwarded-Host"] = []string{originalReq.Host}
+	}
+	if upstreamReq.Header.Get("X-Forwarded-For") == "" {
+		upstreamReq.Header["X-Forwarded-For"] = []string{originalReq.RemoteAddr}
+	}
+
+	if originalReq.Body != nil {
+		upstreamReq.Body = originalReq.Body
+	}
+
+	return upstreamReq, nil
+}
+
+// copyHeaders clones the header values from the source into the destination.
+func copyHeaders(destination http.Header, source *http.Header) {
+	for k, v := range *source {
+		vClone := make([]string, len(v))
+		copy(vClone, v)
+		destination[k] = vClone
+	}
+}
+
+// getContentType resolves the correct Content-Type for a proxied function.
+func getContentType(request http.Header, proxyResponse http.Header) (headerContentType string) {
+	responseHeader := proxyResponse.Get("Content-Type")
+	requestHeader := request.Get("Content-Type")
+
+	if len(responseHeader) > 0 {
+		headerContentType = responseHeader
+	} else if len(requestHeader) > 0 {
+		headerContentType = requestHeader
+	} else {
+		headerContentType = defaultContentType
+	}
+
+	return headerContentType
+}
@@ -0,0 +1,259 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package flows
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/danenherdi/faas-provider/proxy"
+	"github.com/danenherdi/faas-provider/types"
+	v1 "github.com/openfaas/faas-netes/pkg/apis/openfaas/v1"
+	"github.com/openfaas/faas-netes/pkg/cachetrace"
+	"github.com/openfaas/faas-netes/pkg/caching"
+	"github.com/openfaas/faas-netes/pkg/orchestrator"
+	"github.com/openfaas/faas-netes/pkg/tracing"
+)
+
+// runner invokes a flow and its children in-process. A child which is
+// itself a flow is run by the same runner, rather than by a request back
+// to the provider, so that every node of the tree is cached according to
+// its own Caching and CacheTTL.
+type runner struct {
+	lookup    Lookup
+	namespace string
+
+	// cacheClient is nil when caching is disabled, cacheBackend names it
+	// in metrics
+	cacheClient  types.CacheClient
+	cacheBackend string
+	orchestrator *orchestrator.Orchestrator
+	cacheTrace   *cachetrace.Recorder
+	flights      *flightGroup
+
+	// secrets authenticate calls to third-party APIs
+	secrets *secretStore
+
+	proxyClient *http.Client
+	resolver    proxy.BaseURLResolver
+	verbose     bool
+}
+
+// fetchFunc produces the response of a node when it is not served from the
+// cache, along with whether the response was received in full. Of those,
+// only responses with a status which is cacheable for the flow are cached.
+type fetchFunc func(ctx context.Context) (*bufferedResponse, bool)
+
+// cacheable returns true when responses of flow are cached
+func (rn *runner) cacheable(flow *v1.FlowSpec) bool {
+	return rn.cacheClient != nil && flow.Caching
+}
+
+// serve returns the response of the node at path from the cache, or calls
+// fetch and caches its response under key. Concurrent misses for the same
+// key share a single call to fetch, and a stale response is served while
+// fetch refreshes it in the background. A call to fetch is not cancelled
+// along with ctx, as its response may be shared with other callers, but it
+// is still limited by the deadline of ctx.
+func (rn *runner) serve(ctx context.Context, path string, flow *v1.FlowSpec, key string, fetch fetchFunc) *bufferedResponse {
+	cacheTTL := time.Duration(flow.CacheTTL) * time.Second
+	staleTTL := time.Duration(flow.StaleTTL) * time.Second
+
+	readAt := time.Now()
+	lookupCtx, span := tracing.Start(ctx, "cache "+path, tracing.KindInternal)
+	freshness := cacheExpired
+	entry, err := readCacheEntry(lookupCtx, rn.cacheClient, key)
+	if entry != nil {
+		freshness = entry.freshness(time.Now(), cacheTTL, staleTTL)
+	}
+	result := cacheResult(freshness)
+	if err != nil {
+		result = cacheError
+		span.SetError(err)
+	}
+	span.SetAttribute("flow.node", path)
+	span.SetAttribute("cache.result", result)
+	span.End()
+	flowCacheRequests.WithLabelValues(rn.cacheBackend, result).Inc()
+
+	if rn.orchestrator != nil {
+		rn.orchestrator.RecordAccess(key, freshness != cacheExpired)
+	}
+	cacheTraceFrom(ctx).record(path, freshness)
+
+	detached := context.WithoutCancel(ctx)
+	refresh := func() *bufferedResponse {
+		fetchCtx := detached
+		if deadline, ok := ctx.Deadline(); ok {
+			var cancel context.CancelFunc
+			fetchCtx, cancel = context.WithDeadline(detached, deadline)
+			defer cancel()
+		}
+
+		res, complete := fetch(fetchCtx)
+		if complete && cacheableStatus(flow, res.status) {
+			value, _ := encodeCacheEntry(newCacheEntry(res, time.Now()))
+			if err := rn.cacheClient.SetEx(detached, key, value, cacheTTL+staleTTL); err != nil {
+				log.Printf("error caching the response of %s: %s", path, err.Error())
+			}
+		}
+		return res
+	}
+
+	if freshness != cacheExpired {
+		rn.captureRead(readAt, path, key, true, len(entry.Body), cacheTTL+staleTTL)
+	}
+
+	switch freshness {
+	case cacheFresh:
+		if rn.verbose {
+			log.Printf("response of %s served from cache", path)
+		}
+		return entry.response(time.Now(), freshness)
+
+	case cacheStale:
+		if _, started := rn.flights.start(key, refresh); started && rn.verbose {
+			log.Printf("stale response of %s served from cache, refreshing", path)
+		}
+		return entry.response(time.Now(), freshness)
+	}
+
+	res, shared, err := rn.flights.do(ctx, key, refresh)
+	if err != nil {
+		rn.captureRead(readAt, path, key, false, 0, cacheTTL+staleTTL)
+		return &bufferedResponse{header: http.Header{}, err: err}
+	}
+	rn.captureRead(readAt, path, key, false, res.body.Len(), cacheTTL+staleTTL)
+	if shared && rn.verbose {
+		log.Printf("response of %s shared with a concurrent request", path)
+	}
+	return res
+}
+
+// captureRead adds a read of key to the cache trace, when one is being
+// captured. A miss is captured once the response has been fetched, so that
+// the trace has the size of the value which was stored.
+func (rn *runner) captureRead(at time.Time, path, key string, hit bool, size int, ttl time.Duration) {
+	if rn.cacheTrace == nil {
+		return
+	}
+
+	rn.cacheTrace.Record(cachetrace.Record{
+		Time: at,
+		Key:  cachetrace.HashKey(key),
+		Flow: path,
+		Hit:  hit,
+		Size: size,
+		TTL:  int(ttl / time.Second),
+	})
+}
+
+// runFlow invokes the children of the flow at path and then its function
+// with their responses and the args set from them, writing the function's
+// response to w. The cache
+// trace of the children is added to the response headers. children are the
+// results of children which have already run, for a flow whose cache key
+// includes their outputs, they are run here when nil.
+func (rn *runner) runFlow(w http.ResponseWriter, r *http.Request, path, name string, flow *v1.FlowSpec, args map[string]interface{}, children *childOutputs, returnBody bool) *bytes.Reader {
+	if children == nil {
+		var err error
+		if children, err = rn.runChildren(r.Context(), path, flow, args); err != nil {
+			writeChildError(w, name, err)
+			return nil
+		}
+	}
+
+	flowInput := FlowInput{
+		FlowInput: types.FlowInput{
+			Args:     children.args,
+			Children: children.outputs,
+		},
+		Skipped: children.skipped,
+	}
+
+	newRequestBody, _ := json.Marshal(flowInput)
+	r.Body = io.NopCloser(bytes.NewBuffer(newRequestBody))
+
+	setCacheTrace(w, r.Context())
+
+	return proxyRequest(w, r, name, rn.proxyClient, rn.resolver, rn.verbose, returnBody)
+}
+
+// invokeFlow runs a flow which is the child of another, the flow's
+// function receives a POST with the child's args just as it would if the
+// flow had been requested on its own
+func (rn *runner) invokeFlow(ctx context.Context, path, name string, flow *v1.FlowSpec, args map[string]interface{}) *bufferedResponse {
+	cacheable := rn.cacheable(flow)
+
+	var children *childOutputs
+	if cacheable && keyedChildren(flow) {
+		var err error
+		if children, err = rn.runChildren(ctx, path, flow, args); err != nil {
+			res := newBufferedResponse()
+			writeChildError(res, name, err)
+			return res
+		}
+	}
+
+	fetch := func(ctx context.Context) (*bufferedResponse, bool) {
+		res := newBufferedResponse()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/flow/"+name, nil)
+		if err != nil {
+			res.err = err
+			return res, false
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		rn.runFlow(res, req, path, name, flow, args, children, false)
+		return res, res.err == nil
+	}
+
+	if !cacheable {
+		res, _ := fetch(ctx)
+		return res
+	}
+
+	var outputs map[string]*types.FlowOutput
+	if children != nil {
+		outputs = children.outputs
+	}
+
+	key, err := flowCacheKey(rn.namespace, name, flow, nil, args, outputs)
+	if err != nil {
+		log.Printf("error creating cache key of flow %s: %s", path, err.Error())
+		res, _ := fetch(ctx)
+		return res
+	}
+
+	return rn.serve(ctx, path, flow, key, fetch)
+}
+
+// readCacheEntry returns the cached response for key, or nil when there is
+// none or it can not be decoded. An error is returned when the cache could
+// not be read, as opposed to the key not being found.
+func readCacheEntry(ctx context.Context, cacheClient types.CacheClient, key string) (*cacheEntry, error) {
+	data, err := cacheClient.Get(ctx, key)
+	if err != nil {
+		if caching.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	entry, err := decodeCacheEntry(data)
+	if err != nil {
+		log.Printf("error decoding cached response %s: %s", key, err.Error())
+		return nil, nil
+	}
+	return entry, nil
+}
@@ -0,0 +1,98 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package flows
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openfaas/faas-netes/pkg/k8s"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// secretTTL is how long a secret read for a third-party API is reused
+// before it is read again, so that a rotated secret is picked up without
+// reading it from the API server on every call
+const secretTTL = 30 * time.Second
+
+// secretStore reads the OpenFaaS secrets used to authenticate to
+// third-party APIs
+type secretStore struct {
+	client    k8s.SecretsClient
+	namespace string
+
+	lock   sync.Mutex
+	values map[string]cachedSecret
+
+	// now is replaced in tests
+	now func() time.Time
+}
+
+type cachedSecret struct {
+	value   string
+	expires time.Time
+}
+
+func newSecretStore(client k8s.SecretsClient, namespace string) *secretStore {
+	return &secretStore{
+		client:    client,
+		namespace: namespace,
+		values:    map[string]cachedSecret{},
+		now:       time.Now,
+	}
+}
+
+// get returns the value of the secret called name
+func (s *secretStore) get(name string) (string, error) {
+	if s == nil || s.client == nil {
+		return "", fmt.Errorf("secrets are not available to flows")
+	}
+
+	s.lock.Lock()
+	cached, ok := s.values[name]
+	s.lock.Unlock()
+
+	if ok && cached.expires.After(s.now()) {
+		return cached.value, nil
+	}
+
+	secrets, err := s.client.GetSecrets(s.namespace, []string{name})
+	if err != nil {
+		return "", fmt.Errorf("unable to read secret %s: %w", name, err)
+	}
+
+	value, err := secretValue(secrets[name], name)
+	if err != nil {
+		return "", err
+	}
+
+	s.lock.Lock()
+	s.values[name] = cachedSecret{value: value, expires: s.now().Add(secretTTL)}
+	s.lock.Unlock()
+
+	return value, nil
+}
+
+// secretValue returns the value of an OpenFaaS secret, which is held under
+// a key of the same name as the secret. A secret created by other means is
+// accepted when it holds a single value.
+func secretValue(secret *apiv1.Secret, name string) (string, error) {
+	if secret == nil {
+		return "", fmt.Errorf("secret %s not found", name)
+	}
+
+	value, ok := secret.Data[name]
+	if !ok && len(secret.Data) == 1 {
+		for _, v := range secret.Data {
+			value, ok = v, true
+		}
+	}
+	if !ok {
+		return "", fmt.Errorf("secret %s has no value named %s", name, name)
+	}
+
+	return strings.TrimSpace(string(value)), nil
+}
@@ -0,0 +1,151 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package flows
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The selectors used by FlowChild.OutputMap are a subset of JSONPath:
+//
+//	selector = "$" { "." ( name | "*" ) | "[" ( index | string | "*" ) "]" }
+//
+// For example: $.user.id, $.items[0].name, $['content-type'] or
+// $.items[*].id. A negative index counts from the end of an array. A
+// selector with a wildcard selects a list of every match, otherwise a
+// selector which does not resolve selects null.
+
+// selector is a parsed FlowChild.OutputMap selector
+type selector struct {
+	steps []selectorStep
+}
+
+type selectorStep struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// parseSelector parses a selector, which must start at the root "$"
+func parseSelector(src string) (*selector, error) {
+	if !strings.HasPrefix(src, "$") {
+		return nil, fmt.Errorf("selector %q must start with $", src)
+	}
+
+	s := &selector{}
+	for pos := 1; pos < len(src); {
+		switch src[pos] {
+		case '.':
+			pos++
+			end := pos
+			for end < len(src) && src[end] != '.' && src[end] != '[' {
+				end++
+			}
+			name := src[pos:end]
+			if len(name) == 0 {
+				return nil, fmt.Errorf("expected a name after '.' at position %d", pos)
+			}
+			if name == "*" {
+				s.steps = append(s.steps, selectorStep{wildcard: true})
+			} else {
+				s.steps = append(s.steps, selectorStep{key: name})
+			}
+			pos = end
+
+		case '[':
+			end := strings.IndexByte(src[pos:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed '[' at position %d", pos)
+			}
+			step, err := parseBracket(src[pos+1 : pos+end])
+			if err != nil {
+				return nil, fmt.Errorf("%s at position %d", err.Error(), pos)
+			}
+			s.steps = append(s.steps, step)
+			pos += end + 1
+
+		default:
+			return nil, fmt.Errorf("unexpected %q at position %d", src[pos], pos)
+		}
+	}
+
+	return s, nil
+}
+
+func parseBracket(text string) (selectorStep, error) {
+	text = strings.TrimSpace(text)
+
+	switch {
+	case text == "*":
+		return selectorStep{wildcard: true}, nil
+
+	case len(text) >= 2 && (text[0] == '\'' || text[0] == '"') && text[len(text)-1] == text[0]:
+		return selectorStep{key: text[1 : len(text)-1]}, nil
+	}
+
+	index, err := strconv.Atoi(text)
+	if err != nil {
+		return selectorStep{}, fmt.Errorf("expected an index, a quoted key or * but found %q", text)
+	}
+	return selectorStep{index: index, isIndex: true}, nil
+}
+
+// selectFrom returns the part of value chosen by the selector
+func (s *selector) selectFrom(value interface{}) interface{} {
+	values := []interface{}{value}
+	multiple := false
+
+	for _, step := range s.steps {
+		next := []interface{}{}
+		for _, current := range values {
+			next = append(next, step.apply(current)...)
+		}
+		values = next
+		multiple = multiple || step.wildcard
+	}
+
+	if multiple {
+		return values
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return values[0]
+}
+
+// apply returns the values which the step selects from value
+func (step selectorStep) apply(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if step.wildcard {
+			values := make([]interface{}, 0, len(v))
+			for _, key := range sortedKeys(v) {
+				values = append(values, v[key])
+			}
+			return values
+		}
+		if child, ok := v[step.key]; ok && !step.isIndex {
+			return []interface{}{child}
+		}
+
+	case []interface{}:
+		if step.wildcard {
+			return v
+		}
+		if step.isIndex {
+			i := step.index
+			if i < 0 {
+				i += len(v)
+			}
+			if i >= 0 && i < len(v) {
+				return []interface{}{v[i]}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package flows
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseSelector_Select(t *testing.T) {
+	output := outputValue([]byte(`{
+		"user": {"id": "u-1", "tags": ["a", "b", "c"]},
+		"items": [{"sku": "x", "qty": 1}, {"sku": "y", "qty": 2}],
+		"content-type": "json"
+	}`))
+
+	cases := []struct {
+		selector string
+		want     interface{}
+	}{
+		{"$", output},
+		{"$.user.id", "u-1"},
+		{"$.user.tags[1]", "b"},
+		{"$.user.tags[-1]", "c"},
+		{"$.user.tags[3]", nil},
+		{"$.items[0].qty", float64(1)},
+		{"$.items[*].sku", []interface{}{"x", "y"}},
+		{"$.user.*", []interface{}{"u-1", []interface{}{"a", "b", "c"}}},
+		{"$['content-type']", "json"},
+		{`$["user"]["id"]`, "u-1"},
+		{"$.missing.id", nil},
+		{"$.missing[*]", []interface{}{}},
+		{"$.user[0]", nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.selector, func(t *testing.T) {
+			sel, err := parseSelector(tc.selector)
+			if err != nil {
+				t.Fatalf("want no error, got: %s", err)
+			}
+			if got := sel.selectFrom(output); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("want %#v, got: %#v", tc.want, got)
+			}
+		})
+	}
+}
+
+func Test_parseSelector_Errors(t *testing.T) {
+	for _, src := range []string{"user.id", "$.", "$..id", "$[0", "$[x]", "$user"} {
+		t.Run(src, func(t *testing.T) {
+			if _, err := parseSelector(src); err == nil {
+				t.Fatalf("want an error for %q", src)
+			}
+		})
+	}
+}
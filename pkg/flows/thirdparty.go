@@ -0,0 +1,205 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package flows
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+
+	v1 "github.com/openfaas/faas-netes/pkg/apis/openfaas/v1"
+	"github.com/openfaas/faas-netes/pkg/tracing"
+)
+
+// templateFuncs are available to the templates of a third-party request
+var templateFuncs = template.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		data, err := json.Marshal(value)
+		return string(data), err
+	},
+}
+
+// parseTemplate parses one of the templates of a third-party request
+func parseTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+}
+
+func renderTemplate(name, text string, data interface{}) (string, error) {
+	tmpl, err := parseTemplate(name, text)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("unable to render %s: %w", name, err)
+	}
+	return sb.String(), nil
+}
+
+// thirdPartyRequest is a request to a third-party API, rendered from the
+// flow's spec for one set of args
+type thirdPartyRequest struct {
+	method           string
+	url              string
+	header           http.Header
+	body             []byte
+	responseTemplate string
+}
+
+// newThirdPartyRequest renders the request to a third-party flow's API,
+// a flow without a ThirdParty spec receives a POST of the args as JSON
+func (rn *runner) newThirdPartyRequest(flow *v1.FlowSpec, args map[string]interface{}) (*thirdPartyRequest, error) {
+	spec := flow.ThirdParty
+	if spec == nil {
+		spec = &v1.ThirdPartyRequest{}
+	}
+
+	req := &thirdPartyRequest{
+		method:           http.MethodPost,
+		header:           http.Header{},
+		responseTemplate: spec.ResponseTemplate,
+	}
+	if len(spec.Method) > 0 {
+		req.method = strings.ToUpper(spec.Method)
+	}
+
+	data := map[string]interface{}{argsRoot: args}
+
+	u, err := url.Parse(*flow.ThirdPartyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid thirdPartyURL: %w", err)
+	}
+	if len(spec.Query) > 0 {
+		query := u.Query()
+		for _, key := range sortedKeys(spec.Query) {
+			value, err := renderTemplate("query "+key, spec.Query[key], data)
+			if err != nil {
+				return nil, err
+			}
+			query.Set(key, value)
+		}
+		u.RawQuery = query.Encode()
+	}
+	req.url = u.String()
+
+	for _, name := range sortedKeys(spec.Headers) {
+		value, err := renderTemplate("header "+name, spec.Headers[name], data)
+		if err != nil {
+			return nil, err
+		}
+		req.header.Set(name, value)
+	}
+
+	if req.method != http.MethodGet {
+		if len(spec.RequestTemplate) > 0 {
+			body, err := renderTemplate("requestTemplate", spec.RequestTemplate, data)
+			if err != nil {
+				return nil, err
+			}
+			req.body = []byte(body)
+		} else if req.body, err = json.Marshal(args); err != nil {
+			return nil, fmt.Errorf("unable to marshal args: %w", err)
+		}
+
+		if len(req.header.Get("Content-Type")) == 0 {
+			req.header.Set("Content-Type", "application/json")
+		}
+	}
+
+	if auth := spec.Auth; auth != nil {
+		credential, err := rn.secrets.get(auth.Secret)
+		if err != nil {
+			return nil, err
+		}
+
+		switch auth.Type {
+		case v1.ThirdPartyAuthBearer:
+			req.header.Set("Authorization", "Bearer "+credential)
+		case v1.ThirdPartyAuthBasic:
+			if !strings.Contains(credential, ":") {
+				return nil, fmt.Errorf("secret %s must be in the form username:password for basic auth", auth.Secret)
+			}
+			req.header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credential)))
+		default:
+			return nil, fmt.Errorf("unknown auth type %q", auth.Type)
+		}
+	}
+
+	return req, nil
+}
+
+// do sends the request, a 2xx response is passed through the flow's
+// ResponseTemplate when it has one. The call is limited by the deadline of
+// ctx, or by defaultThirdPartyTimeout when ctx has none.
+func (t *thirdPartyRequest) do(ctx context.Context) *bufferedResponse {
+	res := newBufferedResponse()
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultThirdPartyTimeout)
+		defer cancel()
+	}
+
+	var body io.Reader
+	if t.body != nil {
+		body = bytes.NewReader(t.body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, t.method, t.url, body)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	req.Header = t.header.Clone()
+
+	// the URL is not recorded, as its query may hold rendered secrets
+	ctx, span := tracing.Start(ctx, "call "+req.URL.Host, tracing.KindClient)
+	defer func() {
+		span.SetError(res.err)
+		span.End()
+	}()
+	span.SetAttribute("http.method", t.method)
+	span.SetAttribute("http.host", req.URL.Host)
+	tracing.Inject(ctx, req.Header)
+
+	resp, err := sharedHTTPClient.Do(req)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	defer resp.Body.Close()
+	span.SetAttribute("http.status_code", resp.StatusCode)
+
+	data, err := io.ReadAll(resp.Body)
+	copyHeaders(res.header, &resp.Header)
+	res.WriteHeader(resp.StatusCode)
+	if err != nil {
+		res.err = fmt.Errorf("unable to read response: %w", err)
+		return res
+	}
+
+	if len(t.responseTemplate) > 0 && res.ok() {
+		output, err := renderTemplate("responseTemplate", t.responseTemplate, map[string]interface{}{
+			"body":   outputValue(data),
+			"status": resp.StatusCode,
+			"header": resp.Header,
+		})
+		if err != nil {
+			res.err = err
+			return res
+		}
+		data = []byte(output)
+	}
+
+	res.Write(data)
+	return res
+}
@@ -0,0 +1,242 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package flows
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1 "github.com/openfaas/faas-netes/pkg/apis/openfaas/v1"
+	"github.com/openfaas/faas-netes/pkg/k8s"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// testSecrets serves secrets from a map and counts the reads
+type testSecrets struct {
+	k8s.SecretsClient
+	data  map[string]string
+	reads int32
+}
+
+func (s *testSecrets) GetSecrets(namespace string, names []string) (map[string]*apiv1.Secret, error) {
+	atomic.AddInt32(&s.reads, 1)
+
+	secrets := map[string]*apiv1.Secret{}
+	for _, name := range names {
+		if value, ok := s.data[name]; ok {
+			secrets[name] = &apiv1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+				Data:       map[string][]byte{name: []byte(value)},
+			}
+		}
+	}
+	return secrets, nil
+}
+
+// capturedRequest is the request received by a third-party test server
+type capturedRequest struct {
+	method string
+	query  string
+	header http.Header
+	body   string
+}
+
+func newCaptureServer(response string) (*httptest.Server, *capturedRequest) {
+	captured := &capturedRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		*captured = capturedRequest{method: r.Method, query: r.URL.RawQuery, header: r.Header.Clone(), body: string(body)}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(response))
+	}))
+	return server, captured
+}
+
+func thirdPartyRunner(t *testing.T, flow v1.FlowSpec, secrets map[string]string) *runner {
+	lookup, _ := newTestLookup(t, "openfaas-fn", map[string]v1.FlowSpec{"api": flow})
+	rn := testRunner(lookup)
+	rn.secrets = newSecretStore(&testSecrets{data: secrets}, "openfaas-fn")
+	return rn
+}
+
+func Test_thirdPartyRequest_GetWithQueryHeadersAndBearer(t *testing.T) {
+	server, captured := newCaptureServer(`{"rate":1.1}`)
+	defer server.Close()
+
+	url := server.URL + "/rates?format=json"
+	flow := v1.FlowSpec{
+		Args:          []string{"currency"},
+		IsThirdParty:  true,
+		ThirdPartyURL: &url,
+		ThirdParty: &v1.ThirdPartyRequest{
+			Method:  "GET",
+			Query:   map[string]string{"symbol": "{{ .args.currency }}"},
+			Headers: map[string]string{"X-Client": "flows/{{ .args.currency }}"},
+			Auth:    &v1.ThirdPartyAuth{Type: v1.ThirdPartyAuthBearer, Secret: "rates-token"},
+		},
+	}
+
+	rn := thirdPartyRunner(t, flow, map[string]string{"rates-token": "s3cr3t\n"})
+	call, err := rn.thirdPartyCall("test/api", "api", &flow, map[string]interface{}{"currency": "EUR"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	res := call(context.Background())
+	if res.err != nil || res.status != http.StatusOK {
+		t.Fatalf("want 200, got %d: %v", res.status, res.err)
+	}
+	if res.body.String() != `{"rate":1.1}` {
+		t.Fatalf("want the response of the API, got %q", res.body.String())
+	}
+
+	if captured.method != http.MethodGet {
+		t.Fatalf("want GET, got %s", captured.method)
+	}
+	if captured.query != "format=json&symbol=EUR" {
+		t.Fatalf("want query merged with the URL's, got %q", captured.query)
+	}
+	if got := captured.header.Get("X-Client"); got != "flows/EUR" {
+		t.Fatalf("want X-Client rendered, got %q", got)
+	}
+	if got := captured.header.Get("Authorization"); got != "Bearer s3cr3t" {
+		t.Fatalf("want bearer token from the secret, got %q", got)
+	}
+	if len(captured.body) > 0 {
+		t.Fatalf("want no body for a GET, got %q", captured.body)
+	}
+}
+
+func Test_thirdPartyRequest_TemplatesAndBasicAuth(t *testing.T) {
+	server, captured := newCaptureServer(`{"data":{"id":7,"name":"alex"},"meta":{}}`)
+	defer server.Close()
+
+	url := server.URL
+	flow := v1.FlowSpec{
+		Args:          []string{"user", "tags"},
+		IsThirdParty:  true,
+		ThirdPartyURL: &url,
+		ThirdParty: &v1.ThirdPartyRequest{
+			Method:           "put",
+			RequestTemplate:  `{"username":{{ json .args.user }},"labels":{{ json .args.tags }}}`,
+			ResponseTemplate: `{"id":{{ .body.data.id }},"status":{{ .status }}}`,
+			Auth:             &v1.ThirdPartyAuth{Type: v1.ThirdPartyAuthBasic, Secret: "users-api"},
+		},
+	}
+
+	rn := thirdPartyRunner(t, flow, map[string]string{"users-api": "flows:pa:ss"})
+	call, err := rn.thirdPartyCall("test/api", "api", &flow, map[string]interface{}{"user": "alex", "tags": []string{"a", "b"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	res := call(context.Background())
+	if res.err != nil {
+		t.Fatalf("unexpected error: %s", res.err)
+	}
+	if res.body.String() != `{"id":7,"status":200}` {
+		t.Fatalf("want the rendered response, got %q", res.body.String())
+	}
+
+	if captured.method != http.MethodPut {
+		t.Fatalf("want PUT, got %s", captured.method)
+	}
+	if captured.body != `{"username":"alex","labels":["a","b"]}` {
+		t.Fatalf("want the rendered request, got %q", captured.body)
+	}
+	if got := captured.header.Get("Content-Type"); got != "application/json" {
+		t.Fatalf("want a JSON Content-Type, got %q", got)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", captured.header.Get("Authorization"))
+	if username, password, ok := req.BasicAuth(); !ok || username != "flows" || password != "pa:ss" {
+		t.Fatalf("want basic auth flows:pa:ss, got %q %q %v", username, password, ok)
+	}
+}
+
+func Test_thirdPartyCall_FailsWithoutRetryOnMissingSecret(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer server.Close()
+
+	url := server.URL
+	flow := v1.FlowSpec{
+		IsThirdParty:  true,
+		ThirdPartyURL: &url,
+		ThirdParty: &v1.ThirdPartyRequest{
+			Auth: &v1.ThirdPartyAuth{Type: v1.ThirdPartyAuthBearer, Secret: "missing"},
+		},
+	}
+
+	rn := thirdPartyRunner(t, flow, nil)
+	children := map[string]v1.FlowChild{"api": {Function: "api", Retries: 3}}
+
+	_, err := rn.runChildren(context.Background(), "test", &v1.FlowSpec{Children: children}, nil)
+	if err == nil || !strings.Contains(err.Error(), "secret missing not found") {
+		t.Fatalf("want missing secret error, got %v", err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 0 {
+		t.Fatalf("want no calls to the API, got %d", got)
+	}
+}
+
+func Test_thirdPartyCall_CachedChildKeepsItsTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { defaultThirdPartyTimeout = timeout }(defaultThirdPartyTimeout)
+	defaultThirdPartyTimeout = 20 * time.Millisecond
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	url := server.URL
+	flow := v1.FlowSpec{IsThirdParty: true, ThirdPartyURL: &url, Caching: true, CacheTTL: 60}
+
+	rn := thirdPartyRunner(t, flow, nil)
+	rn.cacheClient = newMapCache()
+	children := map[string]v1.FlowChild{"api": {Function: "api", Timeout: &metav1.Duration{Duration: 45 * time.Second}}}
+
+	result, err := rn.runChildren(context.Background(), "test", &v1.FlowSpec{Children: children}, nil)
+	if err != nil {
+		t.Fatalf("want the child's timeout to apply to the cached call, got: %s", err)
+	}
+	if got := string(result.outputs["api"].Data); got != "ok" {
+		t.Fatalf("want output ok, got: %q", got)
+	}
+}
+
+func Test_secretStore_ReusesSecretsWithinTTL(t *testing.T) {
+	client := &testSecrets{data: map[string]string{"token": "abc"}}
+	store := newSecretStore(client, "openfaas-fn")
+
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if value, err := store.get("token"); err != nil || value != "abc" {
+			t.Fatalf("want abc, got %q: %v", value, err)
+		}
+	}
+	if client.reads != 1 {
+		t.Fatalf("want one read within the TTL, got %d", client.reads)
+	}
+
+	now = now.Add(secretTTL)
+	store.get("token")
+	if client.reads != 2 {
+		t.Fatalf("want the secret read again after the TTL, got %d reads", client.reads)
+	}
+}
@@ -0,0 +1,98 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package flows
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/danenherdi/faas-provider/types"
+	"github.com/gorilla/mux"
+	v1 "github.com/openfaas/faas-netes/pkg/apis/openfaas/v1"
+	"github.com/openfaas/faas-netes/pkg/tracing"
+)
+
+func Test_NewHandler_RecordsSpans(t *testing.T) {
+	exporter := tracing.NewMemoryExporter()
+	tracing.SetExporter(exporter)
+	defer tracing.SetExporter(nil)
+
+	server, _, _ := newEchoServer(0)
+	defer server.Close()
+
+	var traceparent string
+	function := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("Traceparent")
+		w.Write([]byte("done"))
+	}))
+	defer function.Close()
+	functionURL, _ := url.Parse(function.URL)
+
+	echoURL := server.URL
+	lookup, _ := newTestLookup(t, "openfaas-fn", map[string]v1.FlowSpec{
+		"checkout": {
+			Args: []string{"id"},
+			Children: map[string]v1.FlowChild{
+				"stock": {Function: "echo", ArgsMap: map[string]string{"id": "id"}},
+				"promo": {Function: "echo", When: "args.id == \"vip\""},
+			},
+		},
+		"echo": {Args: []string{"id"}, IsThirdParty: true, ThirdPartyURL: &echoURL, Caching: true, CacheTTL: 60},
+	})
+	handler := NewHandler(types.FaaSConfig{EnableCaching: true}, "openfaas-fn", newMapCache(), testResolver{url: functionURL}, lookup, nil, nil, nil, nil, false)
+
+	req := httptest.NewRequest(http.MethodPost, "/flow/checkout", strings.NewReader(`{"id":"sku-1"}`))
+	req.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req = mux.SetURLVars(req, map[string]string{"name": "checkout"})
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("want status %d, got: %d, body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	spans := map[string]tracing.SpanData{}
+	for _, span := range exporter.Spans() {
+		if span.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("want span %s to continue the caller's trace, got %s", span.Name, span.TraceID)
+		}
+		spans[span.Name] = span
+	}
+
+	flow, ok := spans["flow checkout"]
+	if !ok || flow.ParentID.String() != "00f067aa0ba902b7" || flow.Kind != tracing.KindServer {
+		t.Fatalf("want a span of the flow under the caller's span, got: %v", spans)
+	}
+
+	stock := spans["node checkout/stock"]
+	if stock.ParentID != flow.SpanID || stock.Attributes["http.status_code"] != http.StatusOK || stock.Attributes["flow.attempts"] != 1 {
+		t.Errorf("want a span of the stock node under the flow, got: %+v", stock)
+	}
+	promo := spans["node checkout/promo"]
+	if promo.ParentID != flow.SpanID || promo.Attributes["flow.node.state"] != NodeSkipped {
+		t.Errorf("want a span of the skipped promo node, got: %+v", promo)
+	}
+
+	lookupSpan := spans["cache checkout/stock"]
+	if lookupSpan.ParentID != stock.SpanID || lookupSpan.Attributes["cache.result"] != "miss" {
+		t.Errorf("want a span of the cache lookup under the node, got: %+v", lookupSpan)
+	}
+
+	echoHost, _ := url.Parse(server.URL)
+	upstream := spans["call "+echoHost.Host]
+	if upstream.ParentID != stock.SpanID || upstream.Kind != tracing.KindClient {
+		t.Errorf("want a span of the third-party call under the node, got: %+v", upstream)
+	}
+
+	call, ok := spans["call checkout"]
+	if !ok || call.ParentID != flow.SpanID || call.Attributes["http.status_code"] != http.StatusOK {
+		t.Fatalf("want a span of the function call under the flow, got: %+v", call)
+	}
+	if want := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + call.SpanID.String() + "-01"; traceparent != want {
+		t.Errorf("want the function to receive traceparent %s, got %q", want, traceparent)
+	}
+}
@@ -0,0 +1,541 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package flows
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	v1 "github.com/openfaas/faas-netes/pkg/apis/openfaas/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Problem describes an error found in a flow definition
+type Problem struct {
+	// Flow is the name of the flow with the problem
+	Flow string `json:"flow"`
+	// Child is the alias of the child with the problem, if any
+	Child string `json:"child,omitempty"`
+	// Message describes the problem
+	Message string `json:"message"`
+	// Cycle lists the flows which form a cycle, if the problem is a cycle
+	Cycle []string `json:"cycle,omitempty"`
+}
+
+func (p Problem) String() string {
+	if len(p.Child) > 0 {
+		return fmt.Sprintf("%s: child %s: %s", p.Flow, p.Child, p.Message)
+	}
+	return fmt.Sprintf("%s: %s", p.Flow, p.Message)
+}
+
+// involves returns true when the problem is about the named flow, either
+// directly or as part of a cycle
+func (p Problem) involves(name string) bool {
+	if p.Flow == name {
+		return true
+	}
+	for _, flow := range p.Cycle {
+		if flow == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks a set of flow definitions keyed by name. It reports
+// children which reference unknown flows, args maps which use args that
+// are neither declared nor set by a sibling's output map, third-party
+// flows without a valid URL or request and cycles between flows. Problems
+// are sorted by flow and child.
+func Validate(specs map[string]v1.FlowSpec) []Problem {
+	problems := []Problem{}
+
+	for _, name := range sortedKeys(specs) {
+		problems = append(problems, validateFlow(name, specs)...)
+	}
+
+	problems = append(problems, findCycles(specs)...)
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].Flow != problems[j].Flow {
+			return problems[i].Flow < problems[j].Flow
+		}
+		return problems[i].Child < problems[j].Child
+	})
+
+	return problems
+}
+
+// ValidateReachable validates the named flow and every flow reachable from
+// it through its children
+func ValidateReachable(name string, lookup Lookup) ([]Problem, error) {
+	specs := map[string]v1.FlowSpec{}
+
+	pending := []string{name}
+	for len(pending) > 0 {
+		next := pending[0]
+		pending = pending[1:]
+
+		if _, ok := specs[next]; ok {
+			continue
+		}
+
+		spec, err := lookup.Get(next)
+		if err != nil {
+			if IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		specs[next] = *spec
+
+		for _, child := range spec.Children {
+			pending = append(pending, child.Function)
+		}
+	}
+
+	if _, ok := specs[name]; !ok {
+		return nil, &ErrFlowNotFound{Name: name}
+	}
+
+	return Validate(specs), nil
+}
+
+// RegisterEventHandlers validates every flow whenever a Flow resource is
+// added, updated or deleted and logs any problems that were not already
+// reported, so that broken definitions show up when they are applied
+// rather than on the first invocation.
+func RegisterEventHandlers(informer cache.SharedIndexInformer, lookup Lookup) {
+	reporter := &problemReporter{reported: map[string]bool{}}
+
+	check := func(interface{}) {
+		reporter.report(lookup)
+	}
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    check,
+		UpdateFunc: func(_, newObj interface{}) { check(newObj) },
+		DeleteFunc: check,
+	})
+}
+
+// problemReporter logs each distinct problem once for as long as it persists
+type problemReporter struct {
+	lock     sync.Mutex
+	reported map[string]bool
+}
+
+func (p *problemReporter) report(lookup Lookup) {
+	specs, err := lookup.List()
+	if err != nil {
+		log.Printf("error listing flows for validation: %s", err.Error())
+		return
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	current := map[string]bool{}
+	for _, problem := range Validate(specs) {
+		msg := problem.String()
+		current[msg] = true
+		if !p.reported[msg] {
+			log.Printf("invalid flow %s", msg)
+		}
+	}
+	p.reported = current
+}
+
+// joinProblems formats problems as a single line for error messages
+func joinProblems(problems []Problem) string {
+	msgs := make([]string, 0, len(problems))
+	for _, problem := range problems {
+		msgs = append(msgs, problem.String())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func validateFlow(name string, specs map[string]v1.FlowSpec) []Problem {
+	problems := []Problem{}
+	flow := specs[name]
+
+	if flow.IsThirdParty {
+		if flow.ThirdPartyURL == nil || len(*flow.ThirdPartyURL) == 0 {
+			problems = append(problems, Problem{Flow: name, Message: "thirdPartyURL is required for a third-party flow"})
+		} else if u, err := url.Parse(*flow.ThirdPartyURL); err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 {
+			problems = append(problems, Problem{Flow: name, Message: fmt.Sprintf("thirdPartyURL %q is not a valid URL", *flow.ThirdPartyURL)})
+		}
+	}
+
+	if flow.ThirdParty != nil {
+		problems = append(problems, validateThirdParty(name, flow)...)
+	}
+
+	declared := toSet(flow.Args)
+
+	if flow.Caching && flow.CacheTTL <= 0 {
+		problems = append(problems, Problem{Flow: name, Message: "caching requires a cacheTTL above 0, a cached response is never fresh without one"})
+	}
+
+	if flow.CacheKey != nil {
+		for _, arg := range flow.CacheKey.Args {
+			if _, ok := declared[arg]; !ok {
+				problems = append(problems, Problem{Flow: name, Message: fmt.Sprintf("cacheKey uses %q which is not an arg of %s", arg, name)})
+			}
+		}
+		for _, part := range flow.CacheKey.PathParts {
+			if part < 0 {
+				problems = append(problems, Problem{Flow: name, Message: fmt.Sprintf("cacheKey pathParts contains %d which is not a segment index", part)})
+			}
+		}
+		for _, alias := range flow.CacheKey.Children {
+			if _, ok := flow.Children[alias]; !ok {
+				problems = append(problems, Problem{Flow: name, Message: fmt.Sprintf("cacheKey uses the output of %q which is not a child of %s", alias, name)})
+			}
+		}
+	}
+
+	for _, status := range flow.CacheStatusCodes {
+		if status < 100 || status > 599 {
+			problems = append(problems, Problem{Flow: name, Message: fmt.Sprintf("cacheStatusCodes contains %d which is not an HTTP status code", status)})
+		}
+	}
+
+	// outputs maps each arg set by an OutputMap to the first child which
+	// sets it, those args are available to siblings along with declared
+	outputs := map[string]string{}
+	for _, alias := range sortedKeys(flow.Children) {
+		for _, arg := range sortedKeys(flow.Children[alias].OutputMap) {
+			if _, ok := declared[arg]; ok {
+				problems = append(problems, Problem{Flow: name, Child: alias, Message: fmt.Sprintf("outputMap sets %q which is already an arg of %s", arg, name)})
+			} else if other, ok := outputs[arg]; ok {
+				problems = append(problems, Problem{Flow: name, Child: alias, Message: fmt.Sprintf("outputMap sets %q which is also set by %s", arg, other)})
+			} else {
+				outputs[arg] = alias
+			}
+
+			if _, err := parseSelector(flow.Children[alias].OutputMap[arg]); err != nil {
+				problems = append(problems, Problem{Flow: name, Child: alias, Message: fmt.Sprintf("invalid outputMap for %s: %s", arg, err.Error())})
+			}
+		}
+	}
+
+	for _, alias := range sortedKeys(flow.Children) {
+		child := flow.Children[alias]
+
+		if len(child.Function) == 0 {
+			problems = append(problems, Problem{Flow: name, Child: alias, Message: "function is required"})
+			continue
+		}
+
+		switch child.OnError {
+		case "", v1.ChildErrorFail, v1.ChildErrorSkip, v1.ChildErrorFallback:
+		default:
+			problems = append(problems, Problem{Flow: name, Child: alias, Message: fmt.Sprintf("onError %q must be one of fail, skip or fallback", child.OnError)})
+		}
+		if child.Retries < 0 || child.Retries > maxRetries {
+			problems = append(problems, Problem{Flow: name, Child: alias, Message: fmt.Sprintf("retries must be between 0 and %d", maxRetries)})
+		}
+
+		for _, dependency := range child.DependsOn {
+			if msg := checkSiblingUse(dependency, alias, name, flow); len(msg) > 0 {
+				problems = append(problems, Problem{Flow: name, Child: alias, Message: "dependsOn refers to " + msg})
+			}
+		}
+
+		if len(child.When) > 0 {
+			problems = append(problems, validateCondition(name, alias, flow, declared, outputs)...)
+		}
+
+		childFlow, known := specs[child.Function]
+		if !known {
+			problems = append(problems, Problem{Flow: name, Child: alias, Message: fmt.Sprintf("function %q is not a known flow", child.Function)})
+		}
+		childArgs := toSet(childFlow.Args)
+
+		for _, childArg := range sortedKeys(child.ArgsMap) {
+			parentArg := child.ArgsMap[childArg]
+
+			if isChildPath(parentArg) {
+				if path, err := parseChildPath(parentArg); err != nil {
+					problems = append(problems, Problem{Flow: name, Child: alias, Message: fmt.Sprintf("invalid argsMap for %s: %s", childArg, err.Error())})
+				} else if msg := checkSiblingUse(path.paths[0][1], alias, name, flow); len(msg) > 0 {
+					problems = append(problems, Problem{Flow: name, Child: alias, Message: "argsMap refers to " + msg})
+				}
+			} else if msg := checkArgUse(parentArg, alias, name, declared, outputs); len(msg) > 0 {
+				problems = append(problems, Problem{Flow: name, Child: alias, Message: "argsMap uses " + msg})
+			}
+			if _, ok := childArgs[childArg]; known && !ok {
+				problems = append(problems, Problem{Flow: name, Child: alias, Message: fmt.Sprintf("argsMap sets %q which is not an arg of %s", childArg, child.Function)})
+			}
+		}
+	}
+
+	problems = append(problems, findSiblingCycles(name, flow)...)
+
+	return problems
+}
+
+// validateThirdParty checks the request made to a third-party flow's API,
+// its templates must parse and credentials must come from a secret
+func validateThirdParty(name string, flow v1.FlowSpec) []Problem {
+	problems := []Problem{}
+	spec := flow.ThirdParty
+
+	if !flow.IsThirdParty {
+		problems = append(problems, Problem{Flow: name, Message: "thirdParty is only used by a third-party flow"})
+	}
+
+	method := strings.ToUpper(spec.Method)
+	switch method {
+	case "", http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		problems = append(problems, Problem{Flow: name, Message: fmt.Sprintf("thirdParty method %q must be one of GET, POST, PUT, PATCH or DELETE", spec.Method)})
+	}
+
+	templates := map[string]string{}
+	for _, key := range sortedKeys(spec.Query) {
+		templates["query "+key] = spec.Query[key]
+	}
+	for _, header := range sortedKeys(spec.Headers) {
+		if strings.EqualFold(header, "Authorization") {
+			problems = append(problems, Problem{Flow: name, Message: "thirdParty headers must not set Authorization, use auth with a secret"})
+		}
+		templates["header "+header] = spec.Headers[header]
+	}
+	if method == http.MethodGet && len(spec.Query) == 0 && len(flow.Args) > 0 {
+		problems = append(problems, Problem{Flow: name, Message: "thirdParty GET sends no args without query, map them to query keys"})
+	}
+	if len(spec.RequestTemplate) > 0 {
+		if method == http.MethodGet {
+			problems = append(problems, Problem{Flow: name, Message: "thirdParty requestTemplate is not sent with a GET"})
+		}
+		templates["requestTemplate"] = spec.RequestTemplate
+	}
+	if len(spec.ResponseTemplate) > 0 {
+		templates["responseTemplate"] = spec.ResponseTemplate
+	}
+
+	for _, template := range sortedKeys(templates) {
+		if _, err := parseTemplate(template, templates[template]); err != nil {
+			problems = append(problems, Problem{Flow: name, Message: fmt.Sprintf("thirdParty %s is not a valid template: %s", template, err.Error())})
+		}
+	}
+
+	if auth := spec.Auth; auth != nil {
+		switch auth.Type {
+		case v1.ThirdPartyAuthBearer, v1.ThirdPartyAuthBasic:
+		default:
+			problems = append(problems, Problem{Flow: name, Message: fmt.Sprintf("thirdParty auth type %q must be bearer or basic", auth.Type)})
+		}
+		if len(auth.Secret) == 0 {
+			problems = append(problems, Problem{Flow: name, Message: "thirdParty auth requires a secret"})
+		}
+	}
+
+	return problems
+}
+
+// checkArgUse describes why a child can not use arg of the flow called
+// name, or returns an empty string when it can. An arg is either declared
+// or set by the OutputMap of a sibling.
+func checkArgUse(arg, alias, name string, declared map[string]struct{}, outputs map[string]string) string {
+	if _, ok := declared[arg]; ok {
+		return ""
+	}
+
+	producer, ok := outputs[arg]
+	switch {
+	case !ok:
+		return fmt.Sprintf("%q which is not an arg of %s", arg, name)
+	case producer == alias:
+		return fmt.Sprintf("%q which is set by its own outputMap", arg)
+	}
+	return ""
+}
+
+// checkSiblingUse describes why a child can not depend on the sibling
+// called dependency, or returns an empty string when it can
+func checkSiblingUse(dependency, alias, name string, flow v1.FlowSpec) string {
+	if dependency == alias {
+		return "the child itself"
+	}
+	if _, ok := flow.Children[dependency]; !ok {
+		return fmt.Sprintf("%q which is not a child of %s", dependency, name)
+	}
+	return ""
+}
+
+// validateCondition checks that the When condition of a child parses and
+// only refers to available args and to other children of the same flow
+func validateCondition(name, alias string, flow v1.FlowSpec, declared map[string]struct{}, outputs map[string]string) []Problem {
+	problems := []Problem{}
+	child := flow.Children[alias]
+
+	condition, err := parseExpression(child.When)
+	if err != nil {
+		return append(problems, Problem{Flow: name, Child: alias, Message: fmt.Sprintf("invalid when condition: %s", err.Error())})
+	}
+
+	for _, path := range condition.paths {
+		if len(path) < 2 {
+			continue
+		}
+
+		switch path[0] {
+		case argsRoot:
+			if msg := checkArgUse(path[1], alias, name, declared, outputs); len(msg) > 0 {
+				problems = append(problems, Problem{Flow: name, Child: alias, Message: "when uses " + msg})
+			}
+		case childrenRoot:
+			if msg := checkSiblingUse(path[1], alias, name, flow); len(msg) > 0 {
+				problems = append(problems, Problem{Flow: name, Child: alias, Message: "when refers to " + msg})
+			}
+		}
+	}
+
+	return problems
+}
+
+// findSiblingCycles reports children of a flow which wait on each other,
+// through DependsOn, When conditions, ArgsMaps or OutputMaps, and so can
+// never be started
+func findSiblingCycles(name string, flow v1.FlowSpec) []Problem {
+	dependencies := childDependencies(&flow)
+
+	problems := []Problem{}
+	for _, cycle := range findGraphCycles(sortedKeys(flow.Children), dependencies) {
+		problems = append(problems, Problem{
+			Flow:    name,
+			Child:   cycle[0],
+			Message: fmt.Sprintf("children wait on each other: %s -> %s", strings.Join(cycle, " -> "), cycle[0]),
+		})
+	}
+	return problems
+}
+
+// findCycles reports every cycle in the graph of flows and their children,
+// each cycle is reported once against the first flow in the cycle by name
+func findCycles(specs map[string]v1.FlowSpec) []Problem {
+	edges := make(map[string][]string, len(specs))
+	for name, flow := range specs {
+		for _, alias := range sortedKeys(flow.Children) {
+			if next := flow.Children[alias].Function; len(next) > 0 {
+				edges[name] = append(edges[name], next)
+			}
+		}
+	}
+
+	problems := []Problem{}
+	for _, cycle := range findGraphCycles(sortedKeys(specs), edges) {
+		problems = append(problems, Problem{
+			Flow:    cycle[0],
+			Message: fmt.Sprintf("cycle detected: %s -> %s", strings.Join(cycle, " -> "), cycle[0]),
+			Cycle:   cycle,
+		})
+	}
+	return problems
+}
+
+// findGraphCycles returns every cycle between nodes, following edges to
+// nodes which are in the graph. Each cycle is returned once, starting from
+// its lowest node.
+func findGraphCycles(nodes []string, edges map[string][]string) [][]string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	inGraph := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		inGraph[node] = true
+	}
+
+	cycles := [][]string{}
+	state := make(map[string]int, len(nodes))
+	reported := map[string]bool{}
+	path := []string{}
+
+	var visit func(node string)
+	visit = func(node string) {
+		state[node] = visiting
+		path = append(path, node)
+
+		for _, next := range edges[node] {
+			if !inGraph[next] {
+				continue
+			}
+
+			switch state[next] {
+			case unvisited:
+				visit(next)
+			case visiting:
+				cycle := canonicalCycle(cycleFrom(path, next))
+				key := strings.Join(cycle, ",")
+				if !reported[key] {
+					reported[key] = true
+					cycles = append(cycles, cycle)
+				}
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[node] = visited
+	}
+
+	for _, node := range nodes {
+		if state[node] == unvisited {
+			visit(node)
+		}
+	}
+
+	return cycles
+}
+
+// cycleFrom returns the part of path which starts at name
+func cycleFrom(path []string, name string) []string {
+	for i := range path {
+		if path[i] == name {
+			cycle := make([]string, len(path)-i)
+			copy(cycle, path[i:])
+			return cycle
+		}
+	}
+	return nil
+}
+
+// canonicalCycle rotates a cycle so that it starts with its lowest name
+func canonicalCycle(cycle []string) []string {
+	lowest := 0
+	for i := range cycle {
+		if cycle[i] < cycle[lowest] {
+			lowest = i
+		}
+	}
+	return append(append([]string{}, cycle[lowest:]...), cycle[:lowest]...)
+}
+
+func toSet(values []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		set[v] = struct{}{}
+	}
+	return set
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,177 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package flows
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/danenherdi/faas-provider/types"
+	v1 "github.com/openfaas/faas-netes/pkg/apis/openfaas/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// maxValidateBodySize limits the size of the flow files accepted for validation
+const maxValidateBodySize = 4 * 1024 * 1024
+
+// ValidationResponse is returned by the validate endpoint
+type ValidationResponse struct {
+	// Valid is true when no problems were found
+	Valid bool `json:"valid"`
+	// Problems found in the submitted flows
+	Problems []Problem `json:"problems"`
+}
+
+// flowDocument holds any of the documents accepted by the validate
+// endpoint: a Flow, a FlowList or a legacy flows file keyed by name
+type flowDocument struct {
+	Kind     string                `json:"kind"`
+	Metadata metav1.ObjectMeta     `json:"metadata"`
+	Spec     v1.FlowSpec           `json:"spec"`
+	Items    []v1.Flow             `json:"items"`
+	Flows    map[string]types.Flow `json:"flows"`
+}
+
+// MakeValidateHandler lints flow definitions without deploying them. The
+// body may be JSON or YAML and contain one or more Flow or FlowList
+// documents, or a flows file in the legacy {"flows": {...}} format. The
+// submitted flows are checked together with the flows already deployed so
+// that children may refer to either, and only problems which involve a
+// submitted flow are reported. 200 is returned when the flows are valid,
+// 422 when problems were found and 413 when the body is larger than
+// maxValidateBodySize.
+func MakeValidateHandler(lookup Lookup) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil {
+			http.Error(w, "A request body is required", http.StatusBadRequest)
+			return
+		}
+		defer r.Body.Close()
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxValidateBodySize))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, fmt.Sprintf("Request body must not be larger than %d bytes", maxValidateBodySize), http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, fmt.Sprintf("Unable to read request body: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+
+		submitted, err := decodeFlows(bytes.NewReader(body))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Unable to parse flows: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+		if len(submitted) == 0 {
+			http.Error(w, "No flows found in request body", http.StatusBadRequest)
+			return
+		}
+
+		specs, err := lookup.List()
+		if err != nil {
+			log.Printf("error listing flows for validation: %s", err.Error())
+			http.Error(w, "Unable to list flows", http.StatusInternalServerError)
+			return
+		}
+		for name, spec := range submitted {
+			specs[name] = spec
+		}
+
+		res := ValidationResponse{Problems: []Problem{}}
+		for _, problem := range Validate(specs) {
+			for name := range submitted {
+				if problem.involves(name) {
+					res.Problems = append(res.Problems, problem)
+					break
+				}
+			}
+		}
+		res.Valid = len(res.Problems) == 0
+
+		jsonResp, err := json.Marshal(res)
+		if err != nil {
+			log.Printf("error marshalling validation response: %s", err.Error())
+			http.Error(w, "Unable to marshal validation response", http.StatusInternalServerError)
+			return
+		}
+
+		status := http.StatusOK
+		if !res.Valid {
+			status = http.StatusUnprocessableEntity
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(jsonResp)
+	}
+}
+
+// decodeFlows reads every document in body and returns the flows they
+// define keyed by name
+func decodeFlows(body io.Reader) (map[string]v1.FlowSpec, error) {
+	specs := map[string]v1.FlowSpec{}
+	decoder := utilyaml.NewYAMLOrJSONDecoder(body, 4096)
+
+	for {
+		var doc flowDocument
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				return specs, nil
+			}
+			return nil, err
+		}
+
+		switch doc.Kind {
+		case "Flow":
+			if len(doc.Metadata.Name) == 0 {
+				return nil, fmt.Errorf("flow has no metadata.name")
+			}
+			specs[doc.Metadata.Name] = doc.Spec
+		case "FlowList":
+			for _, item := range doc.Items {
+				if len(item.Name) == 0 {
+					return nil, fmt.Errorf("flow has no metadata.name")
+				}
+				specs[item.Name] = item.Spec
+			}
+		case "":
+			for name, flow := range doc.Flows {
+				specs[name] = fromLegacyFlow(flow)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported kind %q", doc.Kind)
+		}
+	}
+}
+
+// fromLegacyFlow converts a flow from the legacy flows file format
+func fromLegacyFlow(flow types.Flow) v1.FlowSpec {
+	spec := v1.FlowSpec{
+		Args:          flow.Args,
+		Caching:       flow.Caching,
+		CacheTTL:      int32(flow.CacheTTL),
+		IsThirdParty:  flow.IsThirdParty,
+		ThirdPartyURL: flow.ThirdPartyURL,
+	}
+
+	if len(flow.Children) > 0 {
+		spec.Children = make(map[string]v1.FlowChild, len(flow.Children))
+		for alias, child := range flow.Children {
+			spec.Children[alias] = v1.FlowChild{
+				Function: child.Function,
+				ArgsMap:  child.ArgsMap,
+			}
+		}
+	}
+
+	return spec
+}
@@ -0,0 +1,389 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package flows
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/danenherdi/faas-provider/types"
+	v1 "github.com/openfaas/faas-netes/pkg/apis/openfaas/v1"
+)
+
+func Test_Validate_ValidFlows(t *testing.T) {
+	rates := "https://rates.example.com"
+	specs := map[string]v1.FlowSpec{
+		"rates": {Args: []string{"currency"}, IsThirdParty: true, ThirdPartyURL: &rates},
+		"checkout": {
+			Args: []string{"user", "currency"},
+			Children: map[string]v1.FlowChild{
+				"rate": {Function: "rates", ArgsMap: map[string]string{"currency": "currency"}},
+			},
+		},
+	}
+
+	if problems := Validate(specs); len(problems) > 0 {
+		t.Fatalf("want no problems, got: %v", problems)
+	}
+}
+
+func Test_Validate_ReportsProblems(t *testing.T) {
+	specs := map[string]v1.FlowSpec{
+		"rates": {Args: []string{"currency"}, IsThirdParty: true},
+		"checkout": {
+			Args:             []string{"user"},
+			CacheKey:         &v1.FlowCacheKey{PathParts: []int32{0, -1}, Children: []string{"rate", "stock"}},
+			CacheStatusCodes: []int32{200, 1000},
+			Children: map[string]v1.FlowChild{
+				"missing": {Function: "stock"},
+				"rate":    {Function: "rates", ArgsMap: map[string]string{"currency": "currency", "region": "user"}},
+			},
+		},
+	}
+
+	got := []string{}
+	for _, problem := range Validate(specs) {
+		got = append(got, problem.String())
+	}
+
+	want := []string{
+		`checkout: cacheKey pathParts contains -1 which is not a segment index`,
+		`checkout: cacheKey uses the output of "stock" which is not a child of checkout`,
+		`checkout: cacheStatusCodes contains 1000 which is not an HTTP status code`,
+		`checkout: child missing: function "stock" is not a known flow`,
+		`checkout: child rate: argsMap uses "currency" which is not an arg of checkout`,
+		`checkout: child rate: argsMap sets "region" which is not an arg of rates`,
+		`rates: thirdPartyURL is required for a third-party flow`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("want problems:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func Test_Validate_CacheTTL(t *testing.T) {
+	specs := map[string]v1.FlowSpec{
+		"cached":   {Caching: true, CacheTTL: 60},
+		"negative": {Caching: true, CacheTTL: -1},
+		"uncached": {CacheTTL: 0},
+		"unset":    {Caching: true, StaleTTL: 60},
+	}
+
+	got := []string{}
+	for _, problem := range Validate(specs) {
+		got = append(got, problem.String())
+	}
+
+	want := []string{
+		`negative: caching requires a cacheTTL above 0, a cached response is never fresh without one`,
+		`unset: caching requires a cacheTTL above 0, a cached response is never fresh without one`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("want problems:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func Test_Validate_Retries(t *testing.T) {
+	specs := map[string]v1.FlowSpec{
+		"rates": {},
+		"checkout": {
+			Children: map[string]v1.FlowChild{
+				"negative": {Function: "rates", Retries: -1},
+				"bounded":  {Function: "rates", Retries: maxRetries},
+				"too many": {Function: "rates", Retries: maxRetries + 1},
+			},
+		},
+	}
+
+	got := []string{}
+	for _, problem := range Validate(specs) {
+		got = append(got, problem.String())
+	}
+
+	want := []string{
+		`checkout: child negative: retries must be between 0 and 10`,
+		`checkout: child too many: retries must be between 0 and 10`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("want problems:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func Test_Validate_OutputMap(t *testing.T) {
+	specs := map[string]v1.FlowSpec{
+		"echo": {Args: []string{"id"}},
+		"page": {
+			Args: []string{"user"},
+			Children: map[string]v1.FlowChild{
+				"a": {Function: "echo", ArgsMap: map[string]string{"id": "fromB"}, OutputMap: map[string]string{"fromA": "$.id"}},
+				"b": {Function: "echo", ArgsMap: map[string]string{"id": "fromA"}, OutputMap: map[string]string{"fromB": "$.id", "user": "$.user"}},
+				"c": {Function: "echo", When: "args.own != null", OutputMap: map[string]string{"fromA": "$.id", "own": "id"}},
+				"d": {Function: "echo", ArgsMap: map[string]string{"id": "fromA"}},
+			},
+		},
+	}
+
+	got := []string{}
+	for _, problem := range Validate(specs) {
+		got = append(got, problem.String())
+	}
+
+	want := []string{
+		`page: child a: children wait on each other: a -> b -> a`,
+		`page: child b: outputMap sets "user" which is already an arg of page`,
+		`page: child c: outputMap sets "fromA" which is also set by a`,
+		`page: child c: invalid outputMap for own: selector "id" must start with $`,
+		`page: child c: when uses "own" which is set by its own outputMap`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("want problems:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func Test_Validate_DependsOn(t *testing.T) {
+	specs := map[string]v1.FlowSpec{
+		"echo": {Args: []string{"id"}},
+		"page": {
+			Children: map[string]v1.FlowChild{
+				"a": {Function: "echo", DependsOn: []string{"c"}},
+				"b": {Function: "echo", DependsOn: []string{"b", "missing"}},
+				"c": {Function: "echo", ArgsMap: map[string]string{"id": "children.a.id"}},
+				"d": {Function: "echo", ArgsMap: map[string]string{"id": "children.a >"}},
+				"e": {Function: "echo", ArgsMap: map[string]string{"id": "children.other"}, DependsOn: []string{"d"}},
+			},
+		},
+	}
+
+	got := []string{}
+	for _, problem := range Validate(specs) {
+		got = append(got, problem.String())
+	}
+
+	want := []string{
+		`page: child a: children wait on each other: a -> c -> a`,
+		`page: child b: dependsOn refers to the child itself`,
+		`page: child b: dependsOn refers to "missing" which is not a child of page`,
+		`page: child d: invalid argsMap for id: unexpected "end of expression" at position 12`,
+		`page: child e: argsMap refers to "other" which is not a child of page`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("want problems:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func Test_Validate_ThirdParty(t *testing.T) {
+	api := "https://api.example.com"
+	specs := map[string]v1.FlowSpec{
+		"api": {
+			IsThirdParty:  true,
+			ThirdPartyURL: &api,
+			ThirdParty: &v1.ThirdPartyRequest{
+				Method:           "GET",
+				Headers:          map[string]string{"authorization": "Bearer token"},
+				Query:            map[string]string{"q": "{{ .args.q "},
+				RequestTemplate:  `{"q":{{ json .args.q }}}`,
+				ResponseTemplate: "{{ .body.id }}",
+				Auth:             &v1.ThirdPartyAuth{Type: "digest"},
+			},
+		},
+		"local": {
+			ThirdParty: &v1.ThirdPartyRequest{Method: "TRACE"},
+		},
+		"lookup": {
+			Args:          []string{"id"},
+			IsThirdParty:  true,
+			ThirdPartyURL: &api,
+			ThirdParty:    &v1.ThirdPartyRequest{Method: "GET"},
+		},
+		"status": {
+			IsThirdParty:  true,
+			ThirdPartyURL: &api,
+			ThirdParty:    &v1.ThirdPartyRequest{Method: "GET"},
+		},
+	}
+
+	got := []string{}
+	for _, problem := range Validate(specs) {
+		got = append(got, problem.String())
+	}
+
+	want := []string{
+		`api: thirdParty headers must not set Authorization, use auth with a secret`,
+		`api: thirdParty requestTemplate is not sent with a GET`,
+		`api: thirdParty query q is not a valid template: template: query q:1: unclosed action`,
+		`api: thirdParty auth type "digest" must be bearer or basic`,
+		`api: thirdParty auth requires a secret`,
+		`local: thirdParty is only used by a third-party flow`,
+		`local: thirdParty method "TRACE" must be one of GET, POST, PUT, PATCH or DELETE`,
+		`lookup: thirdParty GET sends no args without query, map them to query keys`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("want problems:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func Test_Validate_ReportsEachCycleOnce(t *testing.T) {
+	specs := map[string]v1.FlowSpec{
+		"a":    {Children: map[string]v1.FlowChild{"next": {Function: "b"}}},
+		"b":    {Children: map[string]v1.FlowChild{"next": {Function: "c"}}},
+		"c":    {Children: map[string]v1.FlowChild{"next": {Function: "a"}}},
+		"self": {Children: map[string]v1.FlowChild{"again": {Function: "self"}}},
+		"root": {Children: map[string]v1.FlowChild{"b": {Function: "b"}}},
+	}
+
+	problems := Validate(specs)
+	if len(problems) != 2 {
+		t.Fatalf("want 2 problems, got: %v", problems)
+	}
+
+	if problems[0].Message != "cycle detected: a -> b -> c -> a" {
+		t.Errorf("unexpected problem: %s", problems[0])
+	}
+	if problems[1].Message != "cycle detected: self -> self" {
+		t.Errorf("unexpected problem: %s", problems[1])
+	}
+}
+
+func Test_Validate_Conditions(t *testing.T) {
+	specs := map[string]v1.FlowSpec{
+		"fraud": {},
+		"checkout": {
+			Args: []string{"amount"},
+			Children: map[string]v1.FlowChild{
+				"a":      {Function: "fraud", When: "children.b.ok"},
+				"b":      {Function: "fraud", When: "children.a.ok"},
+				"broken": {Function: "fraud", When: "args.amount >"},
+				"self":   {Function: "fraud", When: "children.self.ok"},
+				"valid":  {Function: "fraud", When: "args.amount > 1000 && children.a.ok"},
+				"wrong":  {Function: "fraud", When: "args.total > 1 || children.missing"},
+			},
+		},
+	}
+
+	got := []string{}
+	for _, problem := range Validate(specs) {
+		got = append(got, problem.String())
+	}
+
+	want := []string{
+		`checkout: child a: children wait on each other: a -> b -> a`,
+		`checkout: child broken: invalid when condition: unexpected "end of expression" at position 13`,
+		`checkout: child self: when refers to the child itself`,
+		`checkout: child wrong: when uses "total" which is not an arg of checkout`,
+		`checkout: child wrong: when refers to "missing" which is not a child of checkout`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("want problems:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func Test_NewHandler_RejectsInvalidFlow(t *testing.T) {
+	lookup, _ := newTestLookup(t, "openfaas-fn", map[string]v1.FlowSpec{
+		"ping": {Children: map[string]v1.FlowChild{"pong": {Function: "pong"}}},
+		"pong": {Children: map[string]v1.FlowChild{"ping": {Function: "ping"}}},
+	})
+	handler := NewHandler(types.FaaSConfig{}, "openfaas-fn", nil, testResolver{url: &url.URL{}}, lookup, nil, nil, nil, nil, false)
+
+	rr := serveFlow(handler, "ping", `{}`)
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("want status %d, got: %d", http.StatusInternalServerError, rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), "cycle detected: ping -> pong -> ping") {
+		t.Fatalf("want cycle in error, got: %s", rr.Body.String())
+	}
+}
+
+func Test_MakeValidateHandler(t *testing.T) {
+	lookup, _ := newTestLookup(t, "openfaas-fn", map[string]v1.FlowSpec{
+		"rates":  {Args: []string{"currency"}},
+		"broken": {Children: map[string]v1.FlowChild{"gone": {Function: "gone"}}},
+	})
+	handler := MakeValidateHandler(lookup)
+
+	cases := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantCount  int
+	}{
+		{
+			name: "yaml flow using a deployed child",
+			body: `apiVersion: openfaas.com/v1
+kind: Flow
+metadata:
+  name: checkout
+spec:
+  args: ["currency"]
+  children:
+    rate:
+      function: rates
+      argsMap:
+        currency: currency
+`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "multiple yaml documents with problems",
+			body: `kind: Flow
+metadata:
+  name: checkout
+spec:
+  children:
+    stock:
+      function: stock
+---
+kind: Flow
+metadata:
+  name: refund
+spec:
+  children:
+    refund:
+      function: refund
+`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCount:  2,
+		},
+		{
+			name:       "legacy flows file",
+			body:       `{"flows": {"checkout": {"args": ["user"], "children": {"rate": {"function": "rates", "args_map": {"currency": "user"}}}}}}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "unsupported kind",
+			body:       `{"kind": "Function", "metadata": {"name": "checkout"}}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "too large",
+			body:       `{"flows": {}}` + strings.Repeat(" ", maxValidateBodySize),
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/system/flows/validate", strings.NewReader(tc.body))
+			rr := httptest.NewRecorder()
+			handler(rr, req)
+
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("want status %d, got: %d: %s", tc.wantStatus, rr.Code, rr.Body.String())
+			}
+			if tc.wantStatus == http.StatusBadRequest || tc.wantStatus == http.StatusRequestEntityTooLarge {
+				return
+			}
+
+			var res ValidationResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+				t.Fatalf("unable to decode response: %s", err)
+			}
+			if len(res.Problems) != tc.wantCount {
+				t.Fatalf("want %d problems, got: %v", tc.wantCount, res.Problems)
+			}
+		})
+	}
+}
@@ -2,20 +2,45 @@ package handlers
 
 import (
 	"encoding/json"
-	providertypes "github.com/danenherdi/faas-provider/types"
 	"log"
 	"net/http"
+
+	openfaasv1 "github.com/openfaas/faas-netes/pkg/apis/openfaas/v1"
+	listers "github.com/openfaas/faas-netes/pkg/client/listers/openfaas/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
-func MakeFlowsHandler(flows providertypes.Flows) http.HandlerFunc {
+// FlowsResponse lists the Flow definitions keyed by name
+type FlowsResponse struct {
+	Flows map[string]openfaasv1.FlowSpec `json:"flows"`
+}
+
+// MakeFlowsHandler lists the Flow resources in the function namespace
+func MakeFlowsHandler(defaultNamespace string, flowLister listers.FlowLister) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Header().Set("Content-Type", "application/json")
+		res, err := flowLister.Flows(defaultNamespace).List(labels.Everything())
+		if err != nil {
+			log.Printf("Error listing flows: %s", err)
+			http.Error(w, "Unable to list flows", http.StatusInternalServerError)
+			return
+		}
+
+		flows := FlowsResponse{
+			Flows: make(map[string]openfaasv1.FlowSpec, len(res)),
+		}
+		for _, flow := range res {
+			flows.Flows[flow.Name] = flow.Spec
+		}
+
 		jsonResp, err := json.Marshal(flows)
 		if err != nil {
-			log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+			log.Printf("Error marshalling flows: %s", err)
+			http.Error(w, "Unable to marshal flows", http.StatusInternalServerError)
+			return
 		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
 		w.Write(jsonResp)
-		return
 	}
 }
@@ -11,8 +11,12 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
 
 	types "github.com/danenherdi/faas-provider/types"
+	openfaasv1 "github.com/openfaas/faas-netes/pkg/apis/openfaas/v1"
+	listers "github.com/openfaas/faas-netes/pkg/client/listers/openfaas/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/selection"
 	v1 "k8s.io/client-go/listers/apps/v1"
@@ -94,9 +98,202 @@ func getServiceList(functionNamespace string, deploymentLister v1.DeploymentList
 	return functions, nil
 }
 
-// MakeFlowReader handler for reading workflows deployed in the cluster as deployments.
-func MakeFlowReader(defaultNamespace string, deploymentLister v1.DeploymentLister) http.HandlerFunc {
+// FlowStatus is a flow with its nodes resolved to the deployments which
+// back them
+type FlowStatus struct {
+	Name string   `json:"name"`
+	Args []string `json:"args,omitempty"`
+
+	// ThirdPartyURL is set when the flow is served by an external API
+	// rather than by a function
+	ThirdPartyURL string `json:"thirdPartyURL,omitempty"`
+
+	// Function is the flow's own function, unset for third-party flows
+	Function *FlowNodeStatus `json:"function,omitempty"`
+
+	// Children are keyed by alias
+	Children map[string]FlowChildStatus `json:"children,omitempty"`
+
+	// Ready is true when the flow's function and every child which would
+	// fail the flow have at least one available replica
+	Ready bool `json:"ready"`
+}
+
+// FlowChildStatus is a child of a flow, resolved to the flow it invokes
+type FlowChildStatus struct {
+	// Flow is the name of the flow invoked by the child
+	Flow string `json:"flow"`
+
+	// FlowExists is false when no Flow resource exists with that name
+	FlowExists bool `json:"flowExists"`
+
+	// ThirdPartyURL is set when the child flow is served by an external API
+	ThirdPartyURL string `json:"thirdPartyURL,omitempty"`
+
+	// Function is the child flow's function, unset for third-party flows
+	Function *FlowNodeStatus `json:"function,omitempty"`
+
+	// Ready is true when the child can be invoked
+	Ready bool `json:"ready"`
+}
+
+// FlowNodeStatus is the deployment which backs a node of a flow
+type FlowNodeStatus struct {
+	Name              string `json:"name"`
+	Exists            bool   `json:"exists"`
+	Image             string `json:"image,omitempty"`
+	Replicas          uint64 `json:"replicas"`
+	AvailableReplicas uint64 `json:"availableReplicas"`
+}
+
+// MakeFlowReader handler for reading flows along with the status of the
+// deployments which back each of their nodes.
+func MakeFlowReader(defaultNamespace string, flowLister listers.FlowLister, deploymentLister v1.DeploymentLister) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
+		q := r.URL.Query()
+		namespace := q.Get("namespace")
+
+		lookupNamespace := defaultNamespace
+
+		if len(namespace) > 0 {
+			lookupNamespace = namespace
+		}
+
+		if lookupNamespace != defaultNamespace {
+			http.Error(w, fmt.Sprintf("namespace must be: %s", defaultNamespace), http.StatusBadRequest)
+			return
+		}
+
+		flows, err := getFlowList(lookupNamespace, flowLister, deploymentLister)
+		if err != nil {
+			log.Println(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		flowBytes, err := json.Marshal(flows)
+		if err != nil {
+			klog.Errorf("Failed to marshal flows: %s", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Failed to marshal flows"))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(flowBytes)
+	}
+}
+
+func getFlowList(namespace string, flowLister listers.FlowLister, deploymentLister v1.DeploymentLister) ([]FlowStatus, error) {
+	res, err := flowLister.Flows(namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	specs := make(map[string]openfaasv1.FlowSpec, len(res))
+	for _, flow := range res {
+		specs[flow.Name] = flow.Spec
+	}
+
+	nodes := map[string]*FlowNodeStatus{}
+	getNode := func(name string) (*FlowNodeStatus, error) {
+		if node, ok := nodes[name]; ok {
+			return node, nil
+		}
+		node, err := getFlowNode(namespace, name, deploymentLister)
+		if err != nil {
+			return nil, err
+		}
+		nodes[name] = node
+		return node, nil
 	}
+
+	flows := make([]FlowStatus, 0, len(res))
+	for _, flow := range res {
+		status := FlowStatus{
+			Name: flow.Name,
+			Args: flow.Spec.Args,
+		}
+
+		if flow.Spec.IsThirdParty {
+			if flow.Spec.ThirdPartyURL != nil {
+				status.ThirdPartyURL = *flow.Spec.ThirdPartyURL
+			}
+			status.Ready = len(status.ThirdPartyURL) > 0
+		} else {
+			if status.Function, err = getNode(flow.Name); err != nil {
+				return nil, err
+			}
+			status.Ready = status.Function.AvailableReplicas > 0
+		}
+
+		if len(flow.Spec.Children) > 0 {
+			status.Children = make(map[string]FlowChildStatus, len(flow.Spec.Children))
+		}
+
+		for alias, child := range flow.Spec.Children {
+			childStatus := FlowChildStatus{
+				Flow: child.Function,
+			}
+
+			if childSpec, ok := specs[child.Function]; ok {
+				childStatus.FlowExists = true
+
+				if childSpec.IsThirdParty {
+					if childSpec.ThirdPartyURL != nil {
+						childStatus.ThirdPartyURL = *childSpec.ThirdPartyURL
+					}
+					childStatus.Ready = len(childStatus.ThirdPartyURL) > 0
+				} else {
+					if childStatus.Function, err = getNode(child.Function); err != nil {
+						return nil, err
+					}
+					childStatus.Ready = childStatus.Function.AvailableReplicas > 0
+				}
+			}
+
+			failsFlow := child.OnError == "" || child.OnError == openfaasv1.ChildErrorFail
+			if !childStatus.Ready && failsFlow {
+				status.Ready = false
+			}
+
+			status.Children[alias] = childStatus
+		}
+
+		flows = append(flows, status)
+	}
+
+	sort.Slice(flows, func(i, j int) bool {
+		return flows[i].Name < flows[j].Name
+	})
+
+	return flows, nil
+}
+
+// getFlowNode resolves the function of a flow to its deployment
+func getFlowNode(namespace, name string, deploymentLister v1.DeploymentLister) (*FlowNodeStatus, error) {
+	node := &FlowNodeStatus{Name: name}
+
+	deployment, err := deploymentLister.Deployments(namespace).Get(name)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return node, nil
+		}
+		return nil, err
+	}
+
+	if _, ok := deployment.Spec.Template.Labels["faas_function"]; !ok || len(deployment.Spec.Template.Spec.Containers) == 0 {
+		return node, nil
+	}
+
+	function := k8s.AsFunctionStatus(*deployment)
+	node.Exists = true
+	node.Image = function.Image
+	node.Replicas = function.Replicas
+	node.AvailableReplicas = function.AvailableReplicas
+
+	return node, nil
 }
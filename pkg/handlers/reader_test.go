@@ -0,0 +1,117 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	openfaasv1 "github.com/openfaas/faas-netes/pkg/apis/openfaas/v1"
+	listers "github.com/openfaas/faas-netes/pkg/client/listers/openfaas/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newTestIndexer() cache.Indexer {
+	return cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+}
+
+func newFunctionDeployment(name string, available int32) *appsv1.Deployment {
+	replicas := int32(1)
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "openfaas-fn"},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"faas_function": name}},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: name, Image: "ghcr.io/openfaas/" + name + ":latest"}},
+				},
+			},
+		},
+		Status: appsv1.DeploymentStatus{AvailableReplicas: available},
+	}
+}
+
+func Test_MakeFlowReader(t *testing.T) {
+	rates := "https://rates.example.com"
+
+	flowIndexer := newTestIndexer()
+	for name, spec := range map[string]openfaasv1.FlowSpec{
+		"rates": {IsThirdParty: true, ThirdPartyURL: &rates},
+		"stock": {},
+		"cart":  {},
+		"checkout": {
+			Args: []string{"user"},
+			Children: map[string]openfaasv1.FlowChild{
+				"rate":  {Function: "rates"},
+				"stock": {Function: "stock", OnError: openfaasv1.ChildErrorSkip},
+				"cart":  {Function: "cart"},
+			},
+		},
+	} {
+		flowIndexer.Add(&openfaasv1.Flow{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "openfaas-fn"}, Spec: spec})
+	}
+
+	deploymentIndexer := newTestIndexer()
+	deploymentIndexer.Add(newFunctionDeployment("checkout", 1))
+	deploymentIndexer.Add(newFunctionDeployment("cart", 0))
+
+	handler := MakeFlowReader("openfaas-fn", listers.NewFlowLister(flowIndexer), appslisters.NewDeploymentLister(deploymentIndexer))
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/system/flows/status", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("want status %d, got: %d", http.StatusOK, rr.Code)
+	}
+
+	var flows []FlowStatus
+	if err := json.Unmarshal(rr.Body.Bytes(), &flows); err != nil {
+		t.Fatalf("unable to decode response: %s", err)
+	}
+	if len(flows) != 4 || flows[1].Name != "checkout" {
+		t.Fatalf("want 4 flows sorted by name, got: %+v", flows)
+	}
+
+	checkout := flows[1]
+	if checkout.Function == nil || !checkout.Function.Exists || checkout.Function.Image != "ghcr.io/openfaas/checkout:latest" {
+		t.Fatalf("unexpected function: %+v", checkout.Function)
+	}
+	if !checkout.Children["rate"].Ready || checkout.Children["rate"].ThirdPartyURL != rates {
+		t.Errorf("want third-party child to be ready, got: %+v", checkout.Children["rate"])
+	}
+	if checkout.Children["stock"].Function.Exists || checkout.Children["stock"].Ready {
+		t.Errorf("want child without a deployment to not exist, got: %+v", checkout.Children["stock"])
+	}
+	if cart := checkout.Children["cart"]; !cart.Function.Exists || cart.Function.AvailableReplicas != 0 || cart.Ready {
+		t.Errorf("want child without available replicas to not be ready, got: %+v", cart)
+	}
+	if checkout.Ready {
+		t.Errorf("want flow to not be ready while cart has no available replicas")
+	}
+
+	deploymentIndexer.Update(newFunctionDeployment("cart", 1))
+
+	rr = httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/system/flows/status", nil))
+	json.Unmarshal(rr.Body.Bytes(), &flows)
+	if !flows[1].Ready {
+		t.Errorf("want flow to be ready when only a skipped child is unavailable, got: %+v", flows[1])
+	}
+}
+
+func Test_MakeFlowReader_OtherNamespace(t *testing.T) {
+	handler := MakeFlowReader("openfaas-fn", listers.NewFlowLister(newTestIndexer()), appslisters.NewDeploymentLister(newTestIndexer()))
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/system/flows/status?namespace=kube-system", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("want status %d, got: %d", http.StatusBadRequest, rr.Code)
+	}
+}
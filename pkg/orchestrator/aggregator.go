@@ -0,0 +1,189 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package orchestrator
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/danenherdi/faas-provider/pkg/adaptive"
+)
+
+const (
+	// maxHistory is the number of snapshots given to the trend analysis,
+	// as kept by adaptive.MetricsAggregator
+	maxHistory = 10
+	// profileWait is the time each policy is in use while profiling, as
+	// in adaptive.MetricsAggregator
+	profileWait = 2 * time.Second
+)
+
+// aggregator collects the metrics of a Backend in the same way as
+// adaptive.MetricsAggregator does for PaperCache: the miss ratio of each
+// policy as last measured while it was in use, and a short history of
+// snapshots of the policy in use
+type aggregator struct {
+	backend Backend
+	// wait is the time each policy is in use while profiling
+	wait time.Duration
+
+	lock     sync.RWMutex
+	policies []string
+	metrics  map[string]*adaptive.PolicyMetrics
+	history  []*adaptive.MetricsSnapshot
+}
+
+func newAggregator(backend Backend) *aggregator {
+	return &aggregator{
+		backend: backend,
+		wait:    profileWait,
+		metrics: map[string]*adaptive.PolicyMetrics{},
+	}
+}
+
+// initialize reads the policies of the backend
+func (a *aggregator) initialize() error {
+	policies, err := a.backend.Policies()
+	if err != nil {
+		return fmt.Errorf("failed to read the policies of %s: %w", a.backend.Name(), err)
+	}
+
+	a.lock.Lock()
+	a.policies = policies
+	a.lock.Unlock()
+
+	log.Printf("[Orchestrator] %s has %d policies: %v", a.backend.Name(), len(policies), policies)
+	return nil
+}
+
+// profile switches to each policy in turn and measures its miss ratio
+// once it has been in use for a.wait, a policy which can not be measured
+// is skipped
+func (a *aggregator) profile() error {
+	policies := a.configuredPolicies()
+	if len(policies) == 0 {
+		return fmt.Errorf("no policies configured, call initialize first")
+	}
+
+	for i, policy := range policies {
+		log.Printf("[Orchestrator] [%d/%d] Profiling policy: %s", i+1, len(policies), policy)
+
+		if err := a.backend.SetPolicy(policy); err != nil {
+			log.Printf("[Orchestrator] WARNING: Failed to switch to %s: %v", policy, err)
+			continue
+		}
+
+		time.Sleep(a.wait)
+
+		stats, err := a.backend.Stats()
+		if err != nil {
+			log.Printf("[Orchestrator] WARNING: Failed to read the stats of %s: %v", policy, err)
+			continue
+		}
+		a.store(policy, stats.MissRatio)
+	}
+
+	return nil
+}
+
+// collect measures the policy in use and adds a snapshot to the history
+func (a *aggregator) collect() (*adaptive.MetricsSnapshot, error) {
+	stats, err := a.backend.Stats()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the stats of %s: %w", a.backend.Name(), err)
+	}
+
+	a.store(stats.Policy, stats.MissRatio)
+
+	snapshot := &adaptive.MetricsSnapshot{
+		Timestamp:     time.Now(),
+		CurrentPolicy: stats.Policy,
+		MissRatio:     stats.MissRatio,
+		CacheSize:     stats.MaxSize,
+		UsedSize:      stats.UsedSize,
+		NumObjects:    stats.NumObjects,
+		TotalGets:     stats.TotalGets,
+		TotalSets:     stats.TotalSets,
+	}
+
+	a.lock.Lock()
+	a.history = append(a.history, snapshot)
+	if len(a.history) > maxHistory {
+		a.history = a.history[1:]
+	}
+	a.lock.Unlock()
+
+	return snapshot, nil
+}
+
+func (a *aggregator) store(policy string, missRatio float64) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	metrics, ok := a.metrics[policy]
+	if !ok {
+		metrics = &adaptive.PolicyMetrics{Name: policy}
+		a.metrics[policy] = metrics
+	}
+	metrics.MissRatio = missRatio
+	metrics.HitRatio = 1 - missRatio
+	metrics.LastUpdated = time.Now()
+	metrics.SampleCount++
+}
+
+// best returns the policy with the lowest miss ratio, or an empty string
+// when none has been measured
+func (a *aggregator) best() string {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	best, lowest := "", 0.0
+	for _, policy := range a.policies {
+		if metrics, ok := a.metrics[policy]; ok && (len(best) == 0 || metrics.MissRatio < lowest) {
+			best, lowest = policy, metrics.MissRatio
+		}
+	}
+	return best
+}
+
+func (a *aggregator) configuredPolicies() []string {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	return append([]string{}, a.policies...)
+}
+
+func (a *aggregator) snapshots() []*adaptive.MetricsSnapshot {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	return append([]*adaptive.MetricsSnapshot{}, a.history...)
+}
+
+// aggregated returns a copy of the metrics of each policy and of the
+// history
+func (a *aggregator) aggregated() *adaptive.AggregatedMetrics {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	aggregated := &adaptive.AggregatedMetrics{
+		AllPoliciesMetrics: make(map[string]*adaptive.PolicyMetrics, len(a.metrics)),
+		History:            append([]*adaptive.MetricsSnapshot{}, a.history...),
+		IsComplete:         len(a.metrics) == len(a.policies),
+	}
+	for name, metrics := range a.metrics {
+		copied := *metrics
+		aggregated.AllPoliciesMetrics[name] = &copied
+	}
+	if len(a.history) > 0 {
+		latest := a.history[len(a.history)-1]
+		aggregated.CurrentPolicy = latest.CurrentPolicy
+		aggregated.CurrentPolicyMissRatio = latest.MissRatio
+		aggregated.CacheSize = latest.CacheSize
+	}
+
+	return aggregated
+}
@@ -0,0 +1,113 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package orchestrator
+
+import (
+	"github.com/danenherdi/faas-provider/types"
+	paperClient "github.com/danenherdi/paper-client-go"
+	"github.com/openfaas/faas-netes/pkg/caching"
+)
+
+// Backend is a cache whose eviction policy can be changed while it is
+// running, the orchestrator drives any cache through it
+type Backend interface {
+	// Name identifies the cache in logs
+	Name() string
+	// Policies returns the policies which the orchestrator chooses between
+	Policies() ([]string, error)
+	// SetPolicy changes the eviction policy
+	SetPolicy(policy string) error
+	// Stats returns the policy in use and the miss ratio measured by the
+	// cache
+	Stats() (*BackendStats, error)
+}
+
+// sharedBackend is implemented by a Backend which is not profiled when the
+// orchestrator starts. Such a cache is shared and under real traffic, so
+// cycling it through every policy would change its eviction on each start
+// and measure little but noise. The orchestrator starts from the policy in
+// use instead, and measures the candidates by replaying the reads of the
+// cache against a simulation of each policy, see shadow.
+type sharedBackend interface {
+	// CurrentPolicy returns the policy in use, and an error when the
+	// policy can not be changed
+	CurrentPolicy() (string, error)
+	// SimulatedPolicy returns the policy of cachesim which approximates
+	// policy, false when there is none and policy can only be pinned
+	SimulatedPolicy(policy string) (string, bool)
+}
+
+// BackendStats is the state of a cache as reported by a Backend
+type BackendStats struct {
+	Policy    string
+	MissRatio float64
+	// MaxSize is the capacity of the cache in bytes, it sets the cost of
+	// rebuilding the cache after a switch
+	MaxSize    uint64
+	UsedSize   uint64
+	NumObjects uint64
+	TotalGets  uint64
+	TotalSets  uint64
+}
+
+// NewBackend returns the Backend for a cache client, or nil when the cache
+// can not change its eviction policy. Redis switches between
+// redisPolicies, PaperCache between the policies it is configured with.
+func NewBackend(cacheClient types.CacheClient, redisPolicies []string) Backend {
+	switch client := cacheClient.(type) {
+	case *caching.PaperCache:
+		return NewPaperCacheBackend(client.Client)
+	case *caching.Redis:
+		return NewRedisBackend(client.Client, redisPolicies)
+	}
+	return nil
+}
+
+// PaperCacheBackend drives PaperCache, which reports its own miss ratio
+// and the policies it is configured with
+type PaperCacheBackend struct {
+	client *paperClient.PaperClient
+}
+
+// NewPaperCacheBackend creates a Backend for a PaperCache connection
+func NewPaperCacheBackend(client *paperClient.PaperClient) *PaperCacheBackend {
+	return &PaperCacheBackend{client: client}
+}
+
+// Name implements Backend
+func (p *PaperCacheBackend) Name() string {
+	return "PaperCache"
+}
+
+// Policies implements Backend
+func (p *PaperCacheBackend) Policies() ([]string, error) {
+	status, err := p.client.Status()
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{}, status.GetPolicies()...), nil
+}
+
+// SetPolicy implements Backend
+func (p *PaperCacheBackend) SetPolicy(policy string) error {
+	return p.client.Policy(policy)
+}
+
+// Stats implements Backend
+func (p *PaperCacheBackend) Stats() (*BackendStats, error) {
+	status, err := p.client.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	return &BackendStats{
+		Policy:     status.GetPolicy(),
+		MissRatio:  status.GetMissRatio(),
+		MaxSize:    status.GetMaxSize(),
+		UsedSize:   status.GetUsedSize(),
+		NumObjects: status.GetNumObjects(),
+		TotalGets:  status.GetTotalGets(),
+		TotalSets:  status.GetTotalSets(),
+	}, nil
+}
@@ -0,0 +1,229 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package orchestrator
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danenherdi/faas-provider/pkg/adaptive"
+)
+
+// Triggers of a decision
+const (
+	triggerShift       = "pattern shift"
+	triggerDegradation = "degradation"
+	triggerOperator    = "operator"
+	// triggerInterval is an evaluation on the interval which found nothing
+	// to act on
+	triggerInterval = "interval"
+)
+
+// Decisions made by an operator, alongside those of an evaluation
+const (
+	decisionPin    = "pin"
+	decisionUnpin  = "unpin"
+	decisionPause  = "pause"
+	decisionResume = "resume"
+)
+
+// Decision is the record of an evaluation or of an operator's action. An
+// evaluation which found neither a shift in the pattern of access nor a
+// degrading miss ratio is recorded as stable, and consecutive stable
+// evaluations of the same policy share one record, so that they do not
+// push the other decisions out of the history.
+type Decision struct {
+	// ID increases with each decision, so that exports can be merged
+	ID        int64     `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+
+	// Trigger is why the decision was made: pattern shift, degradation,
+	// both separated by a comma, operator, or interval for a stable
+	// evaluation
+	Trigger string `json:"trigger"`
+	// Decision is one of stable, switch, insufficient benefit, no
+	// candidate, pinned or paused for an evaluation, and pin, unpin, pause
+	// or resume for an operator's action
+	Decision string `json:"decision"`
+
+	// Evaluations is the number of consecutive stable evaluations in the
+	// record, the first was at Timestamp and the last at Until. The
+	// measurements are those of the last.
+	Evaluations int        `json:"evaluations,omitempty"`
+	Until       *time.Time `json:"until,omitempty"`
+
+	// FromPolicy is the policy in use when the decision was made, and
+	// ToPolicy the best candidate or the pinned policy
+	FromPolicy string `json:"fromPolicy,omitempty"`
+	ToPolicy   string `json:"toPolicy,omitempty"`
+
+	CurrentMissRatio   float64 `json:"currentMissRatio"`
+	CandidateMissRatio float64 `json:"candidateMissRatio"`
+	// SwitchingCost is the estimated loss of miss ratio from switching
+	SwitchingCost float64 `json:"switchingCost"`
+	NetBenefit    float64 `json:"netBenefit"`
+
+	PatternShift *PatternShiftStatus `json:"patternShift,omitempty"`
+	Trend        *TrendStatus        `json:"trend,omitempty"`
+
+	// Error is set when the policy of the cache could not be changed
+	Error string `json:"error,omitempty"`
+}
+
+// DecisionLog keeps the most recent decisions of the orchestrator in a
+// ring buffer, so that the memory it uses is bounded. A nil log holds
+// nothing.
+type DecisionLog struct {
+	lock      sync.RWMutex
+	decisions []*Decision
+	next      int
+	lastID    int64
+}
+
+// NewDecisionLog creates a log of up to size decisions, the oldest is
+// dropped to make room for the next
+func NewDecisionLog(size int) *DecisionLog {
+	return &DecisionLog{
+		decisions: make([]*Decision, size),
+	}
+}
+
+// add assigns the next ID to decision and stores it, replacing the oldest
+// when the log is full. A stable decision which follows another for the
+// same policy is merged into it.
+func (l *DecisionLog) add(decision *Decision) {
+	if l == nil || len(l.decisions) == 0 {
+		return
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if decision.Decision == decisionStable {
+		last := l.decisions[(l.next-1+len(l.decisions))%len(l.decisions)]
+		if last != nil && last.Decision == decisionStable && last.FromPolicy == decision.FromPolicy {
+			until := decision.Timestamp
+			merged := *decision
+			merged.ID = last.ID
+			merged.Timestamp = last.Timestamp
+			merged.Evaluations = last.Evaluations + 1
+			merged.Until = &until
+			*last = merged
+			return
+		}
+		decision.Evaluations = 1
+	}
+
+	l.lastID++
+	decision.ID = l.lastID
+
+	l.decisions[l.next] = decision
+	l.next = (l.next + 1) % len(l.decisions)
+}
+
+// DecisionFilter selects decisions from the log, the zero value selects
+// all of them
+type DecisionFilter struct {
+	// Decision only selects decisions of this kind, such as switch
+	Decision string
+	// ExcludeStable leaves out the stable evaluations
+	ExcludeStable bool
+	// Since only selects decisions made after this time, or for stable
+	// evaluations, whose last evaluation was after it
+	Since time.Time
+	// Limit is the maximum number of decisions, the most recent are kept
+	Limit int
+}
+
+// List returns the decisions selected by filter, oldest first
+func (l *DecisionLog) List(filter DecisionFilter) []Decision {
+	decisions := []Decision{}
+	if l == nil {
+		return decisions
+	}
+
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	for i := 1; i <= len(l.decisions); i++ {
+		decision := l.decisions[(l.next-i+len(l.decisions))%len(l.decisions)]
+		if decision == nil {
+			break
+		}
+		if filter.Limit > 0 && len(decisions) == filter.Limit {
+			break
+		}
+		if len(filter.Decision) > 0 && decision.Decision != filter.Decision {
+			continue
+		}
+		if filter.ExcludeStable && decision.Decision == decisionStable {
+			continue
+		}
+		if !filter.Since.IsZero() && !decision.last().After(filter.Since) {
+			continue
+		}
+
+		decisions = append(decisions, *decision)
+	}
+
+	for i, j := 0, len(decisions)-1; i < j; i, j = i+1, j-1 {
+		decisions[i], decisions[j] = decisions[j], decisions[i]
+	}
+	return decisions
+}
+
+// last returns the time of the last evaluation of the decision
+func (d *Decision) last() time.Time {
+	if d.Until != nil {
+		return *d.Until
+	}
+	return d.Timestamp
+}
+
+// trigger returns why the candidates were compared, or interval when
+// there was no reason to
+func trigger(force bool, shift *adaptive.PatternShift, trend *adaptive.TrendScore) string {
+	if force {
+		return triggerOperator
+	}
+
+	triggers := []string{}
+	if shift.Detected {
+		triggers = append(triggers, triggerShift)
+	}
+	if trend.IndicatesDegradation {
+		triggers = append(triggers, triggerDegradation)
+	}
+	if len(triggers) == 0 {
+		return triggerInterval
+	}
+	return strings.Join(triggers, ", ")
+}
+
+// newDecision records the outcome of an evaluation, analysis is nil when
+// no candidate was found
+func newDecision(trigger, decision, currentPolicy string, missRatio float64, shift *adaptive.PatternShift, trend *adaptive.TrendScore, analysis *adaptive.CostBenefitAnalysis) *Decision {
+	record := &Decision{
+		Timestamp:        time.Now(),
+		Trigger:          trigger,
+		Decision:         decision,
+		FromPolicy:       currentPolicy,
+		CurrentMissRatio: missRatio,
+		PatternShift:     patternShiftStatus(shift),
+		Trend:            trendStatus(trend),
+	}
+
+	if analysis != nil {
+		record.ToPolicy = analysis.CandidatePolicy
+		record.CurrentMissRatio = analysis.CurrentMissRatio
+		record.CandidateMissRatio = analysis.CandidateMissRatio
+		record.NetBenefit = analysis.NetBenefit
+		if analysis.SwitchingCost != nil {
+			record.SwitchingCost = analysis.SwitchingCost.TotalCost
+		}
+	}
+
+	return record
+}
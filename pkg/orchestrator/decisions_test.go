@@ -0,0 +1,145 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package orchestrator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/danenherdi/faas-provider/pkg/adaptive"
+)
+
+func Test_DecisionLog_DropsOldest(t *testing.T) {
+	decisions := NewDecisionLog(2)
+	start := time.Unix(1700000000, 0)
+	for i, decision := range []string{decisionSwitch, decisionInsufficient, decisionSwitch} {
+		decisions.add(&Decision{Timestamp: start.Add(time.Duration(i) * time.Second), Decision: decision})
+	}
+
+	all := decisions.List(DecisionFilter{})
+	if len(all) != 2 || all[0].ID != 2 || all[1].ID != 3 {
+		t.Fatalf("want decisions 2 and 3 oldest first, got: %+v", all)
+	}
+
+	switches := decisions.List(DecisionFilter{Decision: decisionSwitch})
+	if len(switches) != 1 || switches[0].ID != 3 {
+		t.Errorf("want decision 3, got: %+v", switches)
+	}
+
+	latest := decisions.List(DecisionFilter{Limit: 1})
+	if len(latest) != 1 || latest[0].ID != 3 {
+		t.Errorf("want the most recent decision, got: %+v", latest)
+	}
+
+	since := decisions.List(DecisionFilter{Since: start.Add(time.Second)})
+	if len(since) != 1 || since[0].ID != 3 {
+		t.Errorf("want decisions after the second, got: %+v", since)
+	}
+}
+
+func Test_DecisionLog_MergesStable(t *testing.T) {
+	decisions := NewDecisionLog(3)
+	start := time.Unix(1700000000, 0)
+	for i, d := range []struct{ decision, policy string }{
+		{decisionStable, "lru"},
+		{decisionStable, "lru"},
+		{decisionSwitch, "lru"},
+		{decisionStable, "lfu"},
+		{decisionStable, "lfu"},
+		{decisionStable, "lfu"},
+	} {
+		decisions.add(&Decision{Timestamp: start.Add(time.Duration(i) * time.Second), Decision: d.decision, FromPolicy: d.policy, CurrentMissRatio: float64(i)})
+	}
+
+	all := decisions.List(DecisionFilter{})
+	if len(all) != 3 {
+		t.Fatalf("want 3 decisions, got: %+v", all)
+	}
+	if first := all[0]; first.Evaluations != 2 || !first.Timestamp.Equal(start) || first.Until == nil || !first.Until.Equal(start.Add(time.Second)) {
+		t.Errorf("want the first two evaluations merged, got: %+v", first)
+	}
+	if last := all[2]; last.ID != 3 || last.Evaluations != 3 || last.CurrentMissRatio != 5 {
+		t.Errorf("want the last three evaluations merged with the latest miss ratio, got: %+v", last)
+	}
+
+	if changed := decisions.List(DecisionFilter{ExcludeStable: true}); len(changed) != 1 || changed[0].Decision != decisionSwitch {
+		t.Errorf("want only the switch, got: %+v", changed)
+	}
+
+	since := decisions.List(DecisionFilter{Since: start.Add(4 * time.Second)})
+	if len(since) != 1 || since[0].ID != 3 {
+		t.Errorf("want the stable evaluations which continued after since, got: %+v", since)
+	}
+}
+
+func Test_DecisionLog_Nil(t *testing.T) {
+	var decisions *DecisionLog
+	decisions.add(&Decision{})
+
+	if got := decisions.List(DecisionFilter{}); got == nil || len(got) != 0 {
+		t.Errorf("want an empty list, got: %v", got)
+	}
+}
+
+func Test_trigger(t *testing.T) {
+	cases := []struct {
+		name  string
+		force bool
+		shift bool
+		trend bool
+		want  string
+	}{
+		{"operator", true, true, false, triggerOperator},
+		{"pattern shift", false, true, false, triggerShift},
+		{"degradation", false, false, true, triggerDegradation},
+		{"both", false, true, true, "pattern shift, degradation"},
+		{"interval", false, false, false, triggerInterval},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := trigger(tc.force, &adaptive.PatternShift{Detected: tc.shift}, &adaptive.TrendScore{IndicatesDegradation: tc.trend})
+			if got != tc.want {
+				t.Errorf("want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func Test_Orchestrator_RecordsActions(t *testing.T) {
+	o := New(&fakeBackend{}, nil, NewDecisionLog(10))
+	o.policies = []string{"lru", "lfu"}
+	o.currentPolicy = "lru"
+
+	o.Pause()
+	o.Pause()
+	if err := o.Pin("lfu"); err != nil {
+		t.Fatalf("want no error, got: %s", err)
+	}
+	o.Unpin()
+	o.Resume()
+
+	decisions := o.Decisions(DecisionFilter{})
+	got := []string{}
+	for _, decision := range decisions {
+		if decision.Trigger != triggerOperator {
+			t.Errorf("want the operator to be the trigger, got: %+v", decision)
+		}
+		got = append(got, decision.Decision)
+	}
+
+	want := []string{decisionPause, decisionPin, decisionUnpin, decisionResume}
+	if len(got) != len(want) {
+		t.Fatalf("want decisions %v, got: %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("want decisions %v, got: %v", want, got)
+		}
+	}
+
+	if pin := decisions[1]; pin.FromPolicy != "lru" || pin.ToPolicy != "lfu" {
+		t.Errorf("want the pin to record the switch, got: %+v", pin)
+	}
+}
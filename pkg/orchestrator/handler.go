@@ -0,0 +1,211 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package orchestrator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxPinBodySize limits the body of a request to pin a policy
+const maxPinBodySize = 4 * 1024
+
+// PinRequest is the body of a request to pin a policy
+type PinRequest struct {
+	Policy string `json:"policy"`
+}
+
+// MakeStatusHandler reports the policy in use, the candidates and the
+// outcome of the last evaluation
+func MakeStatusHandler(orchestrator *Orchestrator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if orchestrator == nil {
+			http.Error(w, "Cache orchestrator is not enabled", http.StatusNotImplemented)
+			return
+		}
+
+		writeJSON(w, orchestrator.Status())
+	}
+}
+
+// MakePinHandler pins the policy named in the body, switching to it if it
+// is not in use. 413 is returned when the body is larger than
+// maxPinBodySize.
+func MakePinHandler(orchestrator *Orchestrator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if orchestrator == nil {
+			http.Error(w, "Cache orchestrator is not enabled", http.StatusNotImplemented)
+			return
+		}
+
+		if r.Body != nil {
+			defer r.Body.Close()
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPinBodySize))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, fmt.Sprintf("Request body must not be larger than %d bytes", maxPinBodySize), http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "Unable to read request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		req := PinRequest{}
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, "Unable to parse request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(req.Policy) == 0 {
+			http.Error(w, "Provide the policy to pin in the request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := orchestrator.Pin(req.Policy); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrUnknownPolicy) {
+				status = http.StatusBadRequest
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		writeJSON(w, orchestrator.Status())
+	}
+}
+
+// MakeUnpinHandler lets the orchestrator switch away from a pinned policy
+func MakeUnpinHandler(orchestrator *Orchestrator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if orchestrator == nil {
+			http.Error(w, "Cache orchestrator is not enabled", http.StatusNotImplemented)
+			return
+		}
+
+		orchestrator.Unpin()
+		writeJSON(w, orchestrator.Status())
+	}
+}
+
+// MakePauseHandler stops automatic switching
+func MakePauseHandler(orchestrator *Orchestrator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if orchestrator == nil {
+			http.Error(w, "Cache orchestrator is not enabled", http.StatusNotImplemented)
+			return
+		}
+
+		orchestrator.Pause()
+		writeJSON(w, orchestrator.Status())
+	}
+}
+
+// MakeResumeHandler restarts automatic switching
+func MakeResumeHandler(orchestrator *Orchestrator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if orchestrator == nil {
+			http.Error(w, "Cache orchestrator is not enabled", http.StatusNotImplemented)
+			return
+		}
+
+		orchestrator.Resume()
+		writeJSON(w, orchestrator.Status())
+	}
+}
+
+// MakeEvaluateHandler evaluates the candidates straight away and reports
+// the outcome
+func MakeEvaluateHandler(orchestrator *Orchestrator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if orchestrator == nil {
+			http.Error(w, "Cache orchestrator is not enabled", http.StatusNotImplemented)
+			return
+		}
+
+		orchestrator.Evaluate()
+		writeJSON(w, orchestrator.Status())
+	}
+}
+
+// MakeDecisionsHandler lists the recorded decisions, oldest first. The
+// query may select a kind of decision with decision, those made after an
+// RFC3339 time with since, and the most recent with limit, stable=false
+// leaves out the stable evaluations. With format=jsonl the decisions are
+// written one per line for export. The history is only held in memory by
+// each replica, so it starts empty after a restart and should be exported
+// to keep it.
+func MakeDecisionsHandler(orchestrator *Orchestrator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if orchestrator == nil {
+			http.Error(w, "Cache orchestrator is not enabled", http.StatusNotImplemented)
+			return
+		}
+
+		query := r.URL.Query()
+		filter := DecisionFilter{
+			Decision: query.Get("decision"),
+		}
+		if stable := query.Get("stable"); len(stable) > 0 {
+			include, err := strconv.ParseBool(stable)
+			if err != nil {
+				http.Error(w, "Provide stable as true or false", http.StatusBadRequest)
+				return
+			}
+			filter.ExcludeStable = !include
+		}
+		if since := query.Get("since"); len(since) > 0 {
+			t, err := time.Parse(time.RFC3339, since)
+			if err != nil {
+				http.Error(w, "Unable to parse since as RFC3339: "+since, http.StatusBadRequest)
+				return
+			}
+			filter.Since = t
+		}
+		if limit := query.Get("limit"); len(limit) > 0 {
+			n, err := strconv.Atoi(limit)
+			if err != nil || n < 0 {
+				http.Error(w, "Provide limit as a positive integer", http.StatusBadRequest)
+				return
+			}
+			filter.Limit = n
+		}
+
+		decisions := orchestrator.Decisions(filter)
+
+		switch format := query.Get("format"); format {
+		case "", "json":
+			writeJSON(w, decisions)
+		case "jsonl":
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.Header().Set("Content-Disposition", `attachment; filename="orchestrator-decisions.jsonl"`)
+			w.WriteHeader(http.StatusOK)
+
+			encoder := json.NewEncoder(w)
+			for _, decision := range decisions {
+				encoder.Encode(decision)
+			}
+		default:
+			http.Error(w, "Unsupported format: "+format, http.StatusBadRequest)
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		http.Error(w, "Unable to marshal response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
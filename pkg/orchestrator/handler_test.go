@@ -0,0 +1,185 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package orchestrator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danenherdi/faas-provider/pkg/adaptive"
+)
+
+func Test_Handlers_NotEnabled(t *testing.T) {
+	handlers := []http.HandlerFunc{
+		MakeStatusHandler(nil),
+		MakePinHandler(nil),
+		MakeUnpinHandler(nil),
+		MakePauseHandler(nil),
+		MakeResumeHandler(nil),
+		MakeEvaluateHandler(nil),
+		MakeDecisionsHandler(nil),
+	}
+
+	for _, handler := range handlers {
+		rr := httptest.NewRecorder()
+		handler(rr, httptest.NewRequest(http.MethodPost, "/system/cache/orchestrator", nil))
+
+		if rr.Code != http.StatusNotImplemented {
+			t.Errorf("want status %d, got: %d", http.StatusNotImplemented, rr.Code)
+		}
+	}
+}
+
+func Test_MakeStatusHandler(t *testing.T) {
+	o := New(nil, nil, nil)
+	o.currentPolicy = "lru"
+	o.lastEvaluation = time.Now()
+	o.lastDecision = decisionInsufficient
+	o.lastShift = &adaptive.PatternShift{Detected: true, TemporalShift: 0.4, CurrentPattern: &adaptive.WorkloadPattern{UniqueKeys: 12}}
+	o.lastTrend = &adaptive.TrendScore{Slope: 0.01}
+	o.lastAnalysis = &adaptive.CostBenefitAnalysis{
+		CurrentPolicy:   "lru",
+		CandidatePolicy: "lfu",
+		NetBenefit:      0.01,
+		SwitchingCost:   &adaptive.SwitchingCost{TotalCost: 0.03},
+	}
+
+	rr := httptest.NewRecorder()
+	MakeStatusHandler(o)(rr, httptest.NewRequest(http.MethodGet, "/system/cache/orchestrator", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("want status %d, got: %d", http.StatusOK, rr.Code)
+	}
+
+	status := Status{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+		t.Fatalf("unable to parse status: %s", err)
+	}
+
+	if status.CurrentPolicy != "lru" || status.LastEvaluation == nil || status.LastEvaluation.Decision != decisionInsufficient {
+		t.Fatalf("unexpected status: %s", rr.Body.String())
+	}
+	evaluation := status.LastEvaluation
+	if evaluation.PatternShift == nil || !evaluation.PatternShift.Detected || evaluation.PatternShift.Pattern.UniqueKeys != 12 {
+		t.Errorf("want the pattern shift, got: %+v", evaluation.PatternShift)
+	}
+	if evaluation.Analysis == nil || evaluation.Analysis.CandidatePolicy != "lfu" || evaluation.Analysis.SwitchingCost != 0.03 {
+		t.Errorf("want the analysis, got: %+v", evaluation.Analysis)
+	}
+}
+
+func Test_MakePinHandler(t *testing.T) {
+	o := New(&fakeBackend{}, nil, nil)
+	o.policies = []string{"lru", "lfu"}
+	o.currentPolicy = "lru"
+	handler := MakePinHandler(o)
+
+	cases := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"invalid body", `{`, http.StatusBadRequest},
+		{"no policy", `{}`, http.StatusBadRequest},
+		{"unknown policy", `{"policy":"arc"}`, http.StatusBadRequest},
+		{"body too large", `{"policy":"` + strings.Repeat("a", maxPinBodySize) + `"}`, http.StatusRequestEntityTooLarge},
+		{"configured policy", `{"policy":"lfu"}`, http.StatusOK},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler(rr, httptest.NewRequest(http.MethodPost, "/system/cache/orchestrator/pin", strings.NewReader(tc.body)))
+
+			if rr.Code != tc.want {
+				t.Errorf("want status %d, got: %d, body: %s", tc.want, rr.Code, rr.Body.String())
+			}
+		})
+	}
+
+	if o.CurrentPolicy() != "lfu" || o.Status().Pinned != "lfu" {
+		t.Errorf("want lfu to be pinned, got: %+v", o.Status())
+	}
+}
+
+func Test_MakePauseHandler(t *testing.T) {
+	o := New(nil, nil, nil)
+
+	rr := httptest.NewRecorder()
+	MakePauseHandler(o)(rr, httptest.NewRequest(http.MethodPost, "/system/cache/orchestrator/pause", nil))
+	if rr.Code != http.StatusOK || !o.Status().Paused {
+		t.Fatalf("want switching to be paused, got status %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	MakeResumeHandler(o)(rr, httptest.NewRequest(http.MethodPost, "/system/cache/orchestrator/resume", nil))
+	if rr.Code != http.StatusOK || o.Status().Paused {
+		t.Fatalf("want switching to be resumed, got status %d", rr.Code)
+	}
+}
+
+func Test_MakeDecisionsHandler(t *testing.T) {
+	o := New(nil, nil, NewDecisionLog(10))
+	o.decisions.add(newDecision(triggerShift, decisionInsufficient, "lru", 0.4,
+		&adaptive.PatternShift{Detected: true}, &adaptive.TrendScore{},
+		&adaptive.CostBenefitAnalysis{CandidatePolicy: "lfu", CurrentMissRatio: 0.4, CandidateMissRatio: 0.38, NetBenefit: 0.01}))
+	o.decisions.add(newDecision(triggerDegradation, decisionSwitch, "lru", 0.5,
+		&adaptive.PatternShift{}, &adaptive.TrendScore{IndicatesDegradation: true},
+		&adaptive.CostBenefitAnalysis{CandidatePolicy: "lfu", CurrentMissRatio: 0.5, CandidateMissRatio: 0.2, NetBenefit: 0.25, SwitchingCost: &adaptive.SwitchingCost{TotalCost: 0.05}}))
+	o.decisions.add(newDecision(triggerInterval, decisionStable, "lfu", 0.2, &adaptive.PatternShift{}, &adaptive.TrendScore{}, nil))
+	handler := MakeDecisionsHandler(o)
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/system/cache/orchestrator/decisions?decision=switch", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("want status %d, got: %d", http.StatusOK, rr.Code)
+	}
+
+	decisions := []Decision{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &decisions); err != nil {
+		t.Fatalf("unable to parse decisions: %s", err)
+	}
+	if len(decisions) != 1 {
+		t.Fatalf("want one switch, got: %s", rr.Body.String())
+	}
+	if d := decisions[0]; d.FromPolicy != "lru" || d.ToPolicy != "lfu" || d.Trigger != triggerDegradation || d.SwitchingCost != 0.05 || d.NetBenefit != 0.25 || d.CandidateMissRatio != 0.2 {
+		t.Errorf("unexpected decision: %+v", d)
+	}
+
+	rr = httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/system/cache/orchestrator/decisions?format=jsonl", nil))
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("want JSONL, got status %d and %s", rr.Code, rr.Header().Get("Content-Type"))
+	}
+
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("want a line for each decision, got: %q", rr.Body.String())
+	}
+	for i, line := range lines {
+		decision := Decision{}
+		if err := json.Unmarshal([]byte(line), &decision); err != nil || decision.ID != int64(i+1) {
+			t.Errorf("want decision %d on line %d, got: %s", i+1, i+1, line)
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/system/cache/orchestrator/decisions?stable=false", nil))
+	decisions = []Decision{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &decisions); err != nil || len(decisions) != 2 {
+		t.Fatalf("want the stable evaluation left out, got: %s", rr.Body.String())
+	}
+
+	for _, query := range []string{"since=yesterday", "limit=-1", "format=csv", "stable=maybe"} {
+		rr = httptest.NewRecorder()
+		handler(rr, httptest.NewRequest(http.MethodGet, "/system/cache/orchestrator/decisions?"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("want status %d for %s, got: %d", http.StatusBadRequest, query, rr.Code)
+		}
+	}
+}
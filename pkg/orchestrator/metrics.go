@@ -0,0 +1,42 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package orchestrator
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	policyDesc = prometheus.NewDesc("flow_cache_orchestrator_policy",
+		"Set to 1 for the eviction policy chosen by the orchestrator", []string{"policy"}, nil)
+	missRatioDesc = prometheus.NewDesc("flow_cache_orchestrator_miss_ratio",
+		"Miss ratio last measured for each candidate policy", []string{"policy"}, nil)
+	switchesDesc = prometheus.NewDesc("flow_cache_orchestrator_switches_total",
+		"Policy switches made by the orchestrator", nil, nil)
+	lastSwitchDesc = prometheus.NewDesc("flow_cache_orchestrator_last_switch_timestamp_seconds",
+		"Time of the last policy switch, or of the initial choice of policy", nil, nil)
+)
+
+// Describe implements prometheus.Collector
+func (o *Orchestrator) Describe(ch chan<- *prometheus.Desc) {
+	ch <- policyDesc
+	ch <- missRatioDesc
+	ch <- switchesDesc
+	ch <- lastSwitchDesc
+}
+
+// Collect implements prometheus.Collector
+func (o *Orchestrator) Collect(ch chan<- prometheus.Metric) {
+	if policy := o.CurrentPolicy(); len(policy) > 0 {
+		ch <- prometheus.MustNewConstMetric(policyDesc, prometheus.GaugeValue, 1, policy)
+	}
+
+	for name, metrics := range o.Metrics().AllPoliciesMetrics {
+		ch <- prometheus.MustNewConstMetric(missRatioDesc, prometheus.GaugeValue, metrics.MissRatio, name)
+	}
+
+	switches, lastSwitch := o.Switches()
+	ch <- prometheus.MustNewConstMetric(switchesDesc, prometheus.CounterValue, float64(switches))
+	ch <- prometheus.MustNewConstMetric(lastSwitchDesc, prometheus.GaugeValue, float64(lastSwitch.UnixNano())/1e9)
+}
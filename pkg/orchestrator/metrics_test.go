@@ -0,0 +1,53 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package orchestrator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func Test_Orchestrator_Collect(t *testing.T) {
+	o := New(nil, nil, nil)
+	o.currentPolicy = "lfu"
+	o.switches = 2
+	o.lastSwitch = time.Unix(1700000000, 0)
+
+	registry := prometheus.NewPedanticRegistry()
+	if err := registry.Register(o); err != nil {
+		t.Fatalf("want no error, got: %s", err)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err)
+	}
+
+	values := map[string]float64{}
+	for _, family := range families {
+		metric := family.GetMetric()[0]
+		name := family.GetName()
+		for _, label := range metric.GetLabel() {
+			name += "/" + label.GetValue()
+		}
+		if counter := metric.GetCounter(); counter != nil {
+			values[name] = counter.GetValue()
+		} else {
+			values[name] = metric.GetGauge().GetValue()
+		}
+	}
+
+	want := map[string]float64{
+		"flow_cache_orchestrator_policy/lfu":                    1,
+		"flow_cache_orchestrator_switches_total":                2,
+		"flow_cache_orchestrator_last_switch_timestamp_seconds": 1700000000,
+	}
+	for name, value := range want {
+		if values[name] != value {
+			t.Errorf("want %s to be %v, got: %v", name, value, values[name])
+		}
+	}
+}
@@ -0,0 +1,466 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+// Package orchestrator switches the eviction policy of the flow cache as
+// the workload changes. It runs the evaluation of the provider's adaptive
+// package, built from the same PatternDetector, TrendAnalyzer and
+// CostBenefitAnalyzer, but keeps its state where it can be reported rather
+// than only logged. The cache is driven through a Backend, so that the
+// same evaluation applies to PaperCache and to Redis.
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/danenherdi/faas-provider/pkg/adaptive"
+)
+
+// ErrUnknownPolicy is returned when pinning a policy which is not
+// configured in the cache
+var ErrUnknownPolicy = errors.New("unknown policy")
+
+// Orchestrator evaluates the candidate policies of a Backend on each
+// interval and switches to the best of them when its net benefit, after
+// the cost of switching, is above the threshold. An operator can pin a
+// policy or pause switching, in which case evaluations are still made and
+// reported but no switch follows from them.
+type Orchestrator struct {
+	backend Backend
+	config  *adaptive.OrchestratorConfig
+
+	aggregator *aggregator
+	detector   *adaptive.PatternDetector
+	trend      *adaptive.TrendAnalyzer
+	analyzer   *adaptive.CostBenefitAnalyzer
+
+	// decisions records each evaluation which compared the candidates and
+	// each action of an operator
+	decisions *DecisionLog
+
+	// shadow simulates the candidates of a sharedBackend, it is nil for a
+	// backend which is profiled
+	shadow *shadow
+
+	// evaluating allows one evaluation at a time, as one may be triggered
+	// while another is run by the loop
+	evaluating sync.Mutex
+
+	lock sync.RWMutex
+	// policies are the policies configured in the cache
+	policies      []string
+	currentPolicy string
+	lastSwitch    time.Time
+	// switches counts the policy switches since the orchestrator was
+	// initialised, not including the initial choice of policy
+	switches int
+
+	pinned string
+	paused bool
+
+	// the outcome of the last evaluation
+	lastEvaluation time.Time
+	lastShift      *adaptive.PatternShift
+	lastTrend      *adaptive.TrendScore
+	lastAnalysis   *adaptive.CostBenefitAnalysis
+	lastDecision   string
+}
+
+// New creates an orchestrator for a cache backend, a nil config uses
+// adaptive.DefaultOrchestratorConfig and a nil log records no decisions
+func New(backend Backend, config *adaptive.OrchestratorConfig, decisions *DecisionLog) *Orchestrator {
+	if config == nil {
+		config = adaptive.DefaultOrchestratorConfig()
+	}
+
+	o := &Orchestrator{
+		backend:    backend,
+		config:     config,
+		aggregator: newAggregator(backend),
+		detector:   adaptive.NewPatternDetector(1000),
+		trend:      adaptive.NewTrendAnalyzer(),
+		analyzer:   adaptive.NewCostBenefitAnalyzer(config.MaxMemory),
+		decisions:  decisions,
+		lastSwitch: time.Now(),
+	}
+	return o
+}
+
+// Initialize profiles each of the policies of the backend and switches to
+// the one with the lowest miss ratio. A sharedBackend is not profiled, the
+// orchestrator starts from the policy it is using and simulates the
+// candidates.
+func (o *Orchestrator) Initialize() error {
+	if err := o.aggregator.initialize(); err != nil {
+		return fmt.Errorf("failed to initialize metrics aggregator: %w", err)
+	}
+
+	if shared, ok := o.backend.(sharedBackend); ok {
+		current, err := shared.CurrentPolicy()
+		if err != nil {
+			return fmt.Errorf("failed to read the current policy: %w", err)
+		}
+		o.shadow = newShadow(o.aggregator.configuredPolicies(), shared.SimulatedPolicy)
+		o.started(current)
+
+		log.Printf("[Orchestrator] Starting from the current policy of %s without profiling: %s", o.backend.Name(), current)
+		log.Printf("[Orchestrator] Candidates simulated from the reads of the cache: %v, other policies can only be pinned", o.shadow.policies())
+		return nil
+	}
+
+	if err := o.aggregator.profile(); err != nil {
+		return fmt.Errorf("fast profiling failed: %w", err)
+	}
+
+	best := o.aggregator.best()
+	if len(best) == 0 {
+		return fmt.Errorf("no suitable initial policy found")
+	}
+
+	if err := o.backend.SetPolicy(best); err != nil {
+		return fmt.Errorf("failed to set initial policy: %w", err)
+	}
+	o.started(best)
+
+	log.Printf("[Orchestrator] Initial policy: %s", best)
+	return nil
+}
+
+// started records the policy the orchestrator starts from
+func (o *Orchestrator) started(policy string) {
+	o.lock.Lock()
+	o.policies = o.aggregator.configuredPolicies()
+	o.currentPolicy = policy
+	o.lastSwitch = time.Now()
+	o.lock.Unlock()
+
+	o.analyzer.SetThreshold(o.config.SwitchThreshold)
+}
+
+// Run evaluates the policies on each interval until ctx is done
+func (o *Orchestrator) Run(ctx context.Context) {
+	log.Printf("[Orchestrator] Starting evaluation loop (interval: %v)", o.config.EvaluationInterval)
+
+	ticker := time.NewTicker(o.config.EvaluationInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			o.evaluate(false)
+		}
+	}
+}
+
+// RecordAccess records a read of the cache for the detection of shifts in
+// the pattern of access, and replays it against the simulated candidates
+// of a sharedBackend
+func (o *Orchestrator) RecordAccess(key string, hit bool) {
+	o.detector.RecordAccess(key, hit)
+	if o.shadow != nil {
+		o.shadow.access(key)
+	}
+}
+
+// CurrentPolicy returns the policy in use
+func (o *Orchestrator) CurrentPolicy() string {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+
+	return o.currentPolicy
+}
+
+// Switches returns the number of policy switches and the time of the last
+// one, or of the initial choice of policy
+func (o *Orchestrator) Switches() (int, time.Time) {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+
+	return o.switches, o.lastSwitch
+}
+
+// Metrics returns the miss ratio last measured for each policy, which for
+// a sharedBackend is simulated for each policy which can be
+func (o *Orchestrator) Metrics() *adaptive.AggregatedMetrics {
+	return o.aggregator.aggregated()
+}
+
+// Pin switches to policy, if it is not in use, and stops the orchestrator
+// from switching away from it until Unpin is called
+func (o *Orchestrator) Pin(policy string) error {
+	if !o.configured(policy) {
+		return fmt.Errorf("%w: %s", ErrUnknownPolicy, policy)
+	}
+
+	o.evaluating.Lock()
+	defer o.evaluating.Unlock()
+
+	current := o.CurrentPolicy()
+	record := &Decision{
+		Timestamp:  time.Now(),
+		Trigger:    triggerOperator,
+		Decision:   decisionPin,
+		FromPolicy: current,
+		ToPolicy:   policy,
+	}
+	defer o.decisions.add(record)
+
+	if current != policy {
+		if err := o.backend.SetPolicy(policy); err != nil {
+			record.Error = err.Error()
+			return fmt.Errorf("unable to switch to %s: %w", policy, err)
+		}
+		o.switched(policy)
+	}
+
+	o.lock.Lock()
+	o.pinned = policy
+	o.lock.Unlock()
+
+	log.Printf("[Orchestrator] Policy pinned: %s", policy)
+	return nil
+}
+
+// Unpin lets the orchestrator switch away from a pinned policy
+func (o *Orchestrator) Unpin() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if len(o.pinned) > 0 {
+		o.pinned = ""
+		o.recordAction(decisionUnpin)
+	}
+}
+
+// Pause stops automatic switching, evaluations continue to be made
+func (o *Orchestrator) Pause() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if !o.paused {
+		o.paused = true
+		o.recordAction(decisionPause)
+	}
+}
+
+// Resume restarts automatic switching after Pause
+func (o *Orchestrator) Resume() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.paused {
+		o.paused = false
+		o.recordAction(decisionResume)
+	}
+}
+
+// Decisions returns the decisions selected by filter, oldest first
+func (o *Orchestrator) Decisions(filter DecisionFilter) []Decision {
+	return o.decisions.List(filter)
+}
+
+// recordAction records an operator's action, o.lock must be held
+func (o *Orchestrator) recordAction(action string) {
+	o.decisions.add(&Decision{
+		Timestamp:  time.Now(),
+		Trigger:    triggerOperator,
+		Decision:   action,
+		FromPolicy: o.currentPolicy,
+	})
+}
+
+// Evaluate compares the candidate policies straight away, regardless of
+// the stability period and of whether the pattern of access has shifted,
+// and switches when the best of them is worth it and switching is neither
+// paused nor pinned
+func (o *Orchestrator) Evaluate() {
+	o.evaluate(true)
+}
+
+// configured returns true when policy is one of the policies of the cache
+func (o *Orchestrator) configured(policy string) bool {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+
+	for _, configured := range o.policies {
+		if configured == policy {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluate collects the metrics of the current policy and, outside of the
+// stability period which follows a switch, compares the candidates when
+// the pattern of access has shifted or the miss ratio is degrading. A
+// forced evaluation always compares the candidates.
+func (o *Orchestrator) evaluate(force bool) {
+	o.evaluating.Lock()
+	defer o.evaluating.Unlock()
+
+	o.lock.RLock()
+	currentPolicy := o.currentPolicy
+	sinceSwitch := time.Since(o.lastSwitch)
+	o.lock.RUnlock()
+
+	if !force && sinceSwitch < o.config.StabilityPeriod {
+		log.Printf("[Orchestrator] In stability period (%.0fs / %.0fs) - skipping evaluation",
+			sinceSwitch.Seconds(), o.config.StabilityPeriod.Seconds())
+		return
+	}
+
+	snapshot, err := o.aggregator.collect()
+	if err != nil {
+		log.Printf("[Orchestrator] Failed to collect metrics: %v", err)
+		return
+	}
+	simulated := o.simulate(snapshot)
+
+	shift := o.detector.DetectShift()
+	trend := o.trend.AnalyzeTrend(o.aggregator.snapshots(), currentPolicy)
+
+	var analysis *adaptive.CostBenefitAnalysis
+	decision := o.decide(force, shift, trend, func() *adaptive.CostBenefitAnalysis {
+		candidates, currentMissRatio := o.candidates(currentPolicy, snapshot, simulated)
+		analysis = o.analyzer.CompareMultipleCandidates(currentPolicy, currentMissRatio, candidates, snapshot.CacheSize)
+		return analysis
+	})
+
+	o.lock.Lock()
+	o.lastEvaluation = time.Now()
+	o.lastShift = shift
+	o.lastTrend = trend
+	if analysis != nil {
+		o.lastAnalysis = analysis
+	}
+	o.lastDecision = decision
+	o.lock.Unlock()
+
+	record := newDecision(trigger(force, shift, trend), decision, currentPolicy, snapshot.MissRatio, shift, trend, analysis)
+	if decision == decisionSwitch {
+		if err := o.switchPolicy(analysis); err != nil {
+			record.Error = err.Error()
+		}
+	}
+	o.decisions.add(record)
+}
+
+// simulate returns the simulated miss ratio of each candidate of a
+// sharedBackend over the reads since the last evaluation, and stores them
+// as the metrics of those policies. nil is returned for a backend which is
+// profiled.
+func (o *Orchestrator) simulate(snapshot *adaptive.MetricsSnapshot) map[string]float64 {
+	if o.shadow == nil {
+		return nil
+	}
+
+	simulated := o.shadow.collect(snapshot)
+	for policy, missRatio := range simulated {
+		o.aggregator.store(policy, missRatio)
+	}
+	return simulated
+}
+
+// candidates returns the miss ratio of each candidate and that of the
+// policy in use to compare them with. The candidates of a sharedBackend
+// are those which are simulated, and the policy in use is compared by its
+// simulated miss ratio unless it can not be simulated. Otherwise each
+// policy has the miss ratio measured when it was last in use.
+func (o *Orchestrator) candidates(currentPolicy string, snapshot *adaptive.MetricsSnapshot, simulated map[string]float64) (map[string]float64, float64) {
+	if o.shadow != nil {
+		if missRatio, ok := simulated[currentPolicy]; ok {
+			return simulated, missRatio
+		}
+		return simulated, snapshot.MissRatio
+	}
+
+	aggregated := o.aggregator.aggregated()
+	candidates := make(map[string]float64, len(aggregated.AllPoliciesMetrics))
+	for name, metrics := range aggregated.AllPoliciesMetrics {
+		candidates[name] = metrics.MissRatio
+	}
+	return candidates, snapshot.MissRatio
+}
+
+// Decisions of an evaluation
+const (
+	decisionStable       = "stable"
+	decisionNoCandidate  = "no candidate"
+	decisionInsufficient = "insufficient benefit"
+	decisionPinned       = "pinned"
+	decisionPaused       = "paused"
+	decisionSwitch       = "switch"
+)
+
+// decide returns the outcome of an evaluation, analyze is only called
+// when the candidates are to be compared
+func (o *Orchestrator) decide(force bool, shift *adaptive.PatternShift, trend *adaptive.TrendScore, analyze func() *adaptive.CostBenefitAnalysis) string {
+	if !force && !shift.Detected && !trend.IndicatesDegradation {
+		return decisionStable
+	}
+	if shift.Detected {
+		log.Printf("[Orchestrator] Pattern shift detected (temporal: %.3f, frequency: %.3f)",
+			shift.TemporalShift, shift.FrequencyShift)
+	}
+	if trend.IndicatesDegradation {
+		log.Printf("[Orchestrator] Performance degradation detected (slope: %.6f, variance: %.6f)",
+			trend.Slope, trend.Variance)
+	}
+
+	analysis := analyze()
+	if analysis == nil {
+		log.Println("[Orchestrator] No suitable candidate found")
+		return decisionNoCandidate
+	}
+	if !analysis.ShouldSwitch {
+		log.Printf("[Orchestrator] Best candidate (%s) has insufficient net benefit (%.2f%% < %.2f%%)",
+			analysis.CandidatePolicy, analysis.NetBenefit*100, o.config.SwitchThreshold*100)
+		return decisionInsufficient
+	}
+
+	o.lock.RLock()
+	pinned, paused := o.pinned, o.paused
+	o.lock.RUnlock()
+
+	if len(pinned) > 0 {
+		log.Printf("[Orchestrator] Not switching to %s, %s is pinned", analysis.CandidatePolicy, pinned)
+		return decisionPinned
+	}
+	if paused {
+		log.Printf("[Orchestrator] Not switching to %s, switching is paused", analysis.CandidatePolicy)
+		return decisionPaused
+	}
+	return decisionSwitch
+}
+
+// switchPolicy changes the policy of the cache to the candidate of the
+// analysis
+func (o *Orchestrator) switchPolicy(analysis *adaptive.CostBenefitAnalysis) error {
+	log.Printf("[Orchestrator] Switching policy: %s → %s (net benefit: %.2f%%)",
+		analysis.CurrentPolicy, analysis.CandidatePolicy, analysis.NetBenefit*100)
+
+	if err := o.backend.SetPolicy(analysis.CandidatePolicy); err != nil {
+		log.Printf("[Orchestrator] Switch failed: %v", err)
+		return err
+	}
+	o.switched(analysis.CandidatePolicy)
+	return nil
+}
+
+// switched records a switch to policy
+func (o *Orchestrator) switched(policy string) {
+	o.lock.Lock()
+	o.currentPolicy = policy
+	o.lastSwitch = time.Now()
+	o.switches++
+	o.lock.Unlock()
+
+	// the accesses under the previous policy are no longer a baseline
+	o.detector.Clear()
+}
@@ -0,0 +1,234 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package orchestrator
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/danenherdi/faas-provider/pkg/adaptive"
+)
+
+// fakeBackend switches between policies whose miss ratios are fixed
+type fakeBackend struct {
+	policies   []string
+	missRatios map[string]float64
+	// err fails every switch
+	err error
+	// keys is the number of keys the cache reports
+	keys uint64
+
+	policy string
+	set    []string
+}
+
+func (f *fakeBackend) Name() string                { return "fake" }
+func (f *fakeBackend) Policies() ([]string, error) { return f.policies, nil }
+
+func (f *fakeBackend) SetPolicy(policy string) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.policy = policy
+	f.set = append(f.set, policy)
+	return nil
+}
+
+func (f *fakeBackend) Stats() (*BackendStats, error) {
+	return &BackendStats{Policy: f.policy, MissRatio: f.missRatios[f.policy], MaxSize: 1024 * 1024, NumObjects: f.keys}, nil
+}
+
+func Test_Orchestrator_Initialize(t *testing.T) {
+	backend := &fakeBackend{
+		policies:   []string{"allkeys-lru", "allkeys-lfu", "volatile-ttl"},
+		missRatios: map[string]float64{"allkeys-lru": 0.4, "allkeys-lfu": 0.2, "volatile-ttl": 0.5},
+	}
+	o := New(backend, nil, nil)
+	o.aggregator.wait = 0
+
+	if err := o.Initialize(); err != nil {
+		t.Fatalf("want no error, got: %s", err)
+	}
+	if backend.policy != "allkeys-lfu" || o.CurrentPolicy() != "allkeys-lfu" {
+		t.Fatalf("want the policy with the lowest miss ratio, got: %s", backend.policy)
+	}
+	if !o.configured("volatile-ttl") {
+		t.Errorf("want the policies of the backend to be configured")
+	}
+
+	status := o.Status()
+	if len(status.Candidates) != 3 || status.Candidates[0].Policy != "allkeys-lfu" || status.Candidates[0].MissRatio != 0.2 {
+		t.Errorf("want each policy to be profiled, got: %+v", status.Candidates)
+	}
+
+	o.Evaluate()
+	if status := o.Status(); status.LastEvaluation == nil || status.LastEvaluation.Decision != decisionInsufficient {
+		t.Errorf("want the other policies to be no better, got: %+v", status.LastEvaluation)
+	}
+}
+
+// sharedFakeBackend is a fakeBackend which is not profiled
+type sharedFakeBackend struct {
+	*fakeBackend
+	currentErr error
+}
+
+func (f sharedFakeBackend) CurrentPolicy() (string, error) {
+	return f.policy, f.currentErr
+}
+
+func (f sharedFakeBackend) SimulatedPolicy(policy string) (string, bool) {
+	name, ok := redisSimulatedPolicies[policy]
+	return name, ok
+}
+
+func Test_Orchestrator_Initialize_Shared(t *testing.T) {
+	backend := &fakeBackend{
+		policies:   []string{"allkeys-lru", "allkeys-lfu"},
+		missRatios: map[string]float64{"allkeys-lru": 0.4, "allkeys-lfu": 0.2},
+		policy:     "allkeys-lru",
+	}
+	o := New(sharedFakeBackend{fakeBackend: backend}, nil, nil)
+
+	if err := o.Initialize(); err != nil {
+		t.Fatalf("want no error, got: %s", err)
+	}
+	if len(backend.set) != 0 || o.CurrentPolicy() != "allkeys-lru" {
+		t.Fatalf("want to start from the current policy without switching, got: %s after %v", o.CurrentPolicy(), backend.set)
+	}
+	if !o.configured("allkeys-lfu") {
+		t.Errorf("want the policies of the backend to be configured")
+	}
+
+	o = New(sharedFakeBackend{fakeBackend: backend, currentErr: errors.New("CONFIG is disabled")}, nil, nil)
+	if err := o.Initialize(); err == nil {
+		t.Fatalf("want an error when the policy can not be managed")
+	}
+}
+
+func Test_Orchestrator_evaluate_SharedSimulatesCandidates(t *testing.T) {
+	// volatile-ttl reports the lowest miss ratio but can not be simulated
+	backend := &fakeBackend{
+		policies:   []string{"allkeys-lru", "allkeys-lfu", "volatile-ttl"},
+		missRatios: map[string]float64{"allkeys-lru": 0.4, "allkeys-lfu": 0.4, "volatile-ttl": 0.1},
+		policy:     "allkeys-lru",
+		keys:       2,
+	}
+	o := New(sharedFakeBackend{fakeBackend: backend}, nil, nil)
+	if err := o.Initialize(); err != nil {
+		t.Fatalf("want no error, got: %s", err)
+	}
+
+	// the first evaluation sizes the simulated caches
+	o.evaluate(true)
+	if len(backend.set) != 0 {
+		t.Fatalf("want no switch without simulated reads, got: %v", backend.set)
+	}
+
+	// a hot key among a scan which is larger than the cache is kept by
+	// LFU, while LRU misses every read of the scan
+	for i := 0; i < 4; i++ {
+		o.RecordAccess("hot", false)
+	}
+	for i := 0; i < 10; i++ {
+		for _, key := range []string{"a", "b", "hot"} {
+			o.RecordAccess(key, false)
+		}
+	}
+
+	o.evaluate(true)
+	if len(backend.set) != 1 || backend.policy != "allkeys-lfu" {
+		t.Fatalf("want a switch to the policy with the lowest simulated miss ratio, got: %v", backend.set)
+	}
+	if metrics := o.Metrics().AllPoliciesMetrics; metrics["allkeys-lfu"] == nil || metrics["allkeys-lfu"].MissRatio >= metrics["allkeys-lru"].MissRatio {
+		t.Errorf("want the simulated miss ratios as metrics, got: %+v", metrics)
+	}
+}
+
+func Test_Orchestrator_decide(t *testing.T) {
+	worthIt := &adaptive.CostBenefitAnalysis{CurrentPolicy: "lru", CandidatePolicy: "lfu", NetBenefit: 0.2, ShouldSwitch: true}
+	notWorthIt := &adaptive.CostBenefitAnalysis{CurrentPolicy: "lru", CandidatePolicy: "lfu", NetBenefit: 0.01}
+
+	cases := []struct {
+		name     string
+		force    bool
+		shift    bool
+		pinned   string
+		paused   bool
+		analysis *adaptive.CostBenefitAnalysis
+		want     string
+	}{
+		{name: "no shift or degradation", analysis: worthIt, want: decisionStable},
+		{name: "forced", force: true, analysis: worthIt, want: decisionSwitch},
+		{name: "shift", shift: true, analysis: worthIt, want: decisionSwitch},
+		{name: "no candidate", shift: true, want: decisionNoCandidate},
+		{name: "insufficient benefit", shift: true, analysis: notWorthIt, want: decisionInsufficient},
+		{name: "pinned", force: true, pinned: "lru", analysis: worthIt, want: decisionPinned},
+		{name: "paused", shift: true, paused: true, analysis: worthIt, want: decisionPaused},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			o := New(nil, nil, nil)
+			o.pinned = tc.pinned
+			o.paused = tc.paused
+
+			analyzed := false
+			got := o.decide(tc.force, &adaptive.PatternShift{Detected: tc.shift}, &adaptive.TrendScore{}, func() *adaptive.CostBenefitAnalysis {
+				analyzed = true
+				return tc.analysis
+			})
+
+			if got != tc.want {
+				t.Errorf("want decision %q, got %q", tc.want, got)
+			}
+			if analyzed != (tc.want != decisionStable) {
+				t.Errorf("want the candidates to be compared only when the workload has changed or the evaluation is forced")
+			}
+		})
+	}
+}
+
+func Test_Orchestrator_Pin(t *testing.T) {
+	backend := &fakeBackend{}
+	o := New(backend, nil, nil)
+	o.policies = []string{"lru", "lfu"}
+	o.currentPolicy = "lru"
+
+	if err := o.Pin("arc"); !errors.Is(err, ErrUnknownPolicy) {
+		t.Fatalf("want ErrUnknownPolicy, got: %v", err)
+	}
+
+	if err := o.Pin("lfu"); err != nil {
+		t.Fatalf("want no error, got: %s", err)
+	}
+	if err := o.Pin("lfu"); err != nil {
+		t.Fatalf("want no error, got: %s", err)
+	}
+
+	if len(backend.set) != 1 || backend.set[0] != "lfu" {
+		t.Errorf("want one switch to lfu, got: %v", backend.set)
+	}
+	if status := o.Status(); status.CurrentPolicy != "lfu" || status.Pinned != "lfu" || status.Switches != 1 {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	o.Unpin()
+	if status := o.Status(); status.Pinned != "" {
+		t.Errorf("want no pinned policy, got: %s", status.Pinned)
+	}
+}
+
+func Test_Orchestrator_Pin_SwitchFails(t *testing.T) {
+	o := New(&fakeBackend{err: errors.New("connection refused")}, nil, nil)
+	o.policies = []string{"lru", "lfu"}
+	o.currentPolicy = "lru"
+
+	if err := o.Pin("lfu"); err == nil {
+		t.Fatalf("want an error")
+	}
+	if status := o.Status(); status.CurrentPolicy != "lru" || status.Pinned != "" {
+		t.Errorf("want the policy to be unchanged, got: %+v", status)
+	}
+}
@@ -0,0 +1,325 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package orchestrator
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisTimeout bounds each call made by RedisBackend
+const redisTimeout = 10 * time.Second
+
+// RedisBackend drives Redis by setting maxmemory-policy with CONFIG SET.
+// Redis counts keyspace hits and misses since it started, so the miss
+// ratio is measured over the reads since the policy was last set or the
+// stats were last read. In cluster mode every master is set and the
+// counters of the masters are summed. Redis is not profiled on start, see
+// sharedBackend.
+type RedisBackend struct {
+	client   redis.UniversalClient
+	policies []string
+
+	// read returns the counters of the servers, it is replaced in tests
+	read func(ctx context.Context) (redisCounters, error)
+	// current returns the policy of the servers, it is replaced in tests
+	current func(ctx context.Context) (string, error)
+
+	lock sync.Mutex
+	// hits and misses are the counters at the start of the window
+	hits   uint64
+	misses uint64
+	// missRatio is the last miss ratio measured, it is reported for a
+	// window without reads
+	missRatio float64
+}
+
+// redisCounters are the fields of INFO used by RedisBackend, summed over
+// the servers
+type redisCounters struct {
+	policy     string
+	hits       uint64
+	misses     uint64
+	usedMemory uint64
+	maxMemory  uint64
+	keys       uint64
+	// unbounded is true when a server has no maxmemory, it then never
+	// evicts whatever its policy
+	unbounded bool
+}
+
+// redisSimulatedPolicies maps the policies of Redis to those of cachesim
+// which approximate them. Flow responses are always cached with a TTL, so
+// the volatile- policies act as their allkeys- counterparts. Redis samples
+// the keys it evicts rather than keeping an exact order, which the
+// simulation does not. The random and ttl policies are not simulated.
+var redisSimulatedPolicies = map[string]string{
+	"allkeys-lru":  "lru",
+	"allkeys-lfu":  "lfu",
+	"volatile-lru": "lru",
+	"volatile-lfu": "lfu",
+}
+
+// NewRedisBackend creates a Backend which switches a Redis connection
+// between policies, each a value of maxmemory-policy
+func NewRedisBackend(client redis.UniversalClient, policies []string) *RedisBackend {
+	r := &RedisBackend{
+		client:   client,
+		policies: append([]string{}, policies...),
+	}
+	r.read = r.readCounters
+	r.current = r.readPolicy
+	return r
+}
+
+// Name implements Backend
+func (r *RedisBackend) Name() string {
+	return "Redis"
+}
+
+// Policies implements Backend. An error is returned when maxmemory is not
+// set, as Redis does not evict and there is nothing to choose between.
+func (r *RedisBackend) Policies() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	counters, err := r.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if counters.unbounded {
+		return nil, fmt.Errorf("maxmemory is not set, Redis does not evict")
+	}
+	if len(r.policies) < 2 {
+		return nil, fmt.Errorf("at least two policies are needed, got: %v", r.policies)
+	}
+
+	return append([]string{}, r.policies...), nil
+}
+
+// SetPolicy implements Backend, the miss ratio is measured afresh from
+// the switch
+func (r *RedisBackend) SetPolicy(policy string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	err := r.forEachServer(ctx, func(ctx context.Context, client redis.Cmdable) error {
+		return client.ConfigSet(ctx, "maxmemory-policy", policy).Err()
+	})
+	if err != nil {
+		return err
+	}
+
+	counters, err := r.read(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.lock.Lock()
+	r.hits, r.misses = counters.hits, counters.misses
+	r.lock.Unlock()
+
+	return nil
+}
+
+// CurrentPolicy implements sharedBackend. Managed services often disable
+// CONFIG or rename it, in which case the policy can not be changed and an
+// error is returned.
+func (r *RedisBackend) CurrentPolicy() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	policy, err := r.current(ctx)
+	if err != nil {
+		if configUnavailable(err) {
+			return "", fmt.Errorf("maxmemory-policy can not be managed, CONFIG is disabled, renamed or not allowed: %w", err)
+		}
+		return "", fmt.Errorf("unable to read maxmemory-policy: %w", err)
+	}
+
+	counters, err := r.read(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	r.lock.Lock()
+	r.hits, r.misses = counters.hits, counters.misses
+	r.lock.Unlock()
+
+	return policy, nil
+}
+
+// SimulatedPolicy implements sharedBackend
+func (r *RedisBackend) SimulatedPolicy(policy string) (string, bool) {
+	name, ok := redisSimulatedPolicies[policy]
+	return name, ok
+}
+
+// Stats implements Backend
+func (r *RedisBackend) Stats() (*BackendStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	counters, err := r.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.lock.Lock()
+	// the counters go back to zero on a restart or CONFIG RESETSTAT
+	if counters.hits < r.hits || counters.misses < r.misses {
+		r.hits, r.misses = 0, 0
+	}
+	hits, misses := counters.hits-r.hits, counters.misses-r.misses
+	if hits+misses > 0 {
+		r.missRatio = float64(misses) / float64(hits+misses)
+	}
+	r.hits, r.misses = counters.hits, counters.misses
+	missRatio := r.missRatio
+	r.lock.Unlock()
+
+	maxSize := counters.maxMemory
+	if maxSize == 0 {
+		maxSize = counters.usedMemory
+	}
+
+	return &BackendStats{
+		Policy:     counters.policy,
+		MissRatio:  missRatio,
+		MaxSize:    maxSize,
+		UsedSize:   counters.usedMemory,
+		NumObjects: counters.keys,
+		TotalGets:  counters.hits + counters.misses,
+	}, nil
+}
+
+// readPolicy reads maxmemory-policy with CONFIG GET from each server, the
+// policy of the first server to answer is returned. Nothing is written to
+// the servers, so a server which allows CONFIG GET but not CONFIG SET is
+// only found out by the first switch, which then fails and is recorded
+// with its error.
+func (r *RedisBackend) readPolicy(ctx context.Context) (string, error) {
+	var lock sync.Mutex
+	policy := ""
+
+	err := r.forEachServer(ctx, func(ctx context.Context, client redis.Cmdable) error {
+		config, err := client.ConfigGet(ctx, "maxmemory-policy").Result()
+		if err != nil {
+			return err
+		}
+		server, ok := config["maxmemory-policy"]
+		if !ok {
+			return fmt.Errorf("CONFIG GET returned no maxmemory-policy")
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+		if len(policy) == 0 {
+			policy = server
+		}
+		return nil
+	})
+
+	return policy, err
+}
+
+// configUnavailable returns true when err shows that CONFIG can not be
+// used: Redis answers that the command is unknown when it is disabled or
+// renamed, and NOPERM when an ACL does not allow it
+func configUnavailable(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "unknown command") || strings.HasPrefix(msg, "NOPERM")
+}
+
+// readCounters reads INFO from each server
+func (r *RedisBackend) readCounters(ctx context.Context) (redisCounters, error) {
+	var lock sync.Mutex
+	counters := redisCounters{}
+
+	err := r.forEachServer(ctx, func(ctx context.Context, client redis.Cmdable) error {
+		info, err := client.Info(ctx).Result()
+		if err != nil {
+			return err
+		}
+		server := parseRedisInfo(info)
+
+		lock.Lock()
+		defer lock.Unlock()
+		counters.add(server)
+		return nil
+	})
+
+	return counters, err
+}
+
+// forEachServer calls fn for the server, or for each master of a cluster
+func (r *RedisBackend) forEachServer(ctx context.Context, fn func(ctx context.Context, client redis.Cmdable) error) error {
+	cluster, ok := r.client.(*redis.ClusterClient)
+	if !ok {
+		return fn(ctx, r.client)
+	}
+
+	return cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		return fn(ctx, node)
+	})
+}
+
+// add sums the counters of another server, the policy is that of the
+// first server
+func (c *redisCounters) add(server redisCounters) {
+	if len(c.policy) == 0 {
+		c.policy = server.policy
+	}
+	c.hits += server.hits
+	c.misses += server.misses
+	c.usedMemory += server.usedMemory
+	c.maxMemory += server.maxMemory
+	c.keys += server.keys
+	c.unbounded = c.unbounded || server.unbounded
+}
+
+// parseRedisInfo reads the counters from the output of INFO, which is a
+// line of field:value for each field, and db<n>:keys=<n>,expires=... for
+// each database in the keyspace section
+func parseRedisInfo(info string) redisCounters {
+	counters := redisCounters{}
+
+	scanner := bufio.NewScanner(strings.NewReader(info))
+	for scanner.Scan() {
+		field, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !ok || strings.HasPrefix(field, "#") {
+			continue
+		}
+
+		switch {
+		case field == "keyspace_hits":
+			counters.hits, _ = strconv.ParseUint(value, 10, 64)
+		case field == "keyspace_misses":
+			counters.misses, _ = strconv.ParseUint(value, 10, 64)
+		case field == "used_memory":
+			counters.usedMemory, _ = strconv.ParseUint(value, 10, 64)
+		case field == "maxmemory":
+			counters.maxMemory, _ = strconv.ParseUint(value, 10, 64)
+			counters.unbounded = counters.maxMemory == 0
+		case field == "maxmemory_policy":
+			counters.policy = value
+		case strings.HasPrefix(field, "db"):
+			for _, pair := range strings.Split(value, ",") {
+				if name, keys, ok := strings.Cut(pair, "="); ok && name == "keys" {
+					n, _ := strconv.ParseUint(keys, 10, 64)
+					counters.keys += n
+				}
+			}
+		}
+	}
+
+	return counters
+}
@@ -0,0 +1,127 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func Test_parseRedisInfo(t *testing.T) {
+	info := "# Memory\r\n" +
+		"used_memory:1048576\r\n" +
+		"used_memory_human:1.00M\r\n" +
+		"maxmemory:67108864\r\n" +
+		"maxmemory_policy:allkeys-lfu\r\n" +
+		"\r\n" +
+		"# Stats\r\n" +
+		"keyspace_hits:75\r\n" +
+		"keyspace_misses:25\r\n" +
+		"\r\n" +
+		"# Keyspace\r\n" +
+		"db0:keys=10,expires=10,avg_ttl=5000\r\n" +
+		"db1:keys=5,expires=0,avg_ttl=0\r\n"
+
+	got := parseRedisInfo(info)
+	want := redisCounters{policy: "allkeys-lfu", hits: 75, misses: 25, usedMemory: 1048576, maxMemory: 67108864, keys: 15}
+	if got != want {
+		t.Fatalf("want %+v, got %+v", want, got)
+	}
+
+	if !parseRedisInfo("maxmemory:0\r\n").unbounded {
+		t.Errorf("want a server without maxmemory to be unbounded")
+	}
+}
+
+func Test_RedisBackend_Stats(t *testing.T) {
+	counters := redisCounters{policy: "allkeys-lru", hits: 100, misses: 100, maxMemory: 1024}
+	r := NewRedisBackend(nil, []string{"allkeys-lru", "allkeys-lfu"})
+	r.read = func(ctx context.Context) (redisCounters, error) {
+		return counters, nil
+	}
+
+	stats, err := r.Stats()
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err)
+	}
+	if stats.Policy != "allkeys-lru" || stats.MissRatio != 0.5 || stats.MaxSize != 1024 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	// only the reads since the last stats count
+	counters.hits, counters.misses = 190, 110
+	if stats, _ := r.Stats(); stats.MissRatio != 0.1 {
+		t.Fatalf("want the miss ratio of the window, got: %v", stats.MissRatio)
+	}
+
+	// a window without reads keeps the last ratio
+	if stats, _ := r.Stats(); stats.MissRatio != 0.1 {
+		t.Fatalf("want the last miss ratio, got: %v", stats.MissRatio)
+	}
+
+	// the counters are reset by a restart
+	counters.hits, counters.misses = 1, 3
+	if stats, _ := r.Stats(); stats.MissRatio != 0.75 {
+		t.Fatalf("want the miss ratio since the reset, got: %v", stats.MissRatio)
+	}
+}
+
+func Test_RedisBackend_Policies(t *testing.T) {
+	counters := redisCounters{maxMemory: 1024}
+	r := NewRedisBackend(nil, []string{"allkeys-lru", "allkeys-lfu"})
+	r.read = func(ctx context.Context) (redisCounters, error) {
+		return counters, nil
+	}
+
+	if policies, err := r.Policies(); err != nil || len(policies) != 2 {
+		t.Fatalf("want the configured policies, got: %v %v", policies, err)
+	}
+
+	counters = redisCounters{unbounded: true}
+	if _, err := r.Policies(); err == nil {
+		t.Fatalf("want an error when maxmemory is not set")
+	}
+}
+
+func Test_RedisBackend_CurrentPolicy(t *testing.T) {
+	counters := redisCounters{policy: "allkeys-lfu", hits: 100, misses: 100, maxMemory: 1024}
+	r := NewRedisBackend(nil, []string{"allkeys-lru", "allkeys-lfu"})
+	r.read = func(ctx context.Context) (redisCounters, error) {
+		return counters, nil
+	}
+	r.current = func(ctx context.Context) (string, error) {
+		return "allkeys-lfu", nil
+	}
+
+	if policy, err := r.CurrentPolicy(); err != nil || policy != "allkeys-lfu" {
+		t.Fatalf("want the policy in use, got: %q %v", policy, err)
+	}
+
+	// the miss ratio is measured from the start
+	counters.hits, counters.misses = 190, 110
+	if stats, _ := r.Stats(); stats.MissRatio != 0.1 {
+		t.Fatalf("want the miss ratio since the start, got: %v", stats.MissRatio)
+	}
+
+	for _, msg := range []string{
+		"ERR unknown command 'CONFIG', with args beginning with: 'GET' 'maxmemory-policy'",
+		"NOPERM User flows has no permissions to run the 'config|get' command",
+	} {
+		r.current = func(ctx context.Context) (string, error) {
+			return "", errors.New(msg)
+		}
+		if _, err := r.CurrentPolicy(); err == nil || !strings.Contains(err.Error(), "CONFIG is disabled, renamed or not allowed") {
+			t.Errorf("want an error when CONFIG can not be used, got: %v", err)
+		}
+	}
+
+	r.current = func(ctx context.Context) (string, error) {
+		return "", errors.New("dial tcp: connection refused")
+	}
+	if _, err := r.CurrentPolicy(); err == nil || strings.Contains(err.Error(), "CONFIG is disabled") {
+		t.Errorf("want a connection error not to be reported as CONFIG being unavailable, got: %v", err)
+	}
+}
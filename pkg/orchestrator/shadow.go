@@ -0,0 +1,146 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package orchestrator
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/danenherdi/faas-provider/pkg/adaptive"
+	"github.com/openfaas/faas-netes/pkg/cachesim"
+)
+
+// maxShadowEntries bounds the simulated caches of a sharedBackend, a larger
+// cache is simulated as a cache of this many entries
+const maxShadowEntries = 100000
+
+// shadow measures the candidates of a sharedBackend, which is not
+// profiled, by replaying the reads recorded by the orchestrator against a
+// simulated cache for each policy which cachesim can approximate. Every
+// simulated policy, the one in use included, is measured in the same way,
+// so that their miss ratios can be compared with each other. A policy
+// which can not be simulated is never a candidate, it can only be pinned.
+type shadow struct {
+	// simulated maps each policy of the backend to the policy of cachesim
+	// which approximates it
+	simulated map[string]string
+
+	lock sync.Mutex
+	// caches, window and missRatios are keyed by the policy of cachesim,
+	// as more than one policy of the backend may share it
+	caches map[string]cachesim.Cache
+	// window counts the reads since the last collect
+	window map[string]*simulatedCounts
+	// missRatios are the last miss ratios measured, they are kept for a
+	// window without reads
+	missRatios map[string]float64
+}
+
+// newShadow creates a shadow for the policies which simulate maps to a
+// policy of cachesim, its caches are created by the first collect
+func newShadow(policies []string, simulate func(policy string) (string, bool)) *shadow {
+	s := &shadow{
+		simulated:  map[string]string{},
+		window:     map[string]*simulatedCounts{},
+		missRatios: map[string]float64{},
+	}
+	for _, policy := range policies {
+		if name, ok := simulate(policy); ok {
+			s.simulated[policy] = name
+		}
+	}
+	return s
+}
+
+// policies returns the policies of the backend which are simulated
+func (s *shadow) policies() []string {
+	policies := make([]string, 0, len(s.simulated))
+	for policy := range s.simulated {
+		policies = append(policies, policy)
+	}
+	sort.Strings(policies)
+	return policies
+}
+
+// access replays a read of key, reads are dropped until the caches have
+// been created
+func (s *shadow) access(key string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	for name, cache := range s.caches {
+		counts := s.window[name]
+		counts.total++
+		if !cache.Access(key, 1, 0, now) {
+			counts.misses++
+		}
+	}
+}
+
+// collect returns the miss ratio of each simulated policy over the reads
+// since it was last called. The caches are created on the first call for
+// which the snapshot of the backend has keys, as their capacity is
+// estimated from it.
+func (s *shadow) collect(snapshot *adaptive.MetricsSnapshot) map[string]float64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.caches == nil {
+		capacity := shadowCapacity(snapshot)
+		if capacity == 0 || len(s.simulated) == 0 {
+			return map[string]float64{}
+		}
+
+		s.caches = map[string]cachesim.Cache{}
+		for _, name := range s.simulated {
+			if _, ok := s.caches[name]; ok {
+				continue
+			}
+			cache, err := cachesim.New(name, capacity, false)
+			if err != nil {
+				log.Printf("[Orchestrator] WARNING: Unable to simulate %s: %v", name, err)
+				continue
+			}
+			s.caches[name] = cache
+			s.window[name] = &simulatedCounts{}
+		}
+		log.Printf("[Orchestrator] Simulating %v with %d entries", s.policies(), capacity)
+		return map[string]float64{}
+	}
+
+	for name, counts := range s.window {
+		if counts.total > 0 {
+			s.missRatios[name] = counts.ratio()
+		}
+		*counts = simulatedCounts{}
+	}
+
+	ratios := map[string]float64{}
+	for policy, name := range s.simulated {
+		if ratio, ok := s.missRatios[name]; ok {
+			ratios[policy] = ratio
+		}
+	}
+	return ratios
+}
+
+// shadowCapacity estimates the number of entries the cache holds once it
+// is full, from its number of keys and the share of its memory they use
+func shadowCapacity(snapshot *adaptive.MetricsSnapshot) int64 {
+	if snapshot.NumObjects == 0 {
+		return 0
+	}
+
+	capacity := float64(snapshot.NumObjects)
+	if snapshot.UsedSize > 0 && snapshot.CacheSize > snapshot.UsedSize {
+		capacity = capacity * float64(snapshot.CacheSize) / float64(snapshot.UsedSize)
+	}
+	if capacity > maxShadowEntries {
+		capacity = maxShadowEntries
+	}
+	return int64(capacity)
+}
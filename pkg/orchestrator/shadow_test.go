@@ -0,0 +1,66 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package orchestrator
+
+import (
+	"math"
+	"testing"
+
+	"github.com/danenherdi/faas-provider/pkg/adaptive"
+)
+
+func Test_shadow(t *testing.T) {
+	s := newShadow([]string{"allkeys-lru", "volatile-lru", "allkeys-lfu", "volatile-ttl"}, func(policy string) (string, bool) {
+		name, ok := redisSimulatedPolicies[policy]
+		return name, ok
+	})
+	if policies := s.policies(); len(policies) != 3 {
+		t.Fatalf("want the policies which can be simulated, got: %v", policies)
+	}
+
+	s.access("dropped")
+	if ratios := s.collect(&adaptive.MetricsSnapshot{}); len(ratios) != 0 {
+		t.Fatalf("want no miss ratios before the cache has keys, got: %v", ratios)
+	}
+	if ratios := s.collect(&adaptive.MetricsSnapshot{NumObjects: 1}); len(ratios) != 0 {
+		t.Fatalf("want no miss ratios before any read, got: %v", ratios)
+	}
+
+	for _, key := range []string{"a", "a", "b"} {
+		s.access(key)
+	}
+	ratios := s.collect(&adaptive.MetricsSnapshot{NumObjects: 1})
+	for _, policy := range []string{"allkeys-lru", "volatile-lru", "allkeys-lfu"} {
+		if math.Abs(ratios[policy]-2.0/3) > 1e-9 {
+			t.Errorf("want a miss ratio of 2/3 for %s, got: %v", policy, ratios)
+		}
+	}
+	if _, ok := ratios["volatile-ttl"]; ok {
+		t.Errorf("want no miss ratio for a policy which is not simulated")
+	}
+
+	if ratios := s.collect(&adaptive.MetricsSnapshot{NumObjects: 1}); math.Abs(ratios["allkeys-lru"]-2.0/3) > 1e-9 {
+		t.Errorf("want the last miss ratios for a window without reads, got: %v", ratios)
+	}
+}
+
+func Test_shadowCapacity(t *testing.T) {
+	cases := []struct {
+		name     string
+		snapshot adaptive.MetricsSnapshot
+		want     int64
+	}{
+		{name: "no keys", snapshot: adaptive.MetricsSnapshot{CacheSize: 1024}, want: 0},
+		{name: "scaled to the memory limit", snapshot: adaptive.MetricsSnapshot{NumObjects: 10, UsedSize: 256, CacheSize: 1024}, want: 40},
+		{name: "full", snapshot: adaptive.MetricsSnapshot{NumObjects: 10, UsedSize: 2048, CacheSize: 1024}, want: 10},
+		{name: "bounded", snapshot: adaptive.MetricsSnapshot{NumObjects: 1000, UsedSize: 1, CacheSize: 1024 * 1024}, want: maxShadowEntries},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := shadowCapacity(&c.snapshot); got != c.want {
+				t.Errorf("want %d, got: %d", c.want, got)
+			}
+		})
+	}
+}
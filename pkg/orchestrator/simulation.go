@@ -0,0 +1,320 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package orchestrator
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/danenherdi/faas-provider/pkg/adaptive"
+	"github.com/openfaas/faas-netes/pkg/cachesim"
+	"github.com/openfaas/faas-netes/pkg/cachetrace"
+)
+
+// SimulationConfig configures the replay of a trace
+type SimulationConfig struct {
+	// Orchestrator is the configuration under test, nil uses
+	// adaptive.DefaultOrchestratorConfig
+	Orchestrator *adaptive.OrchestratorConfig
+	// Weights are the weights of the time, memory and degradation costs of
+	// a switch, nil keeps those of adaptive.CostBenefitAnalyzer
+	Weights []float64
+
+	// Policies are the candidates, as given by the Backend
+	Policies []string
+	// Capacity of the simulated cache, in bytes when BySize is true and
+	// in entries otherwise
+	Capacity int64
+	BySize   bool
+
+	// InitialPolicy is used from the start of the trace. When empty the
+	// policy with the lowest miss ratio over the first evaluation interval
+	// is chosen, as Initialize does by profiling each policy.
+	InitialPolicy string
+	// RefreshCandidates measures the miss ratio of every candidate on each
+	// evaluation. The orchestrator only measures the policy in use, the
+	// others keep the miss ratio measured when they were last in use.
+	RefreshCandidates bool
+}
+
+// SimulationResult is the outcome of a replay
+type SimulationResult struct {
+	Accesses int `json:"accesses"`
+	// RecordedMissRatio is the miss ratio of the reads in the trace
+	RecordedMissRatio float64 `json:"recordedMissRatio"`
+
+	InitialPolicy string `json:"initialPolicy"`
+	FinalPolicy   string `json:"finalPolicy"`
+	Switches      int    `json:"switches"`
+	// MissRatio is the miss ratio of the simulated cache with the policy
+	// chosen by the orchestrator at each read
+	MissRatio float64 `json:"missRatio"`
+	// PolicyMissRatios is the miss ratio of each policy had it been used
+	// for the whole trace
+	PolicyMissRatios map[string]float64 `json:"policyMissRatios"`
+
+	// Decisions are recorded as by the DecisionLog, with the time of the
+	// trace
+	Decisions []Decision `json:"decisions"`
+}
+
+// Simulation replays a trace of cache reads against simulated caches, one
+// for each candidate policy, and evaluates them in the same way as the
+// orchestrator on each interval of the trace. The reads are served by the
+// cache of the policy in use, so a switch takes effect at once, without
+// the cost of reconstruction which the analysis estimates.
+type Simulation struct {
+	config       SimulationConfig
+	orchestrator *Orchestrator
+
+	caches   map[string]cachesim.Cache
+	counts   map[string]*simulatedCounts
+	active   simulatedCounts
+	recorded simulatedCounts
+
+	// values and valueBytes give the mean size of the values in the trace
+	values     int64
+	valueBytes int64
+
+	// measured is the miss ratio of each policy as the orchestrator would
+	// know it
+	measured map[string]float64
+	history  []*adaptive.MetricsSnapshot
+
+	initialPolicy  string
+	nextEvaluation time.Time
+	lastSwitch     time.Time
+	switches       int
+	decisions      []Decision
+}
+
+// simulatedCounts counts reads and misses
+type simulatedCounts struct {
+	total  int64
+	misses int64
+}
+
+func (c simulatedCounts) ratio() float64 {
+	if c.total == 0 {
+		return 0
+	}
+	return float64(c.misses) / float64(c.total)
+}
+
+// NewSimulation creates a simulation with an empty cache for each policy
+func NewSimulation(config SimulationConfig) (*Simulation, error) {
+	if len(config.Policies) < 2 {
+		return nil, fmt.Errorf("at least two policies are needed")
+	}
+	if config.Orchestrator == nil {
+		config.Orchestrator = adaptive.DefaultOrchestratorConfig()
+	}
+	if config.Orchestrator.EvaluationInterval <= 0 {
+		return nil, fmt.Errorf("evaluation interval must be greater than zero")
+	}
+
+	o := New(nil, config.Orchestrator, nil)
+	o.analyzer.SetThreshold(config.Orchestrator.SwitchThreshold)
+	if config.Weights != nil {
+		if len(config.Weights) != 3 {
+			return nil, fmt.Errorf("want weights for time, memory and degradation, got %d", len(config.Weights))
+		}
+		if err := o.analyzer.SetWeights(config.Weights[0], config.Weights[1], config.Weights[2]); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &Simulation{
+		config:       config,
+		orchestrator: o,
+		caches:       map[string]cachesim.Cache{},
+		counts:       map[string]*simulatedCounts{},
+		measured:     map[string]float64{},
+	}
+
+	for _, policy := range config.Policies {
+		cache, err := cachesim.New(policy, config.Capacity, config.BySize)
+		if err != nil {
+			return nil, err
+		}
+		s.caches[policy] = cache
+		s.counts[policy] = &simulatedCounts{}
+	}
+
+	if len(config.InitialPolicy) > 0 {
+		if _, ok := s.caches[config.InitialPolicy]; !ok {
+			return nil, fmt.Errorf("initial policy %s is not one of the policies", config.InitialPolicy)
+		}
+		o.currentPolicy = config.InitialPolicy
+		s.initialPolicy = config.InitialPolicy
+	}
+
+	return s, nil
+}
+
+// Access replays a read, evaluating the policies first on each interval
+// which has passed since the last read
+func (s *Simulation) Access(record cachetrace.Record) {
+	if s.nextEvaluation.IsZero() {
+		s.nextEvaluation = record.Time.Add(s.config.Orchestrator.EvaluationInterval)
+		s.lastSwitch = record.Time
+	}
+	for !record.Time.Before(s.nextEvaluation) {
+		s.evaluate(s.nextEvaluation)
+		s.nextEvaluation = s.nextEvaluation.Add(s.config.Orchestrator.EvaluationInterval)
+	}
+
+	s.recorded.total++
+	if !record.Hit {
+		s.recorded.misses++
+	}
+	if record.Size > 0 {
+		s.values++
+		s.valueBytes += int64(record.Size)
+	}
+
+	ttl := time.Duration(record.TTL) * time.Second
+	hits := map[string]bool{}
+	for policy, cache := range s.caches {
+		hit := cache.Access(record.Key, int64(record.Size), ttl, record.Time)
+		hits[policy] = hit
+
+		s.counts[policy].total++
+		if !hit {
+			s.counts[policy].misses++
+		}
+	}
+
+	current := s.orchestrator.currentPolicy
+	if len(current) == 0 {
+		// still profiling, the reads are counted against the policy which
+		// is chosen at the end of the interval
+		s.orchestrator.RecordAccess(record.Key, record.Hit)
+		return
+	}
+
+	s.active.total++
+	if !hits[current] {
+		s.active.misses++
+	}
+	s.orchestrator.RecordAccess(record.Key, hits[current])
+}
+
+// evaluate mirrors Orchestrator.evaluate at now, in the time of the trace
+func (s *Simulation) evaluate(now time.Time) {
+	o := s.orchestrator
+
+	if len(o.currentPolicy) == 0 {
+		s.profile(now)
+		return
+	}
+
+	if now.Sub(s.lastSwitch) < s.config.Orchestrator.StabilityPeriod {
+		return
+	}
+
+	current := o.currentPolicy
+	s.measured[current] = s.active.ratio()
+	if s.config.RefreshCandidates {
+		for policy, counts := range s.counts {
+			s.measured[policy] = counts.ratio()
+		}
+	}
+
+	s.history = append(s.history, &adaptive.MetricsSnapshot{
+		Timestamp:     now,
+		CurrentPolicy: current,
+		MissRatio:     s.active.ratio(),
+		CacheSize:     s.simulatedCacheSize(),
+	})
+	if len(s.history) > maxHistory {
+		s.history = s.history[1:]
+	}
+
+	shift := o.detector.DetectShift()
+	trend := o.trend.AnalyzeTrend(s.history, current)
+
+	var analysis *adaptive.CostBenefitAnalysis
+	decision := o.decide(false, shift, trend, func() *adaptive.CostBenefitAnalysis {
+		candidates := make(map[string]float64, len(s.measured))
+		for policy, missRatio := range s.measured {
+			candidates[policy] = missRatio
+		}
+		analysis = o.analyzer.CompareMultipleCandidates(current, s.active.ratio(), candidates, s.simulatedCacheSize())
+		return analysis
+	})
+	if decision == decisionStable {
+		return
+	}
+
+	record := newDecision(trigger(false, shift, trend), decision, current, s.active.ratio(), shift, trend, analysis)
+	record.ID = int64(len(s.decisions) + 1)
+	record.Timestamp = now
+	s.decisions = append(s.decisions, *record)
+
+	if decision == decisionSwitch {
+		o.currentPolicy = analysis.CandidatePolicy
+		o.detector.Clear()
+		s.lastSwitch = now
+		s.switches++
+	}
+}
+
+// profile chooses the policy with the lowest miss ratio over the first
+// interval, and counts the reads of the interval against it
+func (s *Simulation) profile(now time.Time) {
+	best, lowest := "", math.Inf(1)
+	for _, policy := range s.config.Policies {
+		missRatio := s.counts[policy].ratio()
+		s.measured[policy] = missRatio
+		if missRatio < lowest {
+			best, lowest = policy, missRatio
+		}
+	}
+
+	s.orchestrator.currentPolicy = best
+	s.initialPolicy = best
+	s.active = *s.counts[best]
+	s.lastSwitch = now
+}
+
+// simulatedCacheSize is the size in bytes of the simulated cache, for the
+// estimate of the cost of a switch. When the capacity is a number of
+// entries it is multiplied by the mean size of the values in the trace.
+func (s *Simulation) simulatedCacheSize() uint64 {
+	if s.config.BySize || s.values == 0 {
+		return uint64(s.config.Capacity)
+	}
+	return uint64(s.config.Capacity) * uint64(s.valueBytes/s.values)
+}
+
+// Result returns the outcome of the reads replayed so far, a trace which
+// ended within the first interval is profiled at that point
+func (s *Simulation) Result() SimulationResult {
+	result := SimulationResult{
+		Accesses:          int(s.recorded.total),
+		RecordedMissRatio: s.recorded.ratio(),
+		InitialPolicy:     s.initialPolicy,
+		FinalPolicy:       s.orchestrator.currentPolicy,
+		Switches:          s.switches,
+		MissRatio:         s.active.ratio(),
+		PolicyMissRatios:  map[string]float64{},
+		Decisions:         append([]Decision{}, s.decisions...),
+	}
+
+	for policy, counts := range s.counts {
+		result.PolicyMissRatios[policy] = counts.ratio()
+	}
+
+	// a trace shorter than an interval ends while profiling
+	if len(result.FinalPolicy) == 0 {
+		s.profile(s.nextEvaluation)
+		result.InitialPolicy = s.initialPolicy
+		result.FinalPolicy = s.initialPolicy
+		result.MissRatio = s.active.ratio()
+	}
+
+	return result
+}
@@ -0,0 +1,116 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package orchestrator
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/danenherdi/faas-provider/pkg/adaptive"
+	"github.com/openfaas/faas-netes/pkg/cachetrace"
+)
+
+// replayScans reads a small set of hot keys, then mixes in scans of keys
+// which are read once, which flush an LRU cache but not an LFU cache
+func replayScans(t *testing.T, config SimulationConfig) SimulationResult {
+	t.Helper()
+
+	simulation, err := NewSimulation(config)
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err)
+	}
+
+	random := rand.New(rand.NewSource(1))
+	now := time.Unix(1700000000, 0)
+	for i := 0; i < 20000; i++ {
+		key := fmt.Sprintf("hot-%d", random.Intn(50))
+		if i > 5000 && i%2 == 0 {
+			key = fmt.Sprintf("scan-%d", i)
+		}
+
+		now = now.Add(10 * time.Millisecond)
+		simulation.Access(cachetrace.Record{Time: now, Key: key, Hit: true})
+	}
+
+	return simulation.Result()
+}
+
+func Test_Simulation_SwitchesOnScans(t *testing.T) {
+	result := replayScans(t, SimulationConfig{
+		Orchestrator: &adaptive.OrchestratorConfig{
+			EvaluationInterval: 10 * time.Second,
+			StabilityPeriod:    30 * time.Second,
+			SwitchThreshold:    0.05,
+			MaxMemory:          1024 * 1024 * 1024,
+		},
+		Policies:          []string{"lru", "lfu"},
+		Capacity:          60,
+		InitialPolicy:     "lru",
+		RefreshCandidates: true,
+	})
+
+	if result.Accesses != 20000 || result.RecordedMissRatio != 0 {
+		t.Errorf("unexpected totals: %+v", result)
+	}
+	if result.PolicyMissRatios["lfu"] >= result.PolicyMissRatios["lru"] {
+		t.Fatalf("want lfu to miss less than lru, got: %v", result.PolicyMissRatios)
+	}
+	if result.InitialPolicy != "lru" || result.FinalPolicy != "lfu" || result.Switches != 1 {
+		t.Fatalf("want one switch from lru to lfu, got: %+v", result)
+	}
+	if result.MissRatio >= result.PolicyMissRatios["lru"] {
+		t.Errorf("want the switch to lower the miss ratio, got %.4f against %.4f for lru", result.MissRatio, result.PolicyMissRatios["lru"])
+	}
+
+	var switched *Decision
+	for i, decision := range result.Decisions {
+		if decision.Decision == decisionSwitch {
+			switched = &result.Decisions[i]
+		}
+	}
+	if switched == nil || switched.FromPolicy != "lru" || switched.ToPolicy != "lfu" || switched.NetBenefit <= 0.05 || len(switched.Trigger) == 0 {
+		t.Errorf("want the switch to be recorded, got: %+v", result.Decisions)
+	}
+}
+
+func Test_Simulation_ProfilesInitialPolicy(t *testing.T) {
+	result := replayScans(t, SimulationConfig{
+		Orchestrator: &adaptive.OrchestratorConfig{
+			EvaluationInterval: 100 * time.Second,
+			StabilityPeriod:    30 * time.Second,
+			SwitchThreshold:    0.05,
+			MaxMemory:          1024 * 1024 * 1024,
+		},
+		Policies: []string{"lru", "lfu"},
+		Capacity: 60,
+	})
+
+	if result.InitialPolicy != "lfu" || result.Switches != 0 {
+		t.Errorf("want lfu to be chosen by profiling the first interval, got: %+v", result)
+	}
+	if result.MissRatio != result.PolicyMissRatios["lfu"] {
+		t.Errorf("want the miss ratio of lfu, got %.4f against %.4f", result.MissRatio, result.PolicyMissRatios["lfu"])
+	}
+}
+
+func Test_NewSimulation_Invalid(t *testing.T) {
+	cases := map[string]SimulationConfig{
+		"one policy":     {Policies: []string{"lru"}, Capacity: 10},
+		"unknown policy": {Policies: []string{"lru", "arc"}, Capacity: 10},
+		"no capacity":    {Policies: []string{"lru", "lfu"}},
+		"initial policy": {Policies: []string{"lru", "lfu"}, Capacity: 10, InitialPolicy: "fifo"},
+		"weights":        {Policies: []string{"lru", "lfu"}, Capacity: 10, Weights: []float64{0.5, 0.5}},
+		"weights sum":    {Policies: []string{"lru", "lfu"}, Capacity: 10, Weights: []float64{0.5, 0.5, 0.5}},
+	}
+
+	for name, config := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewSimulation(config); err == nil {
+				t.Errorf("want an error")
+			}
+		})
+	}
+}
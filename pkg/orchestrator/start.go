@@ -0,0 +1,73 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package orchestrator
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/danenherdi/faas-provider/pkg/adaptive"
+	"github.com/danenherdi/faas-provider/types"
+)
+
+// Start initialises the orchestrator and runs its evaluation loop until
+// ctx is done. It returns nil when the orchestrator is disabled or the
+// backend is nil, as it is for a cache which can not change its policy.
+// Decisions are recorded in decisions, which may be nil.
+func Start(ctx context.Context, config types.FaaSConfig, backend Backend, decisions *DecisionLog) *Orchestrator {
+	if !config.EnableIntelligentOrchestrator {
+		log.Println("Intelligent orchestrator is disabled in config")
+		return nil
+	}
+
+	if !config.EnableCaching {
+		log.Println("Intelligent orchestrator disabled because caching is disabled (EnableCaching=false)")
+		return nil
+	}
+
+	if backend == nil {
+		log.Println("Cache backend can not change its eviction policy, orchestrator disabled")
+		return nil
+	}
+
+	log.Printf("%s backend detected, initializing orchestrator...", backend.Name())
+	orchestrator := New(backend, buildOrchestratorConfig(config), decisions)
+
+	log.Println("Starting initialization...")
+	if err := orchestrator.Initialize(); err != nil {
+		log.Printf("WARNING: Initialization failed: %v", err)
+		log.Println("Continuing without adaptive caching")
+		return nil
+	}
+	log.Println("Initialization completed successfully.")
+
+	go orchestrator.Run(ctx)
+
+	return orchestrator
+}
+
+// buildOrchestratorConfig creates orchestrator config from FaaSConfig with defaults
+func buildOrchestratorConfig(faasConfig types.FaaSConfig) *adaptive.OrchestratorConfig {
+	config := adaptive.DefaultOrchestratorConfig()
+
+	if faasConfig.OrchestratorEvalInterval > 0 {
+		config.EvaluationInterval = time.Duration(faasConfig.OrchestratorEvalInterval) * time.Second
+	}
+
+	if faasConfig.OrchestratorStabilityPeriod > 0 {
+		config.StabilityPeriod = time.Duration(faasConfig.OrchestratorStabilityPeriod) * time.Second
+	}
+
+	if faasConfig.OrchestratorSwitchThreshold > 0 && faasConfig.OrchestratorSwitchThreshold <= 1.0 {
+		config.SwitchThreshold = faasConfig.OrchestratorSwitchThreshold
+	}
+
+	// OrchestratorMaxMemory is given in GB
+	if faasConfig.OrchestratorMaxMemory > 0 {
+		config.MaxMemory = faasConfig.OrchestratorMaxMemory * 1024 * 1024 * 1024
+	}
+
+	return config
+}
@@ -0,0 +1,54 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/danenherdi/faas-provider/types"
+	"github.com/openfaas/faas-netes/pkg/caching"
+)
+
+// redisBackend returns the Backend of a Redis cache client whose policy
+// is read by current
+func redisBackend(t *testing.T, current func(ctx context.Context) (string, error)) Backend {
+	t.Helper()
+
+	backend := NewBackend(&caching.Redis{}, []string{"allkeys-lru", "allkeys-lfu"})
+	redis, ok := backend.(*RedisBackend)
+	if !ok {
+		t.Fatalf("want a Redis backend for a Redis cache client, got: %T", backend)
+	}
+	redis.read = func(ctx context.Context) (redisCounters, error) {
+		return redisCounters{policy: "allkeys-lfu", maxMemory: 1024}, nil
+	}
+	redis.current = current
+	return redis
+}
+
+func Test_Start_Redis(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config := types.FaaSConfig{EnableCaching: true, EnableIntelligentOrchestrator: true}
+
+	o := Start(ctx, config, redisBackend(t, func(ctx context.Context) (string, error) {
+		return "allkeys-lfu", nil
+	}), nil)
+	if o == nil {
+		t.Fatalf("want the orchestrator to start with Redis caching")
+	}
+	if o.CurrentPolicy() != "allkeys-lfu" {
+		t.Errorf("want the policy Redis is using, got: %s", o.CurrentPolicy())
+	}
+
+	disabled := Start(ctx, config, redisBackend(t, func(ctx context.Context) (string, error) {
+		return "", errors.New("ERR unknown command 'CONFIG'")
+	}), nil)
+	if disabled != nil {
+		t.Errorf("want the orchestrator to be disabled when CONFIG is unavailable")
+	}
+}
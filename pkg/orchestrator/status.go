@@ -0,0 +1,188 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package orchestrator
+
+import (
+	"sort"
+	"time"
+
+	"github.com/danenherdi/faas-provider/pkg/adaptive"
+)
+
+// Status is the state of the orchestrator as reported by
+// /system/cache/orchestrator
+type Status struct {
+	CurrentPolicy string `json:"currentPolicy"`
+	// Pinned is the policy pinned by an operator, if any
+	Pinned   string `json:"pinned,omitempty"`
+	Paused   bool   `json:"paused"`
+	Switches int    `json:"switches"`
+	// LastSwitch is the time of the last switch, or of the initial choice
+	// of policy
+	LastSwitch time.Time `json:"lastSwitch"`
+
+	Candidates []CandidateStatus `json:"candidates"`
+
+	// LastEvaluation is nil until the candidates have been evaluated once
+	LastEvaluation *EvaluationStatus `json:"lastEvaluation,omitempty"`
+}
+
+// CandidateStatus is the miss ratio last measured for a policy
+type CandidateStatus struct {
+	Policy      string    `json:"policy"`
+	MissRatio   float64   `json:"missRatio"`
+	SampleCount int       `json:"sampleCount"`
+	LastUpdated time.Time `json:"lastUpdated"`
+}
+
+// EvaluationStatus is the outcome of the last evaluation
+type EvaluationStatus struct {
+	Timestamp time.Time `json:"timestamp"`
+	// Decision is one of stable, no candidate, insufficient benefit,
+	// pinned, paused or switch
+	Decision     string               `json:"decision"`
+	PatternShift *PatternShiftStatus  `json:"patternShift,omitempty"`
+	Trend        *TrendStatus         `json:"trend,omitempty"`
+	Analysis     *CostBenefitAnalysis `json:"analysis,omitempty"`
+}
+
+// PatternShiftStatus compares the recent pattern of access with the
+// pattern which preceded it
+type PatternShiftStatus struct {
+	Detected       bool    `json:"detected"`
+	TemporalShift  float64 `json:"temporalShift"`
+	FrequencyShift float64 `json:"frequencyShift"`
+	// Pattern is the current pattern of access
+	Pattern *PatternStatus `json:"pattern,omitempty"`
+}
+
+// PatternStatus describes a window of cache accesses
+type PatternStatus struct {
+	RecencyScore   float64 `json:"recencyScore"`
+	FrequencyScore float64 `json:"frequencyScore"`
+	UniqueKeyRatio float64 `json:"uniqueKeyRatio"`
+	HitRate        float64 `json:"hitRate"`
+	TotalAccesses  int     `json:"totalAccesses"`
+	UniqueKeys     int     `json:"uniqueKeys"`
+}
+
+// TrendStatus is the trend of the miss ratio of the current policy
+type TrendStatus struct {
+	IndicatesDegradation bool    `json:"indicatesDegradation"`
+	Slope                float64 `json:"slope"`
+	Variance             float64 `json:"variance"`
+	Confidence           float64 `json:"confidence"`
+	SampleSize           int     `json:"sampleSize"`
+}
+
+// CostBenefitAnalysis compares the current policy with the best candidate
+type CostBenefitAnalysis struct {
+	CurrentPolicy      string  `json:"currentPolicy"`
+	CandidatePolicy    string  `json:"candidatePolicy"`
+	CurrentMissRatio   float64 `json:"currentMissRatio"`
+	CandidateMissRatio float64 `json:"candidateMissRatio"`
+	PotentialGain      float64 `json:"potentialGain"`
+	SwitchingCost      float64 `json:"switchingCost"`
+	NetBenefit         float64 `json:"netBenefit"`
+	ShouldSwitch       bool    `json:"shouldSwitch"`
+}
+
+// Status returns the policy in use, the operator's overrides, the
+// candidates and the outcome of the last evaluation
+func (o *Orchestrator) Status() Status {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+
+	status := Status{
+		CurrentPolicy: o.currentPolicy,
+		Pinned:        o.pinned,
+		Paused:        o.paused,
+		Switches:      o.switches,
+		LastSwitch:    o.lastSwitch,
+		Candidates:    []CandidateStatus{},
+	}
+
+	for name, metrics := range o.aggregator.aggregated().AllPoliciesMetrics {
+		status.Candidates = append(status.Candidates, CandidateStatus{
+			Policy:      name,
+			MissRatio:   metrics.MissRatio,
+			SampleCount: metrics.SampleCount,
+			LastUpdated: metrics.LastUpdated,
+		})
+	}
+	sort.Slice(status.Candidates, func(i, j int) bool {
+		return status.Candidates[i].Policy < status.Candidates[j].Policy
+	})
+
+	if o.lastEvaluation.IsZero() {
+		return status
+	}
+
+	status.LastEvaluation = &EvaluationStatus{
+		Timestamp:    o.lastEvaluation,
+		Decision:     o.lastDecision,
+		PatternShift: patternShiftStatus(o.lastShift),
+		Trend:        trendStatus(o.lastTrend),
+		Analysis:     analysisStatus(o.lastAnalysis),
+	}
+
+	return status
+}
+
+func patternShiftStatus(shift *adaptive.PatternShift) *PatternShiftStatus {
+	if shift == nil {
+		return nil
+	}
+
+	status := &PatternShiftStatus{
+		Detected:       shift.Detected,
+		TemporalShift:  shift.TemporalShift,
+		FrequencyShift: shift.FrequencyShift,
+	}
+	if pattern := shift.CurrentPattern; pattern != nil {
+		status.Pattern = &PatternStatus{
+			RecencyScore:   pattern.RecencyScore,
+			FrequencyScore: pattern.FrequencyScore,
+			UniqueKeyRatio: pattern.UniqueKeyRatio,
+			HitRate:        pattern.HitRate,
+			TotalAccesses:  pattern.TotalAccesses,
+			UniqueKeys:     pattern.UniqueKeys,
+		}
+	}
+	return status
+}
+
+func trendStatus(trend *adaptive.TrendScore) *TrendStatus {
+	if trend == nil {
+		return nil
+	}
+
+	return &TrendStatus{
+		IndicatesDegradation: trend.IndicatesDegradation,
+		Slope:                trend.Slope,
+		Variance:             trend.Variance,
+		Confidence:           trend.Confidence,
+		SampleSize:           trend.SampleSize,
+	}
+}
+
+func analysisStatus(analysis *adaptive.CostBenefitAnalysis) *CostBenefitAnalysis {
+	if analysis == nil {
+		return nil
+	}
+
+	status := &CostBenefitAnalysis{
+		CurrentPolicy:      analysis.CurrentPolicy,
+		CandidatePolicy:    analysis.CandidatePolicy,
+		CurrentMissRatio:   analysis.CurrentMissRatio,
+		CandidateMissRatio: analysis.CandidateMissRatio,
+		PotentialGain:      analysis.PotentialGain,
+		NetBenefit:         analysis.NetBenefit,
+		ShouldSwitch:       analysis.ShouldSwitch,
+	}
+	if analysis.SwitchingCost != nil {
+		status.SwitchingCost = analysis.SwitchingCost.TotalCost
+	}
+	return status
+}
@@ -0,0 +1,78 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Handler records a server span for each request to next, named after
+// operation and the name in the request's path, such as "function figlet".
+// The span continues the caller's traceparent, and the request's own
+// traceparent is replaced by the span, so that a handler which proxies
+// the request's headers passes on the trace.
+func Handler(operation string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := operation
+		if value := mux.Vars(r)["name"]; len(value) > 0 {
+			name = operation + " " + value
+		}
+
+		ctx, span := Start(Extract(r.Context(), r.Header), name, KindServer)
+		if span == nil {
+			next(w, r)
+			return
+		}
+		defer span.End()
+
+		span.SetAttribute("http.method", r.Method)
+		span.SetAttribute("http.target", r.URL.Path)
+
+		r = r.WithContext(ctx)
+		r.Header = r.Header.Clone()
+		Inject(ctx, r.Header)
+
+		sw := &statusWriter{ResponseWriter: w}
+		next(sw, r)
+
+		status := sw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttribute("http.status_code", status)
+		if status >= 500 {
+			span.SetError(fmt.Errorf("status code %d", status))
+		}
+	}
+}
+
+// statusWriter keeps the status written to a response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush passes on to the underlying writer, for streamed responses
+func (w *statusWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
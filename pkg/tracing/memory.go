@@ -0,0 +1,52 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package tracing
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryExporter keeps every span in memory, it is used by tests to check
+// the spans which were recorded
+type MemoryExporter struct {
+	lock  sync.Mutex
+	spans []SpanData
+}
+
+// NewMemoryExporter creates an empty MemoryExporter
+func NewMemoryExporter() *MemoryExporter {
+	return &MemoryExporter{}
+}
+
+// ExportSpans keeps spans
+func (e *MemoryExporter) ExportSpans(spans []SpanData) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.spans = append(e.spans, spans...)
+}
+
+// Shutdown does nothing, the spans are kept
+func (e *MemoryExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// Spans returns the spans in the order in which they ended
+func (e *MemoryExporter) Spans() []SpanData {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	spans := make([]SpanData, len(e.spans))
+	copy(spans, e.spans)
+	return spans
+}
+
+// Reset drops the spans
+func (e *MemoryExporter) Reset() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.spans = nil
+}
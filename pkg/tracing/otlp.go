@@ -0,0 +1,445 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/openfaas/faas-netes/pkg/config"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// scopeName is the instrumentation scope of the exported spans
+	scopeName = "github.com/openfaas/faas-netes"
+
+	// exportQueueSize is the number of ended spans which wait to be sent,
+	// further spans are dropped until the exporter catches up
+	exportQueueSize = 2048
+	// exportBatchSize is the largest number of spans sent at once
+	exportBatchSize = 512
+	// exportInterval is the longest time a span waits to be sent
+	exportInterval = 5 * time.Second
+
+	// protocolJSON and protocolProtobuf are the encodings of OTLP/HTTP
+	protocolJSON     = "http/json"
+	protocolProtobuf = "http/protobuf"
+)
+
+// NewExporter creates the exporter configured by cfg, nil is returned when
+// the exporter is none
+func NewExporter(cfg config.TracingConfig) (Exporter, error) {
+	switch cfg.Exporter {
+	case "", "none":
+		return nil, nil
+	case "otlp":
+		u, err := url.Parse(cfg.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			return nil, fmt.Errorf("the OTLP endpoint must be an http or https URL, got %q", cfg.Endpoint)
+		}
+		if cfg.Protocol != "" && cfg.Protocol != protocolJSON && cfg.Protocol != protocolProtobuf {
+			return nil, fmt.Errorf("the OTLP protocol must be %s or %s, got %q", protocolProtobuf, protocolJSON, cfg.Protocol)
+		}
+		return NewOTLPExporter(cfg.Endpoint, cfg.Protocol, cfg.Headers, cfg.ServiceName), nil
+	}
+	return nil, fmt.Errorf("unknown exporter %q", cfg.Exporter)
+}
+
+// OTLPExporter sends spans in batches to an OpenTelemetry collector with
+// OTLP over HTTP, encoded as protobuf or JSON
+type OTLPExporter struct {
+	endpoint    string
+	protocol    string
+	headers     map[string]string
+	serviceName string
+	client      *http.Client
+
+	spans   chan SpanData
+	flushes chan chan struct{}
+
+	lock    sync.Mutex
+	dropped int
+}
+
+// NewOTLPExporter creates an exporter which sends spans to endpoint, the
+// full URL of the collector's traces receiver. protocol is http/protobuf,
+// the default when empty, or http/json.
+func NewOTLPExporter(endpoint, protocol string, headers map[string]string, serviceName string) *OTLPExporter {
+	if protocol == "" {
+		protocol = protocolProtobuf
+	}
+
+	e := &OTLPExporter{
+		endpoint:    endpoint,
+		protocol:    protocol,
+		headers:     headers,
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		spans:       make(chan SpanData, exportQueueSize),
+		flushes:     make(chan chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// ExportSpans queues spans to be sent without waiting, spans which do not
+// fit in the queue are dropped and counted
+func (e *OTLPExporter) ExportSpans(spans []SpanData) {
+	for _, span := range spans {
+		select {
+		case e.spans <- span:
+		default:
+			e.lock.Lock()
+			e.dropped++
+			e.lock.Unlock()
+		}
+	}
+}
+
+// Shutdown sends the queued spans, waiting until they are sent or ctx is
+// done
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	select {
+	case e.flushes <- done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run sends a batch when it is full, on each interval and when flushed
+func (e *OTLPExporter) run() {
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, exportBatchSize)
+	send := func() {
+		if len(batch) > 0 {
+			if err := e.send(batch); err != nil {
+				log.Printf("error exporting %d spans: %s", len(batch), err.Error())
+			}
+			batch = batch[:0]
+		}
+
+		e.lock.Lock()
+		dropped := e.dropped
+		e.dropped = 0
+		e.lock.Unlock()
+		if dropped > 0 {
+			log.Printf("dropped %d spans, the export queue was full", dropped)
+		}
+	}
+
+	for {
+		select {
+		case span := <-e.spans:
+			batch = append(batch, span)
+			if len(batch) >= exportBatchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case done := <-e.flushes:
+			for queued := len(e.spans); queued > 0; queued-- {
+				batch = append(batch, <-e.spans)
+				if len(batch) >= exportBatchSize {
+					send()
+				}
+			}
+			send()
+			close(done)
+		}
+	}
+}
+
+// send POSTs a batch of spans to the collector
+func (e *OTLPExporter) send(spans []SpanData) error {
+	body, contentType := e.protoRequest(spans), "application/x-protobuf"
+	if e.protocol == protocolJSON {
+		var err error
+		if body, err = json.Marshal(e.request(spans)); err != nil {
+			return err
+		}
+		contentType = "application/json"
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for key, value := range e.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// otlpRequest is an ExportTraceServiceRequest in the JSON encoding of
+// OTLP, in which IDs are hex strings and 64-bit integers are strings
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              Kind            `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+// Status codes of OTLP
+const (
+	otlpStatusUnset = 0
+	otlpStatusError = 2
+)
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+// request encodes a batch of spans under the exporter's resource
+func (e *OTLPExporter) request(spans []SpanData) otlpRequest {
+	encoded := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		s := otlpSpan{
+			TraceID:           span.TraceID.String(),
+			SpanID:            span.SpanID.String(),
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.Attributes),
+			Status:            otlpStatus{Code: otlpStatusUnset},
+		}
+		if span.ParentID.IsValid() {
+			s.ParentSpanID = span.ParentID.String()
+		}
+		if len(span.Error) > 0 {
+			s.Status = otlpStatus{Code: otlpStatusError, Message: span.Error}
+		}
+		encoded = append(encoded, s)
+	}
+
+	return otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: otlpAttributes(map[string]interface{}{"service.name": e.serviceName}),
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: scopeName},
+				Spans: encoded,
+			}},
+		}},
+	}
+}
+
+// otlpAttributes encodes attributes in key order, a value of any other
+// type than those of SpanData.Attributes is formatted as a string
+func otlpAttributes(attributes map[string]interface{}) []otlpAttribute {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	encoded := make([]otlpAttribute, 0, len(keys))
+	for _, key := range keys {
+		var value otlpValue
+		switch v := attributes[key].(type) {
+		case string:
+			value.StringValue = &v
+		case bool:
+			value.BoolValue = &v
+		case int:
+			i := strconv.Itoa(v)
+			value.IntValue = &i
+		case int64:
+			i := strconv.FormatInt(v, 10)
+			value.IntValue = &i
+		case float64:
+			value.DoubleValue = &v
+		default:
+			s := fmt.Sprint(v)
+			value.StringValue = &s
+		}
+		encoded = append(encoded, otlpAttribute{Key: key, Value: value})
+	}
+	return encoded
+}
+
+// Field numbers of the OTLP messages in an ExportTraceServiceRequest
+const (
+	fieldRequestResourceSpans = 1
+
+	fieldResourceSpansResource   = 1
+	fieldResourceSpansScopeSpans = 2
+	fieldResourceAttributes      = 1
+
+	fieldScopeSpansScope = 1
+	fieldScopeSpansSpans = 2
+	fieldScopeName       = 1
+
+	fieldSpanTraceID      = 1
+	fieldSpanSpanID       = 2
+	fieldSpanParentSpanID = 4
+	fieldSpanName         = 5
+	fieldSpanKind         = 6
+	fieldSpanStartTime    = 7
+	fieldSpanEndTime      = 8
+	fieldSpanAttributes   = 9
+	fieldSpanStatus       = 15
+
+	fieldStatusMessage = 2
+	fieldStatusCode    = 3
+
+	fieldKeyValueKey   = 1
+	fieldKeyValueValue = 2
+
+	fieldValueString = 1
+	fieldValueBool   = 2
+	fieldValueInt    = 3
+	fieldValueDouble = 4
+)
+
+// protoRequest encodes a batch of spans under the exporter's resource as
+// an ExportTraceServiceRequest in the protobuf encoding of OTLP
+func (e *OTLPExporter) protoRequest(spans []SpanData) []byte {
+	resource := appendProtoAttributes(nil, fieldResourceAttributes, map[string]interface{}{"service.name": e.serviceName})
+
+	scopeSpans := appendProtoBytes(nil, fieldScopeSpansScope, appendProtoBytes(nil, fieldScopeName, []byte(scopeName)))
+	for _, span := range spans {
+		scopeSpans = appendProtoBytes(scopeSpans, fieldScopeSpansSpans, protoSpan(span))
+	}
+
+	resourceSpans := appendProtoBytes(nil, fieldResourceSpansResource, resource)
+	resourceSpans = appendProtoBytes(resourceSpans, fieldResourceSpansScopeSpans, scopeSpans)
+
+	return appendProtoBytes(nil, fieldRequestResourceSpans, resourceSpans)
+}
+
+// protoSpan encodes a span as an OTLP Span message
+func protoSpan(span SpanData) []byte {
+	b := appendProtoBytes(nil, fieldSpanTraceID, span.TraceID[:])
+	b = appendProtoBytes(b, fieldSpanSpanID, span.SpanID[:])
+	if span.ParentID.IsValid() {
+		b = appendProtoBytes(b, fieldSpanParentSpanID, span.ParentID[:])
+	}
+	b = appendProtoBytes(b, fieldSpanName, []byte(span.Name))
+	b = appendProtoVarint(b, fieldSpanKind, uint64(span.Kind))
+	b = appendProtoFixed64(b, fieldSpanStartTime, uint64(span.Start.UnixNano()))
+	b = appendProtoFixed64(b, fieldSpanEndTime, uint64(span.End.UnixNano()))
+	b = appendProtoAttributes(b, fieldSpanAttributes, span.Attributes)
+
+	var status []byte
+	if len(span.Error) > 0 {
+		status = appendProtoBytes(status, fieldStatusMessage, []byte(span.Error))
+		status = appendProtoVarint(status, fieldStatusCode, otlpStatusError)
+	}
+	return appendProtoBytes(b, fieldSpanStatus, status)
+}
+
+// appendProtoAttributes appends each attribute as a KeyValue message in
+// field num, with the same order and value types as otlpAttributes
+func appendProtoAttributes(b []byte, num protowire.Number, attributes map[string]interface{}) []byte {
+	for _, attribute := range otlpAttributes(attributes) {
+		var value []byte
+		switch v := attribute.Value; {
+		case v.BoolValue != nil:
+			value = appendProtoVarint(nil, fieldValueBool, protowire.EncodeBool(*v.BoolValue))
+		case v.IntValue != nil:
+			i, _ := strconv.ParseInt(*v.IntValue, 10, 64)
+			value = appendProtoVarint(nil, fieldValueInt, uint64(i))
+		case v.DoubleValue != nil:
+			value = appendProtoFixed64(nil, fieldValueDouble, math.Float64bits(*v.DoubleValue))
+		case v.StringValue != nil:
+			value = appendProtoBytes(nil, fieldValueString, []byte(*v.StringValue))
+		}
+
+		keyValue := appendProtoBytes(nil, fieldKeyValueKey, []byte(attribute.Key))
+		keyValue = appendProtoBytes(keyValue, fieldKeyValueValue, value)
+		b = appendProtoBytes(b, num, keyValue)
+	}
+	return b
+}
+
+func appendProtoBytes(b []byte, num protowire.Number, value []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, value)
+}
+
+func appendProtoVarint(b []byte, num protowire.Number, value uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, value)
+}
+
+func appendProtoFixed64(b []byte, num protowire.Number, value uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, value)
+}
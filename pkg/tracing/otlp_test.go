@@ -0,0 +1,233 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openfaas/faas-netes/pkg/config"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func Test_OTLPExporter_SendsJSONOnShutdown(t *testing.T) {
+	received := make(chan otlpRequest, 1)
+	var header http.Header
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		var req otlpRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		received <- req
+	}))
+	defer collector.Close()
+
+	exporter := NewOTLPExporter(collector.URL+"/v1/traces", "http/json", map[string]string{"Authorization": "Bearer abc"}, "faas-netes")
+
+	start := time.Unix(1, 0)
+	exporter.ExportSpans([]SpanData{{
+		TraceID:    TraceID{1},
+		SpanID:     SpanID{2},
+		ParentID:   SpanID{3},
+		Name:       "flow checkout",
+		Kind:       KindServer,
+		Start:      start,
+		End:        start.Add(time.Second),
+		Attributes: map[string]interface{}{"flow.name": "checkout", "http.status_code": 502, "cached": true},
+		Error:      "status code 502",
+	}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := exporter.Shutdown(ctx); err != nil {
+		t.Fatalf("unable to shut down: %s", err)
+	}
+
+	var req otlpRequest
+	select {
+	case req = <-received:
+	default:
+		t.Fatalf("want the spans to be sent on shutdown")
+	}
+
+	if header.Get("Authorization") != "Bearer abc" || header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected headers: %v", header)
+	}
+	if len(req.ResourceSpans) != 1 || len(req.ResourceSpans[0].ScopeSpans) != 1 || len(req.ResourceSpans[0].ScopeSpans[0].Spans) != 1 {
+		t.Fatalf("want one span, got: %+v", req)
+	}
+	if service := req.ResourceSpans[0].Resource.Attributes[0]; service.Key != "service.name" || *service.Value.StringValue != "faas-netes" {
+		t.Errorf("want the service name, got: %+v", service)
+	}
+
+	span := req.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	if span.TraceID != "01000000000000000000000000000000" || span.SpanID != "0200000000000000" || span.ParentSpanID != "0300000000000000" {
+		t.Errorf("want hex IDs, got: %+v", span)
+	}
+	if span.StartTimeUnixNano != "1000000000" || span.EndTimeUnixNano != "2000000000" || span.Kind != KindServer {
+		t.Errorf("unexpected span: %+v", span)
+	}
+	if span.Status.Code != otlpStatusError || span.Status.Message != "status code 502" {
+		t.Errorf("want an error status, got: %+v", span.Status)
+	}
+
+	attributes := map[string]otlpValue{}
+	for _, attribute := range span.Attributes {
+		attributes[attribute.Key] = attribute.Value
+	}
+	if v := attributes["http.status_code"].IntValue; v == nil || *v != "502" {
+		t.Errorf("want an int attribute, got: %+v", attributes["http.status_code"])
+	}
+	if v := attributes["cached"].BoolValue; v == nil || !*v {
+		t.Errorf("want a bool attribute, got: %+v", attributes["cached"])
+	}
+}
+
+// protoField is a field of a protobuf message, holding either the bytes of
+// a length-delimited field or the value of a varint or fixed64 field
+type protoField struct {
+	bytes []byte
+	value uint64
+}
+
+// protoFields decodes a protobuf message into its fields by number
+func protoFields(t *testing.T, b []byte) map[protowire.Number][]protoField {
+	t.Helper()
+
+	fields := map[protowire.Number][]protoField{}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatalf("invalid tag: %s", protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		var field protoField
+		switch typ {
+		case protowire.BytesType:
+			field.bytes, n = protowire.ConsumeBytes(b)
+		case protowire.VarintType:
+			field.value, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			field.value, n = protowire.ConsumeFixed64(b)
+		default:
+			t.Fatalf("unexpected wire type %d of field %d", typ, num)
+		}
+		if n < 0 {
+			t.Fatalf("invalid field %d: %s", num, protowire.ParseError(n))
+		}
+		b = b[n:]
+		fields[num] = append(fields[num], field)
+	}
+	return fields
+}
+
+func Test_OTLPExporter_SendsProtobufByDefault(t *testing.T) {
+	received := make(chan []byte, 1)
+	var header http.Header
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		body, _ := io.ReadAll(r.Body)
+		received <- body
+	}))
+	defer collector.Close()
+
+	exporter := NewOTLPExporter(collector.URL+"/v1/traces", "", nil, "faas-netes")
+
+	start := time.Unix(1, 0)
+	exporter.ExportSpans([]SpanData{{
+		TraceID:    TraceID{1},
+		SpanID:     SpanID{2},
+		Name:       "flow checkout",
+		Kind:       KindServer,
+		Start:      start,
+		End:        start.Add(time.Second),
+		Attributes: map[string]interface{}{"flow.name": "checkout", "http.status_code": 502, "cached": true},
+		Error:      "status code 502",
+	}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := exporter.Shutdown(ctx); err != nil {
+		t.Fatalf("unable to shut down: %s", err)
+	}
+
+	var body []byte
+	select {
+	case body = <-received:
+	default:
+		t.Fatalf("want the spans to be sent on shutdown")
+	}
+	if header.Get("Content-Type") != "application/x-protobuf" {
+		t.Errorf("want a protobuf content type, got: %q", header.Get("Content-Type"))
+	}
+
+	resourceSpans := protoFields(t, protoFields(t, body)[fieldRequestResourceSpans][0].bytes)
+	resource := protoFields(t, resourceSpans[fieldResourceSpansResource][0].bytes)
+	service := protoFields(t, resource[fieldResourceAttributes][0].bytes)
+	if key := string(service[fieldKeyValueKey][0].bytes); key != "service.name" {
+		t.Errorf("want the service name, got: %q", key)
+	}
+	if value := protoFields(t, service[fieldKeyValueValue][0].bytes); string(value[fieldValueString][0].bytes) != "faas-netes" {
+		t.Errorf("want service name faas-netes, got: %q", value[fieldValueString][0].bytes)
+	}
+
+	scopeSpans := protoFields(t, resourceSpans[fieldResourceSpansScopeSpans][0].bytes)
+	if spans := scopeSpans[fieldScopeSpansSpans]; len(spans) != 1 {
+		t.Fatalf("want one span, got: %d", len(spans))
+	}
+	span := protoFields(t, scopeSpans[fieldScopeSpansSpans][0].bytes)
+
+	if id := span[fieldSpanTraceID][0].bytes; len(id) != 16 || id[0] != 1 {
+		t.Errorf("want the trace ID as bytes, got: %x", id)
+	}
+	if _, ok := span[fieldSpanParentSpanID]; ok {
+		t.Errorf("want no parent span ID for a root span")
+	}
+	if string(span[fieldSpanName][0].bytes) != "flow checkout" || span[fieldSpanKind][0].value != uint64(KindServer) {
+		t.Errorf("unexpected name or kind: %q %d", span[fieldSpanName][0].bytes, span[fieldSpanKind][0].value)
+	}
+	if span[fieldSpanStartTime][0].value != 1000000000 || span[fieldSpanEndTime][0].value != 2000000000 {
+		t.Errorf("unexpected times: %d %d", span[fieldSpanStartTime][0].value, span[fieldSpanEndTime][0].value)
+	}
+
+	status := protoFields(t, span[fieldSpanStatus][0].bytes)
+	if status[fieldStatusCode][0].value != otlpStatusError || string(status[fieldStatusMessage][0].bytes) != "status code 502" {
+		t.Errorf("want an error status, got: %+v", status)
+	}
+
+	attributes := map[string]map[protowire.Number][]protoField{}
+	for _, attribute := range span[fieldSpanAttributes] {
+		keyValue := protoFields(t, attribute.bytes)
+		attributes[string(keyValue[fieldKeyValueKey][0].bytes)] = protoFields(t, keyValue[fieldKeyValueValue][0].bytes)
+	}
+	if v := attributes["http.status_code"][fieldValueInt]; len(v) != 1 || v[0].value != 502 {
+		t.Errorf("want an int attribute, got: %+v", attributes["http.status_code"])
+	}
+	if v := attributes["cached"][fieldValueBool]; len(v) != 1 || v[0].value != 1 {
+		t.Errorf("want a bool attribute, got: %+v", attributes["cached"])
+	}
+	if v := attributes["flow.name"][fieldValueString]; len(v) != 1 || string(v[0].bytes) != "checkout" {
+		t.Errorf("want a string attribute, got: %+v", attributes["flow.name"])
+	}
+}
+
+func Test_NewExporter(t *testing.T) {
+	if exporter, err := NewExporter(config.TracingConfig{Exporter: "none"}); exporter != nil || err != nil {
+		t.Errorf("want no exporter for none, got %v %v", exporter, err)
+	}
+	if _, err := NewExporter(config.TracingConfig{Exporter: "otlp", Endpoint: "localhost:4318"}); err == nil {
+		t.Errorf("want an error for an endpoint which is not a URL")
+	}
+	if exporter, err := NewExporter(config.TracingConfig{Exporter: "otlp", Endpoint: "http://localhost:4318/v1/traces"}); exporter == nil || err != nil {
+		t.Errorf("want an OTLP exporter, got %v %v", exporter, err)
+	}
+	if _, err := NewExporter(config.TracingConfig{Exporter: "otlp", Endpoint: "http://localhost:4318/v1/traces", Protocol: "grpc"}); err == nil {
+		t.Errorf("want an error for an unsupported protocol")
+	}
+}
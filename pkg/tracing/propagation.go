@@ -0,0 +1,103 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// Headers of the W3C Trace Context, see https://www.w3.org/TR/trace-context/
+const (
+	traceparentHeader = "Traceparent"
+	tracestateHeader  = "Tracestate"
+)
+
+// sampledFlag is the bit of the trace-flags which marks a sampled trace
+const sampledFlag = 0x01
+
+// Extract returns a context with the remote parent of the traceparent
+// header, so that spans started with it continue the caller's trace. ctx
+// is returned as it is when there is no valid traceparent.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	sc, ok := parseTraceparent(header.Get(traceparentHeader))
+	if !ok {
+		return ctx
+	}
+	sc.TraceState = header.Get(tracestateHeader)
+
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// Inject sets the traceparent header to the span in ctx, or to the remote
+// parent extracted into ctx, so that the trace is continued by the service
+// which receives the request
+func Inject(ctx context.Context, header http.Header) {
+	sc := SpanContextFrom(ctx)
+	if !sc.IsValid() {
+		return
+	}
+
+	header.Set(traceparentHeader, formatTraceparent(sc))
+	if len(sc.TraceState) > 0 {
+		header.Set(tracestateHeader, sc.TraceState)
+	} else {
+		header.Del(tracestateHeader)
+	}
+}
+
+// formatTraceparent encodes sc as version 00 of the traceparent header
+func formatTraceparent(sc SpanContext) string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// parseTraceparent decodes a traceparent header. Fields added by versions
+// after 00 are ignored, as the specification requires.
+func parseTraceparent(value string) (SpanContext, bool) {
+	var sc SpanContext
+
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return sc, false
+	}
+
+	version, ok := decodeHex(parts[0], 1)
+	if !ok || version[0] == 0xff || (version[0] == 0 && len(parts) != 4) {
+		return sc, false
+	}
+
+	traceID, ok := decodeHex(parts[1], len(sc.TraceID))
+	if !ok {
+		return sc, false
+	}
+	spanID, ok := decodeHex(parts[2], len(sc.SpanID))
+	if !ok {
+		return sc, false
+	}
+	flags, ok := decodeHex(parts[3], 1)
+	if !ok {
+		return sc, false
+	}
+
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	sc.Sampled = flags[0]&sampledFlag != 0
+
+	return sc, sc.IsValid()
+}
+
+// decodeHex decodes a lowercase hex field of size bytes
+func decodeHex(field string, size int) ([]byte, bool) {
+	if len(field) != size*2 || strings.ToLower(field) != field {
+		return nil, false
+	}
+	data, err := hex.DecodeString(field)
+	return data, err == nil
+}
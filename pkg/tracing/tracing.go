@@ -0,0 +1,270 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+// Package tracing records the spans of flows and function invocations and
+// exports them with OpenTelemetry's OTLP. A W3C traceparent header received
+// with a request is continued, and set on the requests made upstream, so
+// that a trace follows a flow through to its functions. Until an exporter
+// is set no spans are recorded, although a traceparent which is received is
+// still passed on.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// TraceID identifies a trace, the spans of a single request
+type TraceID [16]byte
+
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// IsValid returns false for the all-zero ID
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+// SpanID identifies a span within its trace
+type SpanID [8]byte
+
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// IsValid returns false for the all-zero ID
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+// SpanContext is the part of a span which is propagated to other services
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	// Sampled is set when the span is recorded, spans are only recorded
+	// under a parent which is sampled
+	Sampled bool
+	// TraceState is the vendor-specific tracestate header, which is passed
+	// on unchanged
+	TraceState string
+}
+
+// IsValid returns true when both IDs are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Kind describes the relationship of a span to its parent and children
+type Kind int
+
+// Kinds of span, with the values used by OTLP
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+)
+
+// SpanData is a span which has ended, as it is given to an Exporter
+type SpanData struct {
+	TraceID  TraceID
+	SpanID   SpanID
+	ParentID SpanID
+	Name     string
+	Kind     Kind
+	Start    time.Time
+	End      time.Time
+	// Attributes have string, bool, int, int64 or float64 values
+	Attributes map[string]interface{}
+	// Error describes why the operation failed, it is empty on success
+	Error string
+}
+
+// Exporter sends spans once they have ended
+type Exporter interface {
+	// ExportSpans is called as each span ends, it must not block
+	ExportSpans(spans []SpanData)
+	// Shutdown sends any spans which are held by the exporter
+	Shutdown(ctx context.Context) error
+}
+
+var (
+	exporterLock sync.RWMutex
+	exporter     Exporter
+)
+
+// SetExporter sets the exporter of spans which are started from then on,
+// nil stops spans from being recorded
+func SetExporter(e Exporter) {
+	exporterLock.Lock()
+	defer exporterLock.Unlock()
+
+	exporter = e
+}
+
+// Shutdown sends the spans held by the current exporter, it is called
+// before the process exits
+func Shutdown(ctx context.Context) error {
+	e := currentExporter()
+	if e == nil {
+		return nil
+	}
+	return e.Shutdown(ctx)
+}
+
+func currentExporter() Exporter {
+	exporterLock.RLock()
+	defer exporterLock.RUnlock()
+
+	return exporter
+}
+
+// Span records a single operation, a nil span records nothing so that
+// callers need not check whether tracing is enabled
+type Span struct {
+	lock     sync.Mutex
+	exporter Exporter
+	data     SpanData
+	context  SpanContext
+	ended    bool
+}
+
+type spanKey struct{}
+
+type remoteKey struct{}
+
+// Start starts a span as a child of the span in ctx, or of the remote
+// parent extracted into ctx, or as the root of a new trace. The span is
+// nil when no exporter is set or the parent is not sampled, in which case
+// ctx is returned as it is.
+func Start(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	e := currentExporter()
+	if e == nil {
+		return ctx, nil
+	}
+
+	parent := SpanContextFrom(ctx)
+	if parent.IsValid() && !parent.Sampled {
+		return ctx, nil
+	}
+
+	sc := SpanContext{
+		TraceID:    parent.TraceID,
+		SpanID:     newSpanID(),
+		Sampled:    true,
+		TraceState: parent.TraceState,
+	}
+	if !parent.IsValid() {
+		sc.TraceID = newTraceID()
+	}
+
+	span := &Span{
+		exporter: e,
+		context:  sc,
+		data: SpanData{
+			TraceID:    sc.TraceID,
+			SpanID:     sc.SpanID,
+			ParentID:   parent.SpanID,
+			Name:       name,
+			Kind:       kind,
+			Start:      time.Now(),
+			Attributes: map[string]interface{}{},
+		},
+	}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// SpanFrom returns the span of ctx, or nil
+func SpanFrom(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// SpanContextFrom returns the context of the span in ctx, or of the remote
+// parent extracted into ctx, the context is not valid when there is neither
+func SpanContextFrom(ctx context.Context) SpanContext {
+	if span := SpanFrom(ctx); span != nil {
+		return span.context
+	}
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return sc
+}
+
+// SetName renames the span, for when its name is not known at the start
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.data.Name = name
+}
+
+// SetAttribute sets a string, bool, int, int64 or float64 attribute
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.data.Attributes[key] = value
+}
+
+// SetError marks the operation of the span as failed, a nil err is ignored
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.data.Error = err.Error()
+}
+
+// End completes the span and exports it, further calls are ignored
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+
+	s.lock.Lock()
+	if s.ended {
+		s.lock.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+
+	data := s.data
+	data.Attributes = make(map[string]interface{}, len(s.data.Attributes))
+	for key, value := range s.data.Attributes {
+		data.Attributes[key] = value
+	}
+	s.lock.Unlock()
+
+	s.exporter.ExportSpans([]SpanData{data})
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
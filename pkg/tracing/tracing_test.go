@@ -0,0 +1,148 @@
+// License: OpenFaaS Community Edition (CE) EULA
+// Copyright (c) 2017,2019-2024 OpenFaaS Author(s)
+
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func Test_parseTraceparent(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   string
+		valid   bool
+		sampled bool
+	}{
+		{"sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, true},
+		{"not sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, false},
+		{"future version with more fields", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true, true},
+		{"version 00 with more fields", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false, false},
+		{"invalid version", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, false},
+		{"zero trace ID", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, false},
+		{"zero span ID", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false, false},
+		{"uppercase", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false, false},
+		{"short trace ID", "00-4bf92f3577b34da6-00f067aa0ba902b7-01", false, false},
+		{"empty", "", false, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			sc, ok := parseTraceparent(tc.value)
+			if ok != tc.valid {
+				t.Fatalf("want valid %v, got %v", tc.valid, ok)
+			}
+			if ok && sc.Sampled != tc.sampled {
+				t.Fatalf("want sampled %v, got %v", tc.sampled, sc.Sampled)
+			}
+		})
+	}
+}
+
+func Test_Start_ContinuesRemoteParent(t *testing.T) {
+	exporter := NewMemoryExporter()
+	SetExporter(exporter)
+	defer SetExporter(nil)
+
+	header := http.Header{}
+	header.Set(traceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	header.Set(tracestateHeader, "vendor=a")
+
+	ctx, parent := Start(Extract(context.Background(), header), "parent", KindServer)
+	_, child := Start(ctx, "child", KindInternal)
+	child.SetAttribute("key", "value")
+	child.End()
+	parent.End()
+	parent.End()
+
+	spans := exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("want 2 spans, got %d", len(spans))
+	}
+	if spans[1].TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || spans[1].ParentID.String() != "00f067aa0ba902b7" {
+		t.Errorf("want the parent to continue the remote trace, got: %+v", spans[1])
+	}
+	if spans[0].TraceID != spans[1].TraceID || spans[0].ParentID != spans[1].SpanID {
+		t.Errorf("want the child under the parent, got: %+v", spans[0])
+	}
+	if spans[0].Attributes["key"] != "value" {
+		t.Errorf("want the child's attributes, got: %v", spans[0].Attributes)
+	}
+
+	out := http.Header{}
+	Inject(ctx, out)
+	if want := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + parent.context.SpanID.String() + "-01"; out.Get(traceparentHeader) != want {
+		t.Errorf("want traceparent %s, got %s", want, out.Get(traceparentHeader))
+	}
+	if out.Get(tracestateHeader) != "vendor=a" {
+		t.Errorf("want the tracestate to be passed on, got %q", out.Get(tracestateHeader))
+	}
+}
+
+func Test_Start_RecordsNothingWithoutExporter(t *testing.T) {
+	header := http.Header{}
+	header.Set(traceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	ctx, span := Start(Extract(context.Background(), header), "span", KindServer)
+	if span != nil {
+		t.Fatalf("want no span without an exporter")
+	}
+	span.SetAttribute("key", "value")
+	span.End()
+
+	out := http.Header{}
+	Inject(ctx, out)
+	if out.Get(traceparentHeader) != header.Get(traceparentHeader) {
+		t.Fatalf("want the traceparent to be passed on, got %q", out.Get(traceparentHeader))
+	}
+}
+
+func Test_Start_RespectsUnsampledParent(t *testing.T) {
+	exporter := NewMemoryExporter()
+	SetExporter(exporter)
+	defer SetExporter(nil)
+
+	header := http.Header{}
+	header.Set(traceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+
+	_, span := Start(Extract(context.Background(), header), "span", KindServer)
+	span.End()
+
+	if len(exporter.Spans()) != 0 {
+		t.Fatalf("want no spans under an unsampled parent, got %d", len(exporter.Spans()))
+	}
+}
+
+func Test_Handler_PropagatesSpan(t *testing.T) {
+	exporter := NewMemoryExporter()
+	SetExporter(exporter)
+	defer SetExporter(nil)
+
+	var received string
+	handler := Handler("function", func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(traceparentHeader)
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/function/figlet", nil)
+	req.Header.Set(traceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req = mux.SetURLVars(req, map[string]string{"name": "figlet"})
+	handler(httptest.NewRecorder(), req)
+
+	spans := exporter.Spans()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name != "function figlet" || span.Kind != KindServer || span.Attributes["http.status_code"] != http.StatusBadGateway || len(span.Error) == 0 {
+		t.Errorf("unexpected span: %+v", span)
+	}
+	if want := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + span.SpanID.String() + "-01"; received != want {
+		t.Errorf("want the handler to receive traceparent %s, got %s", want, received)
+	}
+}